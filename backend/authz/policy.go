@@ -0,0 +1,124 @@
+// Package authz 报告访问授权策略
+//
+// 报告的所有者拥有全部权限；其他用户通过直接授权或所在团队的授权获得查看者或编辑者角色。
+// 仓储层按ID读取报告，是否允许访问由本包根据角色判断，不再写在SQL的WHERE条件中。
+package authz
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+// Role 用户对报告的角色
+type Role string
+
+// 报告角色，权限依次递增
+const (
+	RoleNone   Role = ""
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+// Action 对报告执行的操作
+type Action string
+
+// 报告操作
+const (
+	// ActionView 查看报告、分页文本、PDF，以及基于报告的问答
+	ActionView Action = "view"
+	// ActionEdit 修改标题、生成摘要、解锁加密PDF
+	ActionEdit Action = "edit"
+	// ActionManage 删除报告、管理分享链接和授权
+	ActionManage Action = "manage"
+)
+
+// 授权错误
+var (
+	// ErrNoAccess 用户对报告没有任何角色，调用方应按报告不存在处理，避免泄露报告是否存在
+	ErrNoAccess = errors.New("无权访问该报告")
+	// ErrForbidden 用户可以访问报告，但角色不允许执行该操作
+	ErrForbidden = errors.New("没有执行该操作的权限")
+)
+
+// rank 角色的权限等级
+var rank = map[Role]int{RoleNone: 0, RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// required 执行操作需要的最低角色
+var required = map[Action]Role{ActionView: RoleViewer, ActionEdit: RoleEditor, ActionManage: RoleOwner}
+
+// Allows 判断角色是否允许执行操作
+func (r Role) Allows(action Action) bool {
+	need, ok := required[action]
+	return ok && rank[r] >= rank[need]
+}
+
+// MaxRole 返回两个角色中权限较高的一个
+func MaxRole(a, b Role) Role {
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// IsGrantable 判断角色是否可以授予其他用户或团队，所有者角色不能授予
+func (r Role) IsGrantable() bool {
+	return r == RoleViewer || r == RoleEditor
+}
+
+// GrantSource 查询授予用户的报告角色，包括直接授权和通过团队获得的授权
+type GrantSource interface {
+	GrantedRoles(ctx context.Context, reportID string, userID string) ([]string, error)
+}
+
+// ReportPolicy 报告授权策略
+type ReportPolicy struct {
+	grants GrantSource
+}
+
+// NewReportPolicy 创建报告授权策略，grants为nil时只有所有者可以访问报告
+func NewReportPolicy(grants GrantSource) *ReportPolicy {
+	return &ReportPolicy{grants: grants}
+}
+
+// RoleOf 返回用户对报告的角色，有多个授权时取权限最高的角色
+func (p *ReportPolicy) RoleOf(ctx context.Context, userID string, report *models.Report) (Role, error) {
+	if userID == "" {
+		return RoleNone, nil
+	}
+	if report.UserID == userID {
+		return RoleOwner, nil
+	}
+	if p == nil || p.grants == nil {
+		return RoleNone, nil
+	}
+	granted, err := p.grants.GrantedRoles(ctx, report.ID, userID)
+	if err != nil {
+		return RoleNone, err
+	}
+	role := RoleNone
+	for _, g := range granted {
+		if r := Role(g); r.IsGrantable() && rank[r] > rank[role] {
+			role = r
+		}
+	}
+	return role, nil
+}
+
+// Authorize 判断用户能否对报告执行操作，返回用户的角色
+// 没有任何角色时返回ErrNoAccess，角色权限不足时返回ErrForbidden
+func (p *ReportPolicy) Authorize(ctx context.Context, userID string, report *models.Report, action Action) (Role, error) {
+	role, err := p.RoleOf(ctx, userID, report)
+	if err != nil {
+		return RoleNone, err
+	}
+	if role == RoleNone {
+		return RoleNone, ErrNoAccess
+	}
+	if !role.Allows(action) {
+		return role, ErrForbidden
+	}
+	return role, nil
+}
@@ -0,0 +1,50 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+// fakeGrants 按用户返回授予的角色
+type fakeGrants map[string][]string
+
+func (g fakeGrants) GrantedRoles(_ context.Context, _ string, userID string) ([]string, error) {
+	return g[userID], nil
+}
+
+func TestReportPolicy_Authorize(t *testing.T) {
+	ctx := context.Background()
+	report := &models.Report{ID: "r1", UserID: "owner"}
+	policy := NewReportPolicy(fakeGrants{
+		"viewer": {"viewer"},
+		"both":   {"viewer", "editor"}, // 直接授权和团队授权取较高的角色
+	})
+
+	role, err := policy.Authorize(ctx, "owner", report, ActionManage)
+	require.NoError(t, err)
+	assert.Equal(t, RoleOwner, role)
+
+	role, err = policy.Authorize(ctx, "viewer", report, ActionView)
+	require.NoError(t, err)
+	assert.Equal(t, RoleViewer, role)
+	_, err = policy.Authorize(ctx, "viewer", report, ActionEdit)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	role, err = policy.Authorize(ctx, "both", report, ActionEdit)
+	require.NoError(t, err)
+	assert.Equal(t, RoleEditor, role)
+	_, err = policy.Authorize(ctx, "both", report, ActionManage)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = policy.Authorize(ctx, "stranger", report, ActionView)
+	assert.ErrorIs(t, err, ErrNoAccess)
+
+	// 未配置授权来源时只有所有者可以访问
+	_, err = (*ReportPolicy)(nil).Authorize(ctx, "viewer", report, ActionView)
+	assert.ErrorIs(t, err, ErrNoAccess)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// GrantHandler 处理报告共享授权相关的请求
+type GrantHandler struct {
+	grantService *services.GrantService
+}
+
+// NewGrantHandler 创建新的报告授权处理器
+func NewGrantHandler(grantService *services.GrantService) *GrantHandler {
+	return &GrantHandler{grantService: grantService}
+}
+
+// GrantAccess 将报告共享给用户或团队
+func (h *GrantHandler) GrantAccess(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.GrantReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	grant, err := h.grantService.GrantAccess(c, c.Param("report_id"), userID, req)
+	if err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidGrant):
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+		case errors.Is(err, repository.ErrUserNotFound), errors.Is(err, repository.ErrTeamNotFound):
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, err.Error(), nil))
+		default:
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "共享报告失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "共享成功", grant))
+}
+
+// ListGrants 列出报告的共享授权
+func (h *GrantHandler) ListGrants(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	grants, err := h.grantService.ListGrants(c, c.Param("report_id"), userID)
+	if err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取共享授权失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", grants))
+}
+
+// RevokeGrant 取消报告的共享授权
+func (h *GrantHandler) RevokeGrant(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	if err := h.grantService.RevokeGrant(c, c.Param("report_id"), c.Param("grant_id"), userID); err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrGrantNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "取消共享失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "已取消共享", nil))
+}
+
+// ListSharedWithMe 列出其他用户共享给当前用户的报告
+func (h *GrantHandler) ListSharedWithMe(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	reports, err := h.grantService.ListSharedWithMe(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取共享报告失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", reports))
+}
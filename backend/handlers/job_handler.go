@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// JobHandler 处理异步任务相关的请求
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+// NewJobHandler 创建新的任务处理器
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService: jobService}
+}
+
+// GetJob 查询任务状态
+func (h *JobHandler) GetJob(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	// 获取任务ID
+	jobID := c.Param("job_id")
+	if jobID == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的任务ID", nil))
+		return
+	}
+
+	job, err := h.jobService.GetJob(c, jobID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, repository.ErrJobNotFound.Error(), nil))
+			return
+		}
+		log.Printf("获取任务%s失败: %v", jobID, err)
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取任务失败", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", job))
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// QuotaHandler 处理大模型配额相关的请求
+type QuotaHandler struct {
+	quotaService *services.QuotaService
+}
+
+// NewQuotaHandler 创建新的配额处理器
+func NewQuotaHandler(quotaService *services.QuotaService) *QuotaHandler {
+	return &QuotaHandler{quotaService: quotaService}
+}
+
+// GetQuota 获取当前用户当天和当月的配额使用情况
+func (h *QuotaHandler) GetQuota(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	status, err := h.quotaService.Status(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取配额失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", status))
+}
+
+// ListPlans 列出全部套餐，仅管理员可用
+func (h *QuotaHandler) ListPlans(c *gin.Context) {
+	plans, err := h.quotaService.ListPlans(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取套餐失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", plans))
+}
+
+// UpdatePlan 创建或修改套餐配额，仅管理员可用，修改后立即生效
+func (h *QuotaHandler) UpdatePlan(c *gin.Context) {
+	name := c.Param("plan")
+	if name == "" || len(name) > 32 {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的套餐名称", nil))
+		return
+	}
+
+	var limits models.QuotaLimits
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	plan, err := h.quotaService.UpdatePlan(c, name, limits)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuotaLimits) {
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "保存套餐失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "保存成功", plan))
+}
+
+// GetUserQuota 获取指定用户的套餐、单独配额和使用情况，仅管理员可用
+func (h *QuotaHandler) GetUserQuota(c *gin.Context) {
+	userID := c.Param("user_id")
+	quota, err := h.quotaService.GetUserQuota(c, userID)
+	if err != nil {
+		if !quotaDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取用户配额失败", err.Error()))
+		}
+		return
+	}
+	status, err := h.quotaService.Status(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取用户配额失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", gin.H{"quota": quota, "status": status}))
+}
+
+// UpdateUserQuota 修改指定用户的套餐和单独配额，仅管理员可用，修改后立即生效
+func (h *QuotaHandler) UpdateUserQuota(c *gin.Context) {
+	var req models.UpdateUserQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	quota, err := h.quotaService.UpdateUserQuota(c, c.Param("user_id"), req)
+	if err != nil {
+		if !quotaDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "保存用户配额失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "保存成功", quota))
+}
+
+// quotaDenied 处理用户或套餐不存在、配额为负数的错误，已响应时返回true
+func quotaDenied(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, repository.ErrUserNotFound.Error(), nil))
+	case errors.Is(err, repository.ErrQuotaPlanNotFound):
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, repository.ErrQuotaPlanNotFound.Error(), nil))
+	case errors.Is(err, services.ErrInvalidQuotaLimits):
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+	default:
+		return false
+	}
+	return true
+}
+
+// quotaExceeded 超出配额时返回429，Retry-After为距离配额重置的秒数，响应数据包含剩余配额和重置时间；err不是配额错误时返回false
+func quotaExceeded(c *gin.Context, err error) bool {
+	var exceeded *services.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	retryAfter := max(int(time.Until(exceeded.ResetAt).Seconds())+1, 1)
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, models.NewAPIResponse(http.StatusTooManyRequests, exceeded.Error(), models.QuotaExceededResponse{
+		Limit:          exceeded.Limit,
+		ResetAt:        exceeded.ResetAt,
+		RequiredTokens: exceeded.RequiredTokens,
+		Quota:          exceeded.Status,
+	}))
+	return true
+}
@@ -1,23 +1,40 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/authz"
 	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
 	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
 	"github.com/qujing226/pdf-enhancer/backend/utils"
 )
 
 // ReportHandler 处理报告相关的请求
 type ReportHandler struct {
 	reportService *services.ReportService
+	jobService    *services.JobService
+	maxUploadSize int64
 }
 
-// NewReportHandler 创建新的报告处理器
-func NewReportHandler(reportService *services.ReportService) *ReportHandler {
-	return &ReportHandler{reportService: reportService}
+// uploadFormOverhead multipart表单中除文件内容外的边界和字段头所允许的额外字节数
+const uploadFormOverhead = 1 << 20
+
+// NewReportHandler 创建新的报告处理器，maxUploadSize为上传PDF的最大字节数，0表示不限制
+func NewReportHandler(reportService *services.ReportService, jobService *services.JobService, maxUploadSize int64) *ReportHandler {
+	return &ReportHandler{reportService: reportService, jobService: jobService, maxUploadSize: maxUploadSize}
 }
 
 // UploadReport 处理报告上传请求
@@ -30,27 +47,116 @@ func (h *ReportHandler) UploadReport(c *gin.Context) {
 	}
 
 	// 获取上传的文件
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "未找到上传的文件", err.Error()))
+	header, ok := h.uploadedFile(c)
+	if !ok {
 		return
 	}
-	defer file.Close()
 
 	// 获取文件标题
-	title := header.Filename
+	title := uploadTitle(header.Filename)
+
+	// 加密PDF的打开密码和是否保存解密副本均为可选表单字段
+	opts := services.UploadOptions{
+		Password:       c.PostForm("password"),
+		StoreDecrypted: c.PostForm("store_decrypted") == "true",
+	}
 
 	// 创建报告
-	report, err := h.reportService.CreateReportFromUpload(c, userID, title, header)
+	result, err := h.reportService.CreateReportFromUpload(c, userID, title, header, opts)
 	if err != nil {
+		if pdfInvalid(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "上传报告失败", err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.NewAPIResponse(http.StatusCreated, "上传成功", report))
+	message := "上传成功"
+	switch {
+	case result.Duplicate:
+		message = "上传成功，已存在相同内容的报告"
+	case result.EncryptionStatus == models.EncryptionLocked:
+		message = "上传成功，文件已加密，提供密码后才能提取内容"
+	}
+	c.JSON(http.StatusCreated, models.NewAPIResponse(http.StatusCreated, message, result))
+}
+
+// uploadedFile 限制请求体大小并解析上传的文件，失败时已写入响应并返回false
+// 使用c.FormFile按引擎的MaxMultipartMemory解析表单，超出部分写入临时文件，不在内存中缓存整个文件
+func (h *ReportHandler) uploadedFile(c *gin.Context) (*multipart.FileHeader, bool) {
+	// 在读取请求体之前限制大小，声明的长度已超限时直接拒绝
+	if h.maxUploadSize > 0 {
+		limit := h.maxUploadSize + uploadFormOverhead
+		if c.Request.ContentLength > limit {
+			h.uploadTooLarge(c)
+			return nil, false
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.uploadTooLarge(c)
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "未找到上传的文件", err.Error()))
+		return nil, false
+	}
+	if h.maxUploadSize > 0 && header.Size > h.maxUploadSize {
+		h.uploadTooLarge(c)
+		return nil, false
+	}
+	return header, true
+}
+
+// pdfInvalid PDF校验失败时返回422及失败原因，err不是校验错误时返回false
+func pdfInvalid(c *gin.Context, err error) bool {
+	var validationErr *utils.PDFValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	message := "文件不是有效的PDF"
+	if validationErr.Reason == utils.PDFRejectWrongPassword {
+		message = "PDF密码错误"
+	}
+	c.JSON(http.StatusUnprocessableEntity, models.NewAPIResponse(http.StatusUnprocessableEntity, message, gin.H{
+		"reason": validationErr.Reason,
+		"detail": validationErr.Detail,
+	}))
+	return true
+}
+
+// uploadTitle 由客户端提供的文件名生成报告标题：去掉路径和控制字符，并限制长度
+func uploadTitle(filename string) string {
+	if i := strings.LastIndexAny(filename, `/\`); i >= 0 {
+		filename = filename[i+1:]
+	}
+	title := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename))
+	if title == "" {
+		return "未命名报告"
+	}
+	if runes := []rune(title); len(runes) > 255 {
+		title = string(runes[:255])
+	}
+	return title
+}
+
+// uploadTooLarge 返回413，提示允许上传的最大文件大小
+func (h *ReportHandler) uploadTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, models.NewAPIResponse(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("文件过大，最大允许%dMB", h.maxUploadSize>>20), gin.H{"max_upload_size": h.maxUploadSize}))
 }
 
 // GetReports 获取用户的报告列表
+// 查询参数：limit、cursor、from/to（创建时间范围，日期或RFC3339格式）、has_summary、sort（created_at/title）、order（asc/desc）
+// 以及按PDF元数据过滤的author、producer（包含匹配）和authored_from/authored_to（PDF创建时间范围）
 func (h *ReportHandler) GetReports(c *gin.Context) {
 	// 获取当前用户ID
 	userID := utils.GetUserIDFromContext(c)
@@ -59,9 +165,20 @@ func (h *ReportHandler) GetReports(c *gin.Context) {
 		return
 	}
 
+	// 解析查询参数
+	query, err := parseReportListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的查询参数", err.Error()))
+		return
+	}
+
 	// 获取报告列表
-	reports, err := h.reportService.GetReportsByUserID(userID)
+	reports, err := h.reportService.ListReports(c, userID, query)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidListQuery) {
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告列表失败", err.Error()))
 		return
 	}
@@ -69,6 +186,77 @@ func (h *ReportHandler) GetReports(c *gin.Context) {
 	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", reports))
 }
 
+// parseReportListQuery 从请求参数解析报告列表查询条件
+func parseReportListQuery(c *gin.Context) (models.ReportListQuery, error) {
+	query := models.ReportListQuery{
+		Cursor:   c.Query("cursor"),
+		SortBy:   c.Query("sort"),
+		Desc:     true,
+		Author:   c.Query("author"),
+		Producer: c.Query("producer"),
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return query, fmt.Errorf("limit必须为正整数")
+		}
+		query.Limit = limit
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		query.Desc = false
+	default:
+		return query, fmt.Errorf("order只能为asc或desc")
+	}
+
+	if v := c.Query("has_summary"); v != "" {
+		hasSummary, err := strconv.ParseBool(v)
+		if err != nil {
+			return query, fmt.Errorf("has_summary必须为true或false")
+		}
+		query.HasSummary = &hasSummary
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+		endDay bool
+	}{
+		{"from", &query.From, false}, {"to", &query.To, true},
+		{"authored_from", &query.AuthoredFrom, false}, {"authored_to", &query.AuthoredTo, true},
+	} {
+		v := c.Query(param.name)
+		if v == "" {
+			continue
+		}
+		t, err := parseTimeParam(v, param.endDay)
+		if err != nil {
+			return query, fmt.Errorf("%s必须为日期(2006-01-02)或RFC3339时间", param.name)
+		}
+		*param.target = &t
+	}
+
+	return query, nil
+}
+
+// parseTimeParam 解析日期或RFC3339时间；只给出日期的结束时间包含当天
+func parseTimeParam(v string, endDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 // GetReport 获取单个报告详情
 func (h *ReportHandler) GetReport(c *gin.Context) {
 	// 获取当前用户ID
@@ -86,18 +274,30 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 	}
 
 	// 获取报告详情
-	report, err := h.reportService.GetReportByID(reportID, userID)
+	report, role, err := h.reportService.AuthorizeReport(c, reportID, userID, authz.ActionView)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		if !reportAccessDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		}
 		return
 	}
 
-	pdfURL := "http://localhost:8080/api/v1/report/" + reportID + ".pdf"
+	detail := models.ReportDetailResponse{Report: *report, Role: string(role)}
 
-	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", models.ReportDetailResponse{
-		Report: *report,
-		PDFURL: pdfURL,
-	}))
+	// 下载链接和元数据只是附加信息，获取失败时不影响报告详情
+	pdfLink, err := h.reportService.GeneratePDFURL(c, reportID, userID)
+	if err != nil {
+		log.Printf("生成报告%s的PDF下载链接失败: %v", reportID, err)
+	} else {
+		detail.PDFURL = pdfLink.URL
+		detail.PDFURLExpiresAt = &pdfLink.ExpiresAt
+	}
+	detail.Metadata, err = h.reportService.GetReportMetadata(c, reportID)
+	if err != nil {
+		log.Printf("获取报告%s的PDF元数据失败: %v", reportID, err)
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", detail))
 }
 
 // GetReportPDF 获取报告的PDF文件
@@ -116,25 +316,60 @@ func (h *ReportHandler) GetReportPDF(c *gin.Context) {
 		return
 	}
 
+	servePDF(c, h.reportService, reportID, userID)
+}
+
+// GetSignedReportPDF 通过签名链接获取报告的PDF文件，不需要JWT令牌
+func (h *ReportHandler) GetSignedReportPDF(c *gin.Context) {
+	reportID := c.Param("report_id")
+	userID, err := h.reportService.VerifyPDFLink(reportID, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, models.NewAPIResponse(http.StatusForbidden, err.Error(), nil))
+		return
+	}
+
+	servePDF(c, h.reportService, reportID, userID)
+}
+
+// servePDF 以userID的身份返回报告的PDF文件，支持Range和条件请求
+func servePDF(c *gin.Context, reportService *services.ReportService, reportID, userID string) {
 	// 获取PDF文件流
-	pdfStream, objInfo, err := h.reportService.GetReportPDF(reportID, userID)
+	pdfStream, objInfo, filename, err := reportService.GetReportPDF(reportID, userID)
 	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, "报告不存在", nil))
+			return
+		}
+		if reportAccessDenied(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取PDF文件失败", err.Error()))
 		return
 	}
 	defer pdfStream.Close()
 
-	// 设置响应头
-	c.Header("Content-Description", "File Transfer")
+	// 设置响应头，ServeContent根据ETag和Last-Modified处理条件请求（304），根据Range返回部分内容（206）
 	c.Header("Content-Type", "application/pdf")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", objInfo.Key))
-	c.Header("Content-Length", fmt.Sprintf("%d", objInfo.Size))
+	c.Header("Content-Disposition", utils.ContentDisposition("inline", filename))
+	c.Header("Cache-Control", "private, no-cache")
+	if objInfo.ETag != "" {
+		c.Header("ETag", quoteETag(objInfo.ETag))
+	}
 
 	// 发送文件
-	c.DataFromReader(http.StatusOK, objInfo.Size, "application/pdf", pdfStream, nil)
+	http.ServeContent(c.Writer, c.Request, "", objInfo.LastModified, pdfStream)
 }
 
-// GenerateSummary 生成报告摘要
+// quoteETag 为存储返回的ETag补上HTTP要求的双引号
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// GenerateSummary 创建异步摘要任务，立即返回任务ID，通过 GET /jobs/:job_id 查询进度
+// 请求体为可选的摘要选项，省略时使用默认的风格、篇幅和语言
 func (h *ReportHandler) GenerateSummary(c *gin.Context) {
 	// 获取当前用户ID
 	userID := utils.GetUserIDFromContext(c)
@@ -150,19 +385,396 @@ func (h *ReportHandler) GenerateSummary(c *gin.Context) {
 		return
 	}
 
+	// 绑定摘要选项，请求体为空时使用默认选项
+	var opts models.SummaryOptions
+	if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+	opts, ok := summaryOptions(c, opts)
+	if !ok {
+		return
+	}
+
 	// 获取报告详情
-	report, err := h.reportService.GetReportByID(reportID, userID)
+	report, _, err := h.reportService.AuthorizeReport(c, reportID, userID, authz.ActionEdit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		if !reportAccessDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		}
+		return
+	}
+
+	if report.Content == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "报告内容为空，无法生成摘要", nil))
 		return
 	}
 
-	// 生成摘要
-	summary, err := h.reportService.GenerateSummary(report)
+	// 创建摘要任务
+	job, err := h.jobService.EnqueueSummary(c, report, userID, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "生成摘要失败", err.Error()))
+		if quotaExceeded(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrSummaryJobConflict) {
+			c.JSON(http.StatusConflict, models.NewAPIResponse(http.StatusConflict, err.Error(), job))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "创建摘要任务失败", err.Error()))
 		return
 	}
 
-	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "生成成功", gin.H{"summary": summary}))
+	c.JSON(http.StatusAccepted, models.NewAPIResponse(http.StatusAccepted, "摘要任务已创建", job))
+}
+
+// StreamSummary 通过Server-Sent Events流式返回摘要，摘要选项通过查询参数style、length、language指定
+// 事件类型：progress（长报告分块进度）、delta（摘要文本片段）、done（完整摘要）、error（生成失败）
+func (h *ReportHandler) StreamSummary(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	// 获取报告ID
+	reportID := c.Param("report_id")
+	if reportID == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的报告ID", nil))
+		return
+	}
+
+	// 绑定摘要选项
+	var opts models.SummaryOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+	opts, ok := summaryOptions(c, opts)
+	if !ok {
+		return
+	}
+
+	// 获取报告详情
+	report, _, err := h.reportService.AuthorizeReport(c, reportID, userID, authz.ActionEdit)
+	if err != nil {
+		if !reportAccessDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		}
+		return
+	}
+
+	if report.Content == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "报告内容为空，无法生成摘要", nil))
+		return
+	}
+
+	// 开始推送事件后无法再修改状态码，先预留配额
+	reservation, err := h.reportService.ReserveSummary(c, report, userID)
+	if err != nil {
+		if !quotaExceeded(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "预留配额失败", err.Error()))
+		}
+		return
+	}
+
+	// 设置SSE响应头
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	summary, err := h.reportService.StreamSummary(c.Request.Context(), report, userID, opts, reservation, services.SummaryStreamCallbacks{
+		OnProgress: func(done, total int) {
+			c.SSEvent("progress", gin.H{"done": done, "total": total})
+			c.Writer.Flush()
+		},
+		OnDelta: func(delta string) error {
+			c.SSEvent("delta", delta)
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		},
+	})
+	if err != nil {
+		c.SSEvent("error", gin.H{"message": "生成摘要失败", "error": err.Error(), "code": services.AIErrorStatus(err)})
+		c.Writer.Flush()
+		return
+	}
+
+	c.SSEvent("done", gin.H{"summary": summary})
+	c.Writer.Flush()
+}
+
+// AskQuestion 针对单个报告进行问答，返回回答及引用的页码
+func (h *ReportHandler) AskQuestion(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	// 获取报告ID
+	reportID := c.Param("report_id")
+	if reportID == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的报告ID", nil))
+		return
+	}
+
+	// 绑定请求参数
+	var askReq models.AskRequest
+	if err := c.ShouldBindJSON(&askReq); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	// 获取报告详情
+	report, _, err := h.reportService.AuthorizeReport(c, reportID, userID, authz.ActionView)
+	if err != nil {
+		if !reportAccessDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		}
+		return
+	}
+
+	// 问答
+	answer, err := h.reportService.AskQuestion(c, report, userID, askReq.Question)
+	if err != nil {
+		if quotaExceeded(c, err) || llmFailed(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "回答问题失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "回答成功", answer))
+}
+
+// GetReportPage 获取报告指定页面的文本
+func (h *ReportHandler) GetReportPage(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	// 获取报告ID和页码
+	reportID := c.Param("report_id")
+	if reportID == "" {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的报告ID", nil))
+		return
+	}
+	pageNumber, err := strconv.Atoi(c.Param("page"))
+	if err != nil || pageNumber < 1 {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的页码", nil))
+		return
+	}
+
+	// 获取报告详情
+	report, _, err := h.reportService.AuthorizeReport(c, reportID, userID, authz.ActionView)
+	if err != nil {
+		if !reportAccessDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取报告详情失败", err.Error()))
+		}
+		return
+	}
+
+	// 获取页面
+	page, err := h.reportService.GetReportPage(c, report, pageNumber)
+	if err != nil {
+		if errors.Is(err, repository.ErrPageNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, "页面不存在", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取页面失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", page))
+}
+
+// SearchReports 在当前用户的报告中全文检索
+func (h *ReportHandler) SearchReports(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	result, err := h.reportService.SearchReports(c, userID, c.Query("q"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearchQuery) {
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "检索报告失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "检索成功", result))
+}
+
+// RenameReport 修改报告标题
+func (h *ReportHandler) RenameReport(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.RenameReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	report, err := h.reportService.RenameReport(c, c.Param("report_id"), userID, req.Title)
+	if err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidTitle):
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+		default:
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "修改报告标题失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "修改成功", report))
+}
+
+// UnlockReport 使用密码解锁加密的报告并提取文本
+func (h *ReportHandler) UnlockReport(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.UnlockReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	report, err := h.reportService.UnlockReport(c, c.Param("report_id"), userID, req.Password, req.StoreDecrypted)
+	if err != nil {
+		if pdfInvalid(c, err) || reportAccessDenied(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrReportNotLocked):
+			c.JSON(http.StatusConflict, models.NewAPIResponse(http.StatusConflict, err.Error(), nil))
+		default:
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "解锁报告失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "解锁成功", report))
+}
+
+// DeleteReport 将报告移入回收站
+func (h *ReportHandler) DeleteReport(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	if err := h.reportService.DeleteReport(c, c.Param("report_id"), userID); err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "删除报告失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "已移入回收站", nil))
+}
+
+// RestoreReport 从回收站恢复报告
+func (h *ReportHandler) RestoreReport(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	if err := h.reportService.RestoreReport(c, c.Param("report_id"), userID); err != nil {
+		if errors.Is(err, repository.ErrReportNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, "回收站中不存在该报告", nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "恢复报告失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "恢复成功", nil))
+}
+
+// ListTrash 获取当前用户回收站中的报告
+func (h *ReportHandler) ListTrash(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	items, err := h.reportService.ListTrash(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取回收站失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", items))
+}
+
+// reportAccessDenied 报告不存在或无权访问时返回404，角色权限不足时返回403；err不属于这两类时返回false
+func reportAccessDenied(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, repository.ErrReportNotFound.Error(), nil))
+	case errors.Is(err, authz.ErrForbidden):
+		c.JSON(http.StatusForbidden, models.NewAPIResponse(http.StatusForbidden, authz.ErrForbidden.Error(), nil))
+	default:
+		return false
+	}
+	return true
+}
+
+// summaryOptions 校验摘要选项并补全默认值，无效时返回400
+func summaryOptions(c *gin.Context, opts models.SummaryOptions) (models.SummaryOptions, bool) {
+	opts, err := services.NormalizeSummaryOptions(opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+		return opts, false
+	}
+	return opts, true
+}
+
+// llmFailed 大模型服务调用失败时返回对应的状态码，服务端要求等待时设置Retry-After头；err不是大模型服务的错误时返回false
+func llmFailed(c *gin.Context, err error) bool {
+	status := services.AIErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		return false
+	}
+	if retryAfter := services.AIErrorRetryAfter(err); retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	c.JSON(status, models.NewAPIResponse(status, err.Error(), nil))
+	return true
 }
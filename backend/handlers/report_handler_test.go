@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// uploadRequest 构造包含size字节文件的multipart上传请求
+func uploadRequest(t *testing.T, size int) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "report.pdf")
+	require.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("x"), size))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/report/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadedFile_SpoolsLargeFilesToDisk(t *testing.T) {
+	h := &ReportHandler{maxUploadSize: 1 << 20}
+
+	for _, tc := range []struct {
+		size   int
+		onDisk bool
+	}{
+		{size: 512, onDisk: false},
+		{size: 64 << 10, onDisk: true},
+	} {
+		c, engine := gin.CreateTestContext(httptest.NewRecorder())
+		// 超过MaxMultipartMemory的文件应写入临时文件
+		engine.MaxMultipartMemory = 4 << 10
+		c.Request = uploadRequest(t, tc.size)
+
+		header, ok := h.uploadedFile(c)
+		require.True(t, ok)
+		assert.Equal(t, int64(tc.size), header.Size)
+
+		file, err := header.Open()
+		require.NoError(t, err)
+		_, isTempFile := file.(*os.File)
+		assert.Equal(t, tc.onDisk, isTempFile, "size=%d", tc.size)
+		file.Close()
+		require.NoError(t, c.Request.MultipartForm.RemoveAll())
+	}
+}
+
+func TestUploadedFile_TooLarge(t *testing.T) {
+	h := &ReportHandler{maxUploadSize: 1 << 10}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = uploadRequest(t, 4<<10)
+
+	_, ok := h.uploadedFile(c)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+}
+
+// fakeReportRepo 只实现读取PDF用到的方法
+type fakeReportRepo struct {
+	repository.IReportRepository
+	report models.Report
+}
+
+func (r *fakeReportRepo) GetByID(_ context.Context, reportID string) (*models.Report, error) {
+	if reportID != r.report.ID {
+		return nil, repository.ErrReportNotFound
+	}
+	report := r.report
+	return &report, nil
+}
+
+// pdfTestRouter 返回以u1身份提供报告r1的PDF的路由，PDF内容为1000字节
+func pdfTestRouter(t *testing.T) (*gin.Engine, []byte, string) {
+	store := storage.NewMemoryStore()
+	content := append([]byte("%PDF-1.4\n"), bytes.Repeat([]byte("x"), 991)...)
+	info, err := store.Put(context.Background(), "r1.pdf", bytes.NewReader(content), int64(len(content)), "application/pdf")
+	require.NoError(t, err)
+
+	reportRepo := &fakeReportRepo{report: models.Report{ID: "r1", UserID: "u1", Title: "年报", PDFPath: "reports/r1.pdf"}}
+	reportService := services.NewReportService(reportRepo, nil, nil, nil, store, nil, "")
+	router := gin.New()
+	handler := func(c *gin.Context) { servePDF(c, reportService, c.Param("report_id"), "u1") }
+	router.GET("/pdf/:report_id", handler)
+	router.HEAD("/pdf/:report_id", handler)
+	return router, content, `"` + info.ETag + `"`
+}
+
+func TestServePDF(t *testing.T) {
+	router, content, etag := pdfTestRouter(t)
+	serve := func(method string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/pdf/r1", nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	full := serve(http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, full.Code)
+	assert.Equal(t, content, full.Body.Bytes())
+	assert.Equal(t, etag, full.Header().Get("ETag"))
+	assert.Equal(t, "bytes", full.Header().Get("Accept-Ranges"))
+
+	partial := serve(http.MethodGet, http.Header{"Range": {"bytes=0-99"}})
+	assert.Equal(t, http.StatusPartialContent, partial.Code)
+	assert.Equal(t, "bytes 0-99/1000", partial.Header().Get("Content-Range"))
+	assert.Equal(t, content[:100], partial.Body.Bytes())
+
+	notModified := serve(http.MethodGet, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.Bytes())
+
+	head := serve(http.MethodHead, nil)
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Equal(t, "1000", head.Header().Get("Content-Length"))
+	assert.Equal(t, "application/pdf", head.Header().Get("Content-Type"))
+	assert.Empty(t, head.Body.Bytes())
+
+	unsatisfiable := serve(http.MethodGet, http.Header{"Range": {"bytes=5000-"}})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, unsatisfiable.Code)
+	assert.Equal(t, "bytes */1000", unsatisfiable.Header().Get("Content-Range"))
+
+	missing := httptest.NewRecorder()
+	router.ServeHTTP(missing, httptest.NewRequest(http.MethodGet, "/pdf/r2", nil))
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+}
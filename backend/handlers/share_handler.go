@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// sharePasswordHeader 通过分享链接访问时携带访问密码的请求头
+const sharePasswordHeader = "X-Share-Password"
+
+// ShareHandler 处理报告分享相关的请求
+type ShareHandler struct {
+	shareService  *services.ShareService
+	reportService *services.ReportService
+}
+
+// NewShareHandler 创建新的分享处理器
+func NewShareHandler(shareService *services.ShareService, reportService *services.ReportService) *ShareHandler {
+	return &ShareHandler{shareService: shareService, reportService: reportService}
+}
+
+// CreateShare 为报告创建分享链接
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	share, err := h.shareService.CreateShare(c, c.Param("report_id"), userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidShareRequest):
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+		case reportAccessDenied(c, err):
+		default:
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "创建分享链接失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewAPIResponse(http.StatusCreated, "创建成功", share))
+}
+
+// ListShares 列出报告的分享链接
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	shares, err := h.shareService.ListShares(c, c.Param("report_id"), userID)
+	if err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取分享链接失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", shares))
+}
+
+// RevokeShare 撤销分享链接
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	if err := h.shareService.RevokeShare(c, c.Param("report_id"), c.Param("share_id"), userID); err != nil {
+		if reportAccessDenied(c, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrShareNotFound) {
+			c.JSON(http.StatusNotFound, models.NewAPIResponse(http.StatusNotFound, err.Error(), nil))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "撤销分享链接失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "已撤销", nil))
+}
+
+// GetSharedReport 通过分享链接查看报告，不需要登录
+func (h *ShareHandler) GetSharedReport(c *gin.Context) {
+	report, err := h.shareService.GetSharedReport(c, c.Param("token"), c.GetHeader(sharePasswordHeader), c.ClientIP())
+	if err != nil {
+		if !shareDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取分享报告失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", report))
+}
+
+// GetSharedReportPDF 通过分享链接下载报告PDF，需要分享时允许下载
+func (h *ShareHandler) GetSharedReportPDF(c *gin.Context) {
+	share, err := h.shareService.AuthorizeDownload(c, c.Param("token"), c.GetHeader(sharePasswordHeader), c.ClientIP(), c.Request.URL.Query())
+	if err != nil {
+		if !shareDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取PDF文件失败", err.Error()))
+		}
+		return
+	}
+
+	// 预览器分段读取时会发出多个Range请求，只有从头开始的GET请求计为一次下载
+	if rangeHeader := c.GetHeader("Range"); c.Request.Method == http.MethodGet && (rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")) {
+		h.shareService.RecordDownload(c, share)
+	}
+
+	servePDF(c, h.reportService, share.ReportID, share.UserID)
+}
+
+// shareDenied 分享链接无法访问时返回对应的状态码，err不是访问被拒绝的错误时返回false
+func shareDenied(c *gin.Context, err error) bool {
+	var throttled *services.ShareThrottledError
+	if errors.As(err, &throttled) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, models.NewAPIResponse(http.StatusTooManyRequests, err.Error(), nil))
+		return true
+	}
+
+	status := 0
+	switch {
+	case errors.Is(err, repository.ErrShareNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrShareExpired):
+		status = http.StatusGone
+	case errors.Is(err, services.ErrSharePasswordRequired), errors.Is(err, services.ErrSharePasswordInvalid):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrShareDownloadNotAllowed), errors.Is(err, services.ErrPDFLinkInvalid), errors.Is(err, services.ErrPDFLinkExpired):
+		status = http.StatusForbidden
+	default:
+		return false
+	}
+	c.JSON(status, models.NewAPIResponse(status, err.Error(), nil))
+	return true
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// TeamHandler 处理团队相关的请求
+type TeamHandler struct {
+	teamService *services.TeamService
+}
+
+// NewTeamHandler 创建新的团队处理器
+func NewTeamHandler(teamService *services.TeamService) *TeamHandler {
+	return &TeamHandler{teamService: teamService}
+}
+
+// CreateTeam 创建团队
+func (h *TeamHandler) CreateTeam(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	team, err := h.teamService.CreateTeam(c, userID, req.Name)
+	if err != nil {
+		if !teamDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "创建团队失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewAPIResponse(http.StatusCreated, "创建成功", team))
+}
+
+// ListTeams 列出当前用户所在的团队
+func (h *TeamHandler) ListTeams(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	teams, err := h.teamService.ListTeams(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取团队列表失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", teams))
+}
+
+// ListMembers 列出团队成员
+func (h *TeamHandler) ListMembers(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	members, err := h.teamService.ListMembers(c, c.Param("team_id"), userID)
+	if err != nil {
+		if !teamDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取团队成员失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", members))
+}
+
+// AddMember 添加团队成员或修改成员角色
+func (h *TeamHandler) AddMember(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	var req models.AddTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, "无效的请求参数", err.Error()))
+		return
+	}
+
+	members, err := h.teamService.AddMember(c, c.Param("team_id"), userID, req)
+	if err != nil {
+		if !teamDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "添加团队成员失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "添加成功", members))
+}
+
+// RemoveMember 移除团队成员或退出团队
+func (h *TeamHandler) RemoveMember(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	if err := h.teamService.RemoveMember(c, c.Param("team_id"), userID, c.Param("user_id")); err != nil {
+		if !teamDenied(c, err) {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "移除团队成员失败", err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "已移除", nil))
+}
+
+// teamDenied 团队操作被拒绝或参数无效时返回对应的状态码，其他错误返回false
+func teamDenied(c *gin.Context, err error) bool {
+	status := 0
+	switch {
+	case errors.Is(err, services.ErrInvalidTeamRequest):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrNotTeamAdmin):
+		status = http.StatusForbidden
+	case errors.Is(err, repository.ErrTeamNotFound),
+		errors.Is(err, repository.ErrTeamMemberNotFound),
+		errors.Is(err, repository.ErrUserNotFound):
+		status = http.StatusNotFound
+	default:
+		return false
+	}
+	c.JSON(status, models.NewAPIResponse(status, err.Error(), nil))
+	return true
+}
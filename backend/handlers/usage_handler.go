@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// UsageHandler 处理大模型用量统计相关的请求
+type UsageHandler struct {
+	usageService *services.UsageService
+}
+
+// NewUsageHandler 创建新的用量统计处理器
+func NewUsageHandler(usageService *services.UsageService) *UsageHandler {
+	return &UsageHandler{usageService: usageService}
+}
+
+// GetUsage 获取当前用户按天和按报告汇总的大模型用量
+func (h *UsageHandler) GetUsage(c *gin.Context) {
+	// 获取当前用户ID
+	userID := utils.GetUserIDFromContext(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, models.NewAPIResponse(http.StatusUnauthorized, "未授权的访问", nil))
+		return
+	}
+
+	from, to, ok := usageRange(c)
+	if !ok {
+		return
+	}
+	usage, err := h.usageService.UserUsage(c, userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取用量统计失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", usage))
+}
+
+// GetAllUsage 获取全部用户按天和按用户汇总的大模型用量，仅管理员可用
+func (h *UsageHandler) GetAllUsage(c *gin.Context) {
+	from, to, ok := usageRange(c)
+	if !ok {
+		return
+	}
+	usage, err := h.usageService.AllUsage(c, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取用量统计失败", err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewAPIResponse(http.StatusOK, "获取成功", usage))
+}
+
+// usageRange 解析查询参数中的统计区间，无效时返回400
+func usageRange(c *gin.Context) (time.Time, time.Time, bool) {
+	from, to, err := services.ParseUsageRange(c.Query("from"), c.Query("to"), time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUsageRange) {
+			c.JSON(http.StatusBadRequest, models.NewAPIResponse(http.StatusBadRequest, err.Error(), nil))
+		} else {
+			c.JSON(http.StatusInternalServerError, models.NewAPIResponse(http.StatusInternalServerError, "获取用量统计失败", err.Error()))
+		}
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 大模型服务调用错误的类型，通过errors.Is判断
+var (
+	// ErrRateLimited 请求过于频繁
+	ErrRateLimited = errors.New("大模型服务请求过于频繁")
+	// ErrInsufficientBalance 大模型服务账户余额不足，如DeepSeek返回的402
+	ErrInsufficientBalance = errors.New("大模型服务账户余额不足")
+	// ErrContextTooLong 输入超过模型的上下文长度
+	ErrContextTooLong = errors.New("输入超过大模型的上下文长度")
+	// ErrAuthFailed 大模型服务鉴权失败，通常是API密钥无效
+	ErrAuthFailed = errors.New("大模型服务鉴权失败")
+	// ErrUnavailable 大模型服务暂时不可用
+	ErrUnavailable = errors.New("大模型服务暂时不可用")
+	// ErrCircuitOpen 连续失败次数过多，熔断期间不再发送请求
+	ErrCircuitOpen = fmt.Errorf("%w: 已熔断", ErrUnavailable)
+)
+
+// APIError 大模型服务返回的错误响应
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter 服务端通过Retry-After要求的等待时间，未指定时为0
+	RetryAfter time.Duration
+	// Kind 错误类型，如ErrRateLimited，无法归类时为nil
+	Kind error
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	if e.Kind != nil {
+		return fmt.Sprintf("%v: API请求失败，状态码: %d，响应: %s", e.Kind, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("API请求失败，状态码: %d，响应: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap 返回错误类型，使errors.Is(err, ErrRateLimited)等判断成立
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// contextTooLongMarkers 各服务在上下文超长时错误信息中包含的关键字
+var contextTooLongMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context length",
+	"too many tokens",
+	"prompt is too long",
+}
+
+// newAPIError 根据状态码和响应内容归类错误
+func newAPIError(statusCode int, header http.Header, body string) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: body, RetryAfter: parseRetryAfter(header.Get("Retry-After"), time.Now())}
+	lower := strings.ToLower(body)
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRateLimited
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		apiErr.Kind = ErrAuthFailed
+	case statusCode == http.StatusPaymentRequired:
+		apiErr.Kind = ErrInsufficientBalance
+	case statusCode == http.StatusRequestEntityTooLarge:
+		apiErr.Kind = ErrContextTooLong
+	case statusCode >= 400 && statusCode < 500 && containsAny(lower, contextTooLongMarkers):
+		apiErr.Kind = ErrContextTooLong
+	case statusCode == http.StatusInternalServerError, statusCode == http.StatusBadGateway,
+		statusCode == http.StatusServiceUnavailable, statusCode == http.StatusGatewayTimeout:
+		apiErr.Kind = ErrUnavailable
+	}
+	return apiErr
+}
+
+// requestError 包装发送请求时的网络错误，调用方取消或超时的错误保持原样
+func requestError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: 发送请求失败: %w", ErrUnavailable, err)
+}
+
+// parseRetryAfter 解析Retry-After头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// RetryAfter 返回错误链中服务端要求的等待时间，没有时返回0
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// retryable 判断错误是否值得重试：限流和服务暂时不可用可以重试，熔断、鉴权失败、余额不足和上下文超长不重试
+func retryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable)
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// fakeEmbeddingDim 假实现生成的向量维度
+const fakeEmbeddingDim = 16
+
+// FakeProvider 进程内的确定性假实现，不访问网络，用于测试和本地开发
+type FakeProvider struct {
+	// Reply 根据请求生成回复，为nil时回显最后一条消息的开头
+	Reply func(req ChatRequest) (string, error)
+}
+
+// NewFakeProvider 创建假实现
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+// Name 实现名称
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Chat 返回确定性的回复
+func (p *FakeProvider) Chat(_ context.Context, req ChatRequest) (*ChatResponse, error) {
+	content, err := p.reply(req)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Content: content, Model: ProviderFake, Usage: fakeUsage(req, content)}, nil
+}
+
+// ChatStream 将回复按每段8个字符拆分后依次回调
+func (p *FakeProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	content, err := p.reply(req)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(content)
+	for start := 0; start < len(runes); start += 8 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(start+8, len(runes))
+		if err := onDelta(string(runes[start:end])); err != nil {
+			return nil, err
+		}
+	}
+	return &ChatResponse{Content: content, Model: ProviderFake, Usage: fakeUsage(req, content)}, nil
+}
+
+// Embed 根据文本的SHA-256生成归一化向量，相同文本得到相同向量
+func (p *FakeProvider) Embed(_ context.Context, texts []string) (*EmbedResponse, error) {
+	embeddings := make([][]float32, len(texts))
+	tokens := 0
+	for i, text := range texts {
+		sum := sha256.Sum256([]byte(text))
+		vector := make([]float32, fakeEmbeddingDim)
+		var norm float64
+		for j := range vector {
+			value := float64(int16(binary.BigEndian.Uint16(sum[j*2:])))
+			vector[j] = float32(value)
+			norm += value * value
+		}
+		if norm > 0 {
+			for j := range vector {
+				vector[j] = float32(float64(vector[j]) / math.Sqrt(norm))
+			}
+		}
+		embeddings[i] = vector
+		tokens += utils.EstimateTokens(text)
+	}
+	return &EmbedResponse{Embeddings: embeddings, Model: ProviderFake, Usage: Usage{PromptTokens: tokens, TotalTokens: tokens}}, nil
+}
+
+func (p *FakeProvider) reply(req ChatRequest) (string, error) {
+	if p.Reply != nil {
+		return p.Reply(req)
+	}
+	if len(req.Messages) == 0 {
+		return "", ErrEmptyResponse
+	}
+	prompt := []rune(strings.TrimSpace(req.Messages[len(req.Messages)-1].Content))
+	if len(prompt) > 50 {
+		prompt = prompt[:50]
+	}
+	return fmt.Sprintf("[fake] %s", string(prompt)), nil
+}
+
+// fakeUsage 按估算的token数计算用量
+func fakeUsage(req ChatRequest, content string) Usage {
+	prompt := 0
+	for _, message := range req.Messages {
+		prompt += utils.EstimateTokens(message.Content)
+	}
+	completion := utils.EstimateTokens(content)
+	return Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProvider_Deterministic(t *testing.T) {
+	provider := NewFakeProvider()
+	req := ChatRequest{Messages: []Message{{Role: RoleUser, Content: "请为以下报告生成摘要"}}}
+
+	var streamed string
+	resp, err := provider.ChatStream(context.Background(), req, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	require.NoError(t, err)
+	chat, err := provider.Chat(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, chat.Content, resp.Content)
+	assert.Equal(t, resp.Content, streamed)
+
+	first, err := provider.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	second, err := provider.Embed(context.Background(), []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, first.Embeddings[0], second.Embeddings[0])
+	assert.NotEqual(t, first.Embeddings[0], first.Embeddings[1])
+}
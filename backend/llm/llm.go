@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrEmptyResponse 模型返回的响应没有内容
+var ErrEmptyResponse = errors.New("API返回的响应没有内容")
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatRequest 对话请求，MaxTokens和Temperature为0时使用服务端默认值
+type ChatRequest struct {
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+}
+
+// Usage token用量，服务端未返回时为0
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// ChatResponse 对话响应
+type ChatResponse struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// EmbedResponse 向量化响应，Embeddings与输入文本一一对应
+type EmbedResponse struct {
+	Embeddings [][]float32
+	Model      string
+	Usage      Usage
+}
+
+// Provider 大模型服务接口，屏蔽OpenAI兼容接口、本地Ollama和测试用假实现等具体实现
+type Provider interface {
+	// Name 实现名称，如openai、ollama或fake
+	Name() string
+	// Chat 发送对话请求并返回完整回复
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// ChatStream 以流式方式发送对话请求，每收到一段文本调用一次onDelta，onDelta返回错误时中止；返回完整回复
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error)
+	// Embed 将文本转换为向量
+	Embed(ctx context.Context, texts []string) (*EmbedResponse, error)
+}
+
+// Config 大模型服务配置
+type Config struct {
+	Provider string // openai、ollama 或 fake
+
+	// BaseURL 接口地址；OpenAI兼容接口需包含版本路径，如 https://api.deepseek.com/v1
+	BaseURL        string
+	APIKey         string
+	Model          string
+	EmbeddingModel string
+	// Timeout 非流式请求的超时时间，流式请求的超时由调用方的ctx控制
+	Timeout time.Duration
+}
+
+// 支持的大模型服务实现
+const (
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
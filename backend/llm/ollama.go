@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider 本地Ollama服务的实现，适用于无法访问外部接口的离线环境
+type OllamaProvider struct {
+	config       Config
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+// ollamaChatResponse 对话响应；流式时每行一个对象，最后一行done为true并带有token计数
+type ollamaChatResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+func (r *ollamaChatResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
+
+// NewOllamaProvider 创建Ollama客户端，BaseURL为服务根地址，如 http://localhost:11434
+func NewOllamaProvider(config Config) *OllamaProvider {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &OllamaProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		// 流式响应持续时间较长，超时由调用方的ctx控制
+		streamClient: &http.Client{},
+	}
+}
+
+// Name 实现名称
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+// Chat 调用 /api/chat 接口并返回模型回复
+func (p *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := p.do(ctx, p.httpClient, "/api/chat", p.chatRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if response.Message.Content == "" {
+		return nil, ErrEmptyResponse
+	}
+	return &ChatResponse{Content: response.Message.Content, Model: response.Model, Usage: response.usage()}, nil
+}
+
+// ChatStream 以流式方式调用 /api/chat 接口，响应为逐行的JSON对象
+func (p *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	resp, err := p.do(ctx, p.streamClient, "/api/chat", p.chatRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ChatResponse{Model: p.config.Model}
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("解析流式响应失败: %w", err)
+		}
+		if chunk.Error != "" {
+			// 流式响应开始后服务端出错，状态码已经是200
+			return nil, fmt.Errorf("%w: %s", ErrUnavailable, chunk.Error)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Message.Content != "" {
+			full.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Done {
+			result.Usage = chunk.usage()
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	result.Content = full.String()
+	return result, nil
+}
+
+// Embed 调用 /api/embed 接口将文本转换为向量
+func (p *OllamaProvider) Embed(ctx context.Context, texts []string) (*EmbedResponse, error) {
+	resp, err := p.do(ctx, p.httpClient, "/api/embed", ollamaEmbedRequest{Model: p.config.EmbeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("向量数量与输入不一致: 输入%d条，返回%d条", len(texts), len(response.Embeddings))
+	}
+	return &EmbedResponse{
+		Embeddings: response.Embeddings,
+		Model:      response.Model,
+		Usage:      Usage{PromptTokens: response.PromptEvalCount, TotalTokens: response.PromptEvalCount},
+	}, nil
+}
+
+func (p *OllamaProvider) chatRequest(req ChatRequest, stream bool) ollamaChatRequest {
+	request := ollamaChatRequest{Model: p.config.Model, Messages: req.Messages, Stream: stream}
+	if req.MaxTokens > 0 || req.Temperature > 0 {
+		request.Options = &ollamaOptions{Temperature: req.Temperature, NumPredict: req.MaxTokens}
+	}
+	return request
+}
+
+// do 发送JSON请求，状态码不是200时读取错误信息并返回错误
+func (p *OllamaProvider) do(ctx context.Context, client *http.Client, path string, body any) (*http.Response, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+path, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+			return nil, newAPIError(resp.StatusCode, resp.Header, apiErr.Error)
+		}
+		return nil, newAPIError(resp.StatusCode, resp.Header, string(respBody))
+	}
+	return resp, nil
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOllamaProvider_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		var req ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.True(t, req.Stream)
+		assert.Equal(t, 100, req.Options.NumPredict)
+		fmt.Fprintln(w, `{"model":"qwen2.5","message":{"role":"assistant","content":"净值"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen2.5","message":{"role":"assistant","content":"上涨"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"qwen2.5","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":2}`)
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(Config{BaseURL: server.URL, Model: "qwen2.5"})
+
+	var deltas []string
+	resp, err := provider.ChatStream(context.Background(), ChatRequest{Messages: []Message{{Role: RoleUser, Content: "内容"}}, MaxTokens: 100}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "净值上涨", resp.Content)
+	assert.Equal(t, []string{"净值", "上涨"}, deltas)
+	assert.Equal(t, Usage{PromptTokens: 12, CompletionTokens: 2, TotalTokens: 14}, resp.Usage)
+}
+
+func TestOllamaProvider_ChatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"qwen2.5\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(Config{BaseURL: server.URL, Model: "qwen2.5"})
+	_, err := provider.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: RoleUser, Content: "内容"}}})
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,254 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// OpenAIProvider OpenAI兼容接口的实现，适用于DeepSeek、OpenAI、vLLM等提供 /chat/completions 的服务
+type OpenAIProvider struct {
+	config       Config
+	httpClient   *http.Client
+	streamClient *http.Client
+}
+
+// openAIChatRequest 对话补全请求结构
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []Message            `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   float64              `json:"temperature,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *openAIUsage) usage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// openAIChatResponse 对话补全响应结构
+type openAIChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// openAIStreamChunk 流式响应中的单个数据块，开启include_usage时最后一块只包含用量
+type openAIStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbedResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// NewOpenAIProvider 创建OpenAI兼容接口的客户端
+func NewOpenAIProvider(config Config) *OpenAIProvider {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &OpenAIProvider{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		// 流式响应持续时间较长，超时由调用方的ctx控制
+		streamClient: &http.Client{},
+	}
+}
+
+// Name 实现名称
+func (p *OpenAIProvider) Name() string {
+	return ProviderOpenAI
+}
+
+// Chat 发送对话请求并返回模型回复
+func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var response openAIChatResponse
+	if err := p.post(ctx, "/chat/completions", p.chatRequest(req, false), &response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, ErrEmptyResponse
+	}
+	return &ChatResponse{
+		Content: response.Choices[0].Message.Content,
+		Model:   response.Model,
+		Usage:   response.Usage.usage(),
+	}, nil
+}
+
+// ChatStream 以 stream: true 发送对话请求，解析 "data:" 分块格式的响应
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	httpReq, err := p.newRequest(ctx, "/chat/completions", p.chatRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.streamClient.Do(httpReq)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, newAPIError(resp.StatusCode, resp.Header, string(respBody))
+	}
+
+	result := &ChatResponse{Model: p.config.Model}
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// 跳过空行和注释行（如 ": keep-alive"）
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("解析流式响应失败: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			full.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// 部分兼容实现不发送 [DONE]，以连接关闭作为结束
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取流式响应失败: %w", err)
+	}
+
+	result.Content = full.String()
+	return result, nil
+}
+
+// Embed 调用 /embeddings 接口将文本转换为向量
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) (*EmbedResponse, error) {
+	var response openAIEmbedResponse
+	if err := p.post(ctx, "/embeddings", openAIEmbedRequest{Model: p.config.EmbeddingModel, Input: texts}, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Data) != len(texts) {
+		return nil, fmt.Errorf("向量数量与输入不一致: 输入%d条，返回%d条", len(texts), len(response.Data))
+	}
+
+	// 按index排序，保证与输入顺序一致
+	sort.Slice(response.Data, func(i, j int) bool { return response.Data[i].Index < response.Data[j].Index })
+	embeddings := make([][]float32, len(response.Data))
+	for i, item := range response.Data {
+		embeddings[i] = item.Embedding
+	}
+	return &EmbedResponse{Embeddings: embeddings, Model: response.Model, Usage: response.Usage.usage()}, nil
+}
+
+func (p *OpenAIProvider) chatRequest(req ChatRequest, stream bool) openAIChatRequest {
+	request := openAIChatRequest{
+		Model:       p.config.Model,
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+	if stream {
+		request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return request
+}
+
+// post 发送JSON请求并解析JSON响应
+func (p *OpenAIProvider) post(ctx context.Context, path string, body any, out any) error {
+	req, err := p.newRequest(ctx, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return requestError(ctx, fmt.Errorf("读取响应失败: %w", err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp.StatusCode, resp.Header, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// newRequest 构建带鉴权头的JSON请求
+func (p *OpenAIProvider) newRequest(ctx context.Context, path string, body any) (*http.Request, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+path, bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	return req, nil
+}
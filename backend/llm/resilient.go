@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ResilienceConfig 重试和熔断配置
+type ResilienceConfig struct {
+	// MaxAttempts 每次调用的最大尝试次数，包括第一次
+	MaxAttempts int
+	// BaseDelay 第一次重试前的基础等待时间，之后每次翻倍
+	BaseDelay time.Duration
+	// MaxDelay 单次等待的上限；服务端要求的Retry-After超过该值时不再重试
+	MaxDelay time.Duration
+	// FailureThreshold 连续失败多少次后熔断
+	FailureThreshold int
+	// Cooldown 熔断持续时间，结束后放行一个探测请求
+	Cooldown time.Duration
+}
+
+// ResilientProvider 为大模型服务增加重试和熔断，限流和服务不可用时按指数退避加随机抖动重试
+type ResilientProvider struct {
+	provider Provider
+	config   ResilienceConfig
+	breaker  *circuitBreaker
+	// sleep 等待指定时间，ctx取消时提前返回，测试中可替换
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewResilientProvider 包装大模型服务，每个被包装的服务有独立的熔断器
+func NewResilientProvider(provider Provider, config ResilienceConfig) *ResilientProvider {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 500 * time.Millisecond
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	return &ResilientProvider{
+		provider: provider,
+		config:   config,
+		breaker:  &circuitBreaker{threshold: config.FailureThreshold, cooldown: config.Cooldown, now: time.Now},
+		sleep:    sleepContext,
+	}
+}
+
+// Name 被包装服务的名称
+func (p *ResilientProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Chat 发送对话请求，失败时按配置重试
+func (p *ResilientProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var resp *ChatResponse
+	err := p.do(ctx, func() (err error) {
+		resp, err = p.provider.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// ChatStream 发送流式对话请求；已经输出部分文本后失败时不再重试，避免调用方收到重复内容
+func (p *ResilientProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	var resp *ChatResponse
+	streamed := false
+	err := p.do(ctx, func() (err error) {
+		resp, err = p.provider.ChatStream(ctx, req, func(delta string) error {
+			streamed = true
+			return onDelta(delta)
+		})
+		if err != nil && streamed {
+			return permanent{err}
+		}
+		return err
+	})
+	return resp, err
+}
+
+// Embed 将文本转换为向量，失败时按配置重试
+func (p *ResilientProvider) Embed(ctx context.Context, texts []string) (*EmbedResponse, error) {
+	var resp *EmbedResponse
+	err := p.do(ctx, func() (err error) {
+		resp, err = p.provider.Embed(ctx, texts)
+		return err
+	})
+	return resp, err
+}
+
+// do 执行一次调用，熔断时直接失败，可重试的错误按退避时间等待后重试
+func (p *ResilientProvider) do(ctx context.Context, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if !p.breaker.allow() {
+			if err != nil {
+				return fmt.Errorf("%w（上次错误: %v）", ErrCircuitOpen, err)
+			}
+			return ErrCircuitOpen
+		}
+
+		err = call()
+		p.breaker.record(err)
+		var stop permanent
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if err == nil || !retryable(err) || attempt >= p.config.MaxAttempts {
+			return err
+		}
+
+		delay, ok := p.backoff(attempt, RetryAfter(err))
+		if !ok {
+			return err
+		}
+		if sleepErr := p.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// backoff 计算第attempt次失败后的等待时间：服务端指定了Retry-After时按其等待，否则为指数退避加全随机抖动
+// Retry-After超过MaxDelay时返回false，不再重试
+func (p *ResilientProvider) backoff(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.config.MaxDelay
+	}
+	delay := p.config.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		delay = min(p.config.BaseDelay<<shift, p.config.MaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1), true
+}
+
+// permanent 标记不应重试的错误
+type permanent struct {
+	err error
+}
+
+func (e permanent) Error() string { return e.err.Error() }
+
+func (e permanent) Unwrap() error { return e.err }
+
+// circuitBreaker 熔断器：连续threshold次服务不可用后熔断cooldown时长，之后放行一个探测请求，成功则恢复
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow 判断是否放行请求，熔断结束后同一时间只放行一个探测请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record 记录调用结果；只有服务不可用才计为失败，限流、鉴权失败等说明服务仍在响应
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		// 调用方取消不能说明服务状态
+		return
+	case !errors.Is(err, ErrUnavailable):
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// sleepContext 等待d时长，ctx取消时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
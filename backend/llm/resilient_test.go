@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider 按顺序返回预设错误的假实现，错误用完后返回成功
+type scriptedProvider struct {
+	FakeProvider
+	errs  []error
+	calls int
+}
+
+func (p *scriptedProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return p.FakeProvider.Chat(ctx, req)
+}
+
+func newTestResilientProvider(provider Provider, config ResilienceConfig) (*ResilientProvider, *[]time.Duration) {
+	p := NewResilientProvider(provider, config)
+	var sleeps []time.Duration
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return p, &sleeps
+}
+
+var testChatRequest = ChatRequest{Messages: []Message{{Role: RoleUser, Content: "内容"}}}
+
+func TestResilientProvider_Retry(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "2")
+	upstream := &scriptedProvider{errs: []error{
+		newAPIError(http.StatusServiceUnavailable, http.Header{}, "overloaded"),
+		newAPIError(http.StatusTooManyRequests, header, "rate limited"),
+	}}
+	p, sleeps := newTestResilientProvider(upstream, ResilienceConfig{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond})
+
+	resp, err := p.Chat(context.Background(), testChatRequest)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.Content)
+	assert.Equal(t, 3, upstream.calls)
+	require.Len(t, *sleeps, 2)
+	assert.LessOrEqual(t, (*sleeps)[0], 100*time.Millisecond)
+	assert.Equal(t, 2*time.Second, (*sleeps)[1], "按Retry-After等待")
+}
+
+func TestResilientProvider_NoRetry(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "3600")
+	cases := []struct {
+		name string
+		err  error
+		kind error
+	}{
+		{"鉴权失败", newAPIError(http.StatusUnauthorized, http.Header{}, "invalid api key"), ErrAuthFailed},
+		{"余额不足", newAPIError(http.StatusPaymentRequired, http.Header{}, `{"error":{"message":"Insufficient Balance"}}`), ErrInsufficientBalance},
+		{"上下文超长", newAPIError(http.StatusBadRequest, http.Header{}, `{"error":{"code":"context_length_exceeded"}}`), ErrContextTooLong},
+		{"Retry-After过长", newAPIError(http.StatusTooManyRequests, header, "quota"), ErrRateLimited},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := &scriptedProvider{errs: []error{tc.err}}
+			p, sleeps := newTestResilientProvider(upstream, ResilienceConfig{})
+
+			_, err := p.Chat(context.Background(), testChatRequest)
+			assert.ErrorIs(t, err, tc.kind)
+			assert.Equal(t, 1, upstream.calls)
+			assert.Empty(t, *sleeps)
+		})
+	}
+}
+
+func TestResilientProvider_CircuitBreaker(t *testing.T) {
+	unavailable := newAPIError(http.StatusBadGateway, http.Header{}, "bad gateway")
+	upstream := &scriptedProvider{errs: []error{unavailable, unavailable, unavailable}}
+	p, _ := newTestResilientProvider(upstream, ResilienceConfig{MaxAttempts: 1, FailureThreshold: 2, Cooldown: time.Minute})
+	now := time.Now()
+	p.breaker.now = func() time.Time { return now }
+
+	for range 2 {
+		_, err := p.Chat(context.Background(), testChatRequest)
+		assert.ErrorIs(t, err, ErrUnavailable)
+	}
+
+	// 熔断期间直接失败，不调用上游
+	_, err := p.Chat(context.Background(), testChatRequest)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, upstream.calls)
+
+	// 熔断结束后放行探测请求，失败则重新熔断
+	now = now.Add(time.Minute)
+	_, err = p.Chat(context.Background(), testChatRequest)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+	_, err = p.Chat(context.Background(), testChatRequest)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// 探测成功后恢复
+	now = now.Add(time.Minute)
+	_, err = p.Chat(context.Background(), testChatRequest)
+	require.NoError(t, err)
+	_, err = p.Chat(context.Background(), testChatRequest)
+	require.NoError(t, err)
+}
+
+func TestResilientProvider_StreamNotRetriedAfterOutput(t *testing.T) {
+	calls := 0
+	upstream := &FakeProvider{Reply: func(ChatRequest) (string, error) {
+		calls++
+		return "部分内容", nil
+	}}
+	p, _ := newTestResilientProvider(upstream, ResilienceConfig{})
+
+	streamErr := errors.Join(ErrUnavailable, errors.New("connection reset"))
+	_, err := p.ChatStream(context.Background(), testChatRequest, func(string) error { return streamErr })
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 1, calls)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 4, 20, 9, 30, 0, 0, time.UTC)
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("", now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+}
@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// UsageScope 调用大模型时记录用量所归属的用户、报告和业务操作
+type UsageScope struct {
+	UserID    string
+	ReportID  string
+	Operation string
+	// RequestID 用户发起的一次AI请求的ID，一次请求可能包含多次大模型调用，用于关联同一请求的多次调用
+	RequestID string
+}
+
+type usageScopeKey struct{}
+
+// WithUsageScope 返回携带用量归属的ctx，字段为空时沿用ctx中已有的值
+func WithUsageScope(ctx context.Context, scope UsageScope) context.Context {
+	current := UsageScopeFrom(ctx)
+	if scope.UserID == "" {
+		scope.UserID = current.UserID
+	}
+	if scope.ReportID == "" {
+		scope.ReportID = current.ReportID
+	}
+	if scope.Operation == "" {
+		scope.Operation = current.Operation
+	}
+	if scope.RequestID == "" {
+		scope.RequestID = current.RequestID
+	}
+	return context.WithValue(ctx, usageScopeKey{}, scope)
+}
+
+// UsageScopeFrom 读取ctx中的用量归属，未设置时返回零值
+func UsageScopeFrom(ctx context.Context) UsageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(UsageScope)
+	return scope
+}
+
+// UsageTally 累计同一次请求中全部调用的token用量，可以在并发的调用间共享
+type UsageTally struct {
+	tokens atomic.Int64
+}
+
+type usageTallyKey struct{}
+
+// WithUsageTally 返回累计用量的ctx，之后经过MeteredProvider的调用都会计入返回的UsageTally
+func WithUsageTally(ctx context.Context) (context.Context, *UsageTally) {
+	tally := &UsageTally{}
+	return context.WithValue(ctx, usageTallyKey{}, tally), tally
+}
+
+// TotalTokens 已累计的token数，包括失败调用返回的用量
+func (t *UsageTally) TotalTokens() int64 {
+	return t.tokens.Load()
+}
+
+// UsageRecord 一次大模型调用的用量
+type UsageRecord struct {
+	UsageScope
+	Provider string
+	Model    string
+	Usage    Usage
+	// Estimated 服务端未返回用量时为true，token数为按文本长度估算的值
+	Estimated bool
+	Latency   time.Duration
+	// Err 调用失败时的错误，失败的调用同样记录
+	Err       error
+	CreatedAt time.Time
+}
+
+// UsageRecorder 保存大模型调用用量
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, record UsageRecord)
+}
+
+// MeteredProvider 记录每次大模型调用的token用量和耗时
+type MeteredProvider struct {
+	provider Provider
+	recorder UsageRecorder
+	model    string
+}
+
+// NewMeteredProvider 包装大模型服务，model为服务端未返回模型名称时记录的默认值
+func NewMeteredProvider(provider Provider, recorder UsageRecorder, model string) *MeteredProvider {
+	return &MeteredProvider{provider: provider, recorder: recorder, model: model}
+}
+
+// Name 被包装服务的名称
+func (p *MeteredProvider) Name() string {
+	return p.provider.Name()
+}
+
+// Chat 发送对话请求并记录用量
+func (p *MeteredProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := p.provider.Chat(ctx, req)
+	p.recordChat(ctx, req, resp, err, start)
+	return resp, err
+}
+
+// ChatStream 发送流式对话请求并记录用量
+func (p *MeteredProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(string) error) (*ChatResponse, error) {
+	start := time.Now()
+	resp, err := p.provider.ChatStream(ctx, req, onDelta)
+	p.recordChat(ctx, req, resp, err, start)
+	return resp, err
+}
+
+// Embed 将文本转换为向量并记录用量
+func (p *MeteredProvider) Embed(ctx context.Context, texts []string) (*EmbedResponse, error) {
+	start := time.Now()
+	resp, err := p.provider.Embed(ctx, texts)
+
+	record := p.newRecord(ctx, err, start)
+	if resp != nil {
+		record.Usage = resp.Usage
+		if resp.Model != "" {
+			record.Model = resp.Model
+		}
+	}
+	if err == nil && record.Usage.TotalTokens == 0 {
+		for _, text := range texts {
+			record.Usage.PromptTokens += utils.EstimateTokens(text)
+		}
+		record.Usage.TotalTokens = record.Usage.PromptTokens
+		record.Estimated = true
+	}
+	p.record(ctx, record)
+	return resp, err
+}
+
+// recordChat 记录对话用量；部分兼容服务在流式响应中不返回用量，此时按文本长度估算
+func (p *MeteredProvider) recordChat(ctx context.Context, req ChatRequest, resp *ChatResponse, err error, start time.Time) {
+	record := p.newRecord(ctx, err, start)
+	if resp != nil {
+		record.Usage = resp.Usage
+		if resp.Model != "" {
+			record.Model = resp.Model
+		}
+	}
+	if err == nil && record.Usage.TotalTokens == 0 {
+		for _, message := range req.Messages {
+			record.Usage.PromptTokens += utils.EstimateTokens(message.Content)
+		}
+		if resp != nil {
+			record.Usage.CompletionTokens = utils.EstimateTokens(resp.Content)
+		}
+		record.Usage.TotalTokens = record.Usage.PromptTokens + record.Usage.CompletionTokens
+		record.Estimated = true
+	}
+	p.record(ctx, record)
+}
+
+// record 保存用量，ctx中有UsageTally时同时累计
+func (p *MeteredProvider) record(ctx context.Context, record UsageRecord) {
+	if tally, ok := ctx.Value(usageTallyKey{}).(*UsageTally); ok {
+		tally.tokens.Add(int64(record.Usage.TotalTokens))
+	}
+	p.recorder.RecordUsage(context.WithoutCancel(ctx), record)
+}
+
+func (p *MeteredProvider) newRecord(ctx context.Context, err error, start time.Time) UsageRecord {
+	return UsageRecord{
+		UsageScope: UsageScopeFrom(ctx),
+		Provider:   p.provider.Name(),
+		Model:      p.model,
+		Latency:    time.Since(start),
+		Err:        err,
+		CreatedAt:  start,
+	}
+}
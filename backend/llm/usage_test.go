@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingRecorder struct {
+	records []UsageRecord
+}
+
+func (r *recordingRecorder) RecordUsage(_ context.Context, record UsageRecord) {
+	r.records = append(r.records, record)
+}
+
+func TestMeteredProvider(t *testing.T) {
+	recorder := &recordingRecorder{}
+	upstream := &scriptedProvider{errs: []error{newAPIError(http.StatusTooManyRequests, http.Header{}, "slow down")}}
+	p := NewMeteredProvider(upstream, recorder, "default-model")
+
+	ctx := WithUsageScope(context.Background(), UsageScope{UserID: "u1", ReportID: "r1"})
+	ctx = WithUsageScope(ctx, UsageScope{Operation: "ask"})
+	ctx, tally := WithUsageTally(ctx)
+
+	_, err := p.Chat(ctx, testChatRequest)
+	require.ErrorIs(t, err, ErrRateLimited)
+	_, err = p.Chat(ctx, testChatRequest)
+	require.NoError(t, err)
+
+	require.Len(t, recorder.records, 2)
+	failed, succeeded := recorder.records[0], recorder.records[1]
+	assert.Equal(t, UsageScope{UserID: "u1", ReportID: "r1", Operation: "ask"}, succeeded.UsageScope)
+	assert.ErrorIs(t, failed.Err, ErrRateLimited)
+	assert.Zero(t, failed.Usage.TotalTokens)
+	assert.NoError(t, succeeded.Err)
+	assert.Equal(t, ProviderFake, succeeded.Model)
+	assert.Positive(t, succeeded.Usage.TotalTokens)
+	assert.False(t, succeeded.Estimated)
+	assert.Equal(t, int64(succeeded.Usage.TotalTokens), tally.TotalTokens())
+}
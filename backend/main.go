@@ -2,29 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log" // 新增导入
 
 	// 新增导入
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/swaggo/gin-swagger/example/basic/docs"
 
+	"github.com/qujing226/pdf-enhancer/backend/authz"
 	"github.com/qujing226/pdf-enhancer/backend/database"
 	"github.com/qujing226/pdf-enhancer/backend/handlers"
+	"github.com/qujing226/pdf-enhancer/backend/llm"
 	"github.com/qujing226/pdf-enhancer/backend/models"
 	"github.com/qujing226/pdf-enhancer/backend/repository"
 	"github.com/qujing226/pdf-enhancer/backend/services"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
 	"github.com/qujing226/pdf-enhancer/backend/utils"
 )
 
@@ -53,18 +57,30 @@ func main() {
 		log.Panic("未找到.env文件，使用环境变量")
 	}
 
+	// 运维子命令，执行完毕后退出，不启动HTTP服务
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		runReconcile(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		runRotateKeys(os.Args[2:])
+		return
+	}
+
 	// 设置运行模式
 	gin.SetMode(getEnv("GIN_MODE", "debug"))
 
 	// 创建Gin引擎
 	r := gin.Default()
+	// 超过该大小的上传文件由multipart写入临时文件，避免大文件占用内存
+	r.MaxMultipartMemory = int64(getIntEnv("UPLOAD_MEMORY_MB", 8)) << 20
 
 	// 配置CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Range", "If-None-Match", "If-Modified-Since", "X-Share-Password"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Range", "Accept-Ranges", "Content-Disposition", "ETag"},
 		AllowCredentials: true,
 	}))
 
@@ -75,18 +91,46 @@ func main() {
 	}
 	defer db.Close()
 
-	// 初始化MinIO客户端
-	minioClient, err := initMinIO()
+	// 初始化对象存储
+	blobStore, err := initMinIO()
 	if err != nil {
-		log.Fatalf("MinIO连接失败: %v", err)
+		log.Fatalf("对象存储初始化失败: %v", err)
 	}
 
-	// 初始化DeepSeek客户端
-	deepseekClient := initDeepSeekClient()
+	// 初始化大模型客户端，每次调用的用量记录到llm_usage表
+	usageService, err := initUsageService(db)
+	if err != nil {
+		log.Fatalf("大模型用量服务初始化失败: %v", err)
+	}
+	llmClient, err := initLLMClient(usageService)
+	if err != nil {
+		log.Fatalf("大模型客户端初始化失败: %v", err)
+	}
 
 	// 初始化服务
 	userService := initUserService(db)
-	reportService := initReportService(db, minioClient, deepseekClient)
+	reportService := initReportService(db, blobStore, llmClient)
+	// 大模型配额从数据库读取，管理员修改后立即生效
+	quotaService := services.NewQuotaService(repository.NewQuotaRepository(db))
+	reportService.Quotas = quotaService
+	jobService := initJobService(db, reportService)
+	if err := jobService.Start(context.Background()); err != nil {
+		log.Fatalf("摘要任务服务启动失败: %v", err)
+	}
+	reportService.StartPurge(context.Background(), time.Duration(getIntEnv("TRASH_PURGE_INTERVAL_MINUTES", 60))*time.Minute)
+
+	shareService := services.NewShareService(repository.NewShareRepository(db), reportService, getEnv("PUBLIC_BASE_URL", "http://localhost:8080"))
+	shareHandler := handlers.NewShareHandler(shareService, reportService)
+
+	userRepo := repository.NewUserRepository(db)
+	teamRepo := repository.NewTeamRepository(db)
+	teamHandler := handlers.NewTeamHandler(services.NewTeamService(teamRepo, userRepo))
+	grantHandler := handlers.NewGrantHandler(services.NewGrantService(repository.NewGrantRepository(db), teamRepo, userRepo, reportService))
+
+	// 报告分享链接，凭令牌访问，不需要JWT令牌
+	r.GET("/s/:token", shareHandler.GetSharedReport)
+	r.GET("/s/:token/pdf", shareHandler.GetSharedReportPDF)
+	r.HEAD("/s/:token/pdf", shareHandler.GetSharedReportPDF)
 
 	// API路由组
 	api := r.Group("/api/v1")
@@ -96,17 +140,69 @@ func main() {
 		api.POST("/register", authHandler.Register)
 		api.POST("/login", authHandler.Login)
 
+		// 报告详情中的PDF签名链接，凭签名访问，不需要JWT令牌
+		reportHandler := handlers.NewReportHandler(reportService, jobService, int64(getIntEnv("MAX_UPLOAD_SIZE_MB", 100))<<20)
+		api.GET("/files/report/:report_id", reportHandler.GetSignedReportPDF)
+		api.HEAD("/files/report/:report_id", reportHandler.GetSignedReportPDF)
+
 		// 需要认证的路由
 		auth := api.Group("/")
 		auth.Use(authMiddleware())
 		{
 			// 报告相关API
-			reportHandler := handlers.NewReportHandler(reportService)
 			auth.GET("/reports", reportHandler.GetReports)
+			auth.GET("/reports/search", reportHandler.SearchReports)
+			auth.GET("/reports/trash", reportHandler.ListTrash)
+			auth.GET("/reports/shared", grantHandler.ListSharedWithMe)
 			auth.GET("/report/:report_id", reportHandler.GetReport)
+			auth.PATCH("/report/:report_id", reportHandler.RenameReport)
+			auth.DELETE("/report/:report_id", reportHandler.DeleteReport)
+			auth.POST("/report/:report_id/restore", reportHandler.RestoreReport)
+			auth.POST("/report/:report_id/unlock", reportHandler.UnlockReport)
 			auth.POST("/report/:report_id/summary", reportHandler.GenerateSummary)
+			auth.GET("/report/:report_id/summary/stream", reportHandler.StreamSummary)
+			auth.POST("/report/:report_id/ask", reportHandler.AskQuestion)
+			auth.GET("/report/:report_id/pages/:page", reportHandler.GetReportPage)
 			auth.GET("/report/:report_id/pdf", reportHandler.GetReportPDF)
+			auth.HEAD("/report/:report_id/pdf", reportHandler.GetReportPDF)
 			auth.POST("/reports/upload", reportHandler.UploadReport)
+
+			// 分享链接管理API
+			auth.POST("/report/:report_id/shares", shareHandler.CreateShare)
+			auth.GET("/report/:report_id/shares", shareHandler.ListShares)
+			auth.DELETE("/report/:report_id/shares/:share_id", shareHandler.RevokeShare)
+
+			// 报告共享授权API
+			auth.POST("/report/:report_id/grants", grantHandler.GrantAccess)
+			auth.GET("/report/:report_id/grants", grantHandler.ListGrants)
+			auth.DELETE("/report/:report_id/grants/:grant_id", grantHandler.RevokeGrant)
+
+			// 团队相关API
+			auth.POST("/teams", teamHandler.CreateTeam)
+			auth.GET("/teams", teamHandler.ListTeams)
+			auth.GET("/teams/:team_id/members", teamHandler.ListMembers)
+			auth.POST("/teams/:team_id/members", teamHandler.AddMember)
+			auth.DELETE("/teams/:team_id/members/:user_id", teamHandler.RemoveMember)
+
+			// 大模型用量统计和配额API
+			usageHandler := handlers.NewUsageHandler(usageService)
+			quotaHandler := handlers.NewQuotaHandler(quotaService)
+			auth.GET("/usage", usageHandler.GetUsage)
+			auth.GET("/quota", quotaHandler.GetQuota)
+
+			// 管理员API
+			admin := auth.Group("/admin", adminMiddleware(userService))
+			{
+				admin.GET("/usage", usageHandler.GetAllUsage)
+				admin.GET("/quota/plans", quotaHandler.ListPlans)
+				admin.PUT("/quota/plans/:plan", quotaHandler.UpdatePlan)
+				admin.GET("/users/:user_id/quota", quotaHandler.GetUserQuota)
+				admin.PUT("/users/:user_id/quota", quotaHandler.UpdateUserQuota)
+			}
+
+			// 异步任务相关API
+			jobHandler := handlers.NewJobHandler(jobService)
+			auth.GET("/jobs/:job_id", jobHandler.GetJob)
 		}
 	}
 
@@ -139,56 +235,108 @@ func initDB() (*sql.DB, error) {
 	return database.Connect(config)
 }
 
-// 初始化MinIO客户端
-func initMinIO() (*minio.Client, error) {
-	// 从环境变量获取MinIO配置
-	endpoint := getEnv("MINIO_ENDPOINT", "localhost:9000")
-	accessKey := getEnv("MINIO_ACCESS_KEY", "minioadmin")
-	secretKey := getEnv("MINIO_SECRET_KEY", "minioadmin")
+// 初始化对象存储，通过STORAGE_BACKEND选择minio、local或memory实现
+func initMinIO() (storage.BlobStore, error) {
 	useSSL, _ := strconv.ParseBool(getEnv("MINIO_USE_SSL", "false"))
+	config := storage.Config{
+		Backend:   getEnv("STORAGE_BACKEND", storage.BackendMinIO),
+		Endpoint:  getEnv("MINIO_ENDPOINT", "localhost:9000"),
+		AccessKey: getEnv("MINIO_ACCESS_KEY", "minioadmin"),
+		SecretKey: getEnv("MINIO_SECRET_KEY", "minioadmin"),
+		UseSSL:    useSSL,
+		Bucket:    getEnv("MINIO_BUCKET_NAME", "reports"),
+		LocalDir:  getEnv("STORAGE_LOCAL_DIR", "./data/reports"),
+	}
 
-	// 创建MinIO客户端
-	minioClient, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("MinIO客户端初始化失败: %w", err)
+	switch config.Backend {
+	case storage.BackendMinIO:
+		store, err := storage.NewMinIOStore(context.Background(), config)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("成功连接到MinIO服务: %s", config.Endpoint)
+		return store, nil
+	case storage.BackendLocal:
+		store, err := storage.NewLocalStore(config.LocalDir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("使用本地文件存储: %s", config.LocalDir)
+		return store, nil
+	case storage.BackendMemory:
+		log.Printf("使用内存存储，服务重启后文件将丢失")
+		return storage.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储后端: %s", config.Backend)
 	}
+}
 
-	// 确保存储桶存在
-	bucketName := getEnv("MINIO_BUCKET_NAME", "reports")
-	exists, err := minioClient.BucketExists(context.Background(), bucketName)
-	if err != nil {
-		return nil, fmt.Errorf("检查MinIO存储桶失败: %w", err)
+// 初始化大模型客户端，通过LLM_PROVIDER选择openai、ollama或fake实现
+// 为兼容旧配置，LLM_*未设置时读取对应的DEEPSEEK_*环境变量
+func initLLMClient(recorder llm.UsageRecorder) (*services.LLMClient, error) {
+	config := llm.Config{
+		Provider:       getEnv("LLM_PROVIDER", llm.ProviderOpenAI),
+		APIKey:         llmEnv("LLM_API_KEY", "DEEPSEEK_API_KEY", ""),
+		EmbeddingModel: getEnv("LLM_EMBEDDING_MODEL", ""),
 	}
 
-	if !exists {
-		// 创建存储桶
-		err = minioClient.MakeBucket(context.Background(), bucketName, minio.MakeBucketOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("创建MinIO存储桶失败: %w", err)
+	var provider llm.Provider
+	switch config.Provider {
+	case llm.ProviderOpenAI:
+		config.BaseURL = getEnv("LLM_BASE_URL", "")
+		if config.BaseURL == "" {
+			config.BaseURL = getEnv("DEEPSEEK_BASE_URL", "https://api.deepseek.com") + "/v1"
 		}
-		log.Printf("已创建MinIO存储桶: %s", bucketName)
+		config.Model = llmEnv("LLM_MODEL", "DEEPSEEK_MODEL_NAME", "deepseek-chat")
+		config.Timeout = time.Duration(getIntEnv("LLM_TIMEOUT_SECONDS", 30)) * time.Second
+		provider = llm.NewOpenAIProvider(config)
+	case llm.ProviderOllama:
+		config.BaseURL = getEnv("LLM_BASE_URL", "http://localhost:11434")
+		config.Model = getEnv("LLM_MODEL", "qwen2.5")
+		if config.EmbeddingModel == "" {
+			config.EmbeddingModel = "nomic-embed-text"
+		}
+		// 本地模型在CPU上推理较慢，默认超时更长
+		config.Timeout = time.Duration(getIntEnv("LLM_TIMEOUT_SECONDS", 300)) * time.Second
+		provider = llm.NewOllamaProvider(config)
+	case llm.ProviderFake:
+		log.Println("使用fake大模型实现，摘要和问答结果仅用于测试")
+		config.Model = llm.ProviderFake
+		provider = llm.NewFakeProvider()
+	default:
+		return nil, fmt.Errorf("不支持的大模型服务: %s", config.Provider)
 	}
-
-	log.Printf("成功连接到MinIO服务: %s", endpoint)
-	return minioClient, nil
+	log.Printf("大模型服务: %s %s %s", provider.Name(), config.BaseURL, config.Model)
+	provider = llm.NewResilientProvider(provider, llm.ResilienceConfig{
+		MaxAttempts:      getIntEnv("LLM_MAX_ATTEMPTS", 3),
+		BaseDelay:        time.Duration(getIntEnv("LLM_RETRY_BASE_DELAY_MS", 500)) * time.Millisecond,
+		MaxDelay:         time.Duration(getIntEnv("LLM_RETRY_MAX_DELAY_SECONDS", 10)) * time.Second,
+		FailureThreshold: getIntEnv("LLM_BREAKER_THRESHOLD", 5),
+		Cooldown:         time.Duration(getIntEnv("LLM_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second,
+	})
+	// 在重试之外记录用量，一次调用只记录一条，耗时包括重试等待
+	provider = llm.NewMeteredProvider(provider, recorder, config.Model)
+
+	return services.NewLLMClient(provider, services.LLMConfig{
+		MaxTokens:    getIntEnv("LLM_MAX_TOKENS", getIntEnv("DEEPSEEK_MAX_TOKENS", 1000)),
+		Temperature:  getFloatEnv("LLM_TEMPERATURE", getFloatEnv("DEEPSEEK_TEMPERATURE", 0.7)),
+		ChunkTokens:  getIntEnv("LLM_CHUNK_TOKENS", getIntEnv("DEEPSEEK_CHUNK_TOKENS", 8000)),
+		ChunkOverlap: getIntEnv("LLM_CHUNK_OVERLAP", getIntEnv("DEEPSEEK_CHUNK_OVERLAP", 200)),
+	}), nil
 }
 
-// 初始化DeepSeek客户端
-func initDeepSeekClient() *services.DeepSeekClient {
-	// 从环境变量获取DeepSeek配置
-	config := services.DeepSeekConfig{
-		APIKey:      getEnv("DEEPSEEK_API_KEY", ""),
-		BaseURL:     getEnv("DEEPSEEK_BASE_URL", "https://api.deepseek.com"),
-		ModelName:   getEnv("DEEPSEEK_MODEL_NAME", "deepseek-chat"),
-		MaxTokens:   getIntEnv("DEEPSEEK_MAX_TOKENS", 1000),
-		Temperature: getFloatEnv("DEEPSEEK_TEMPERATURE", 0.7),
+// 初始化大模型用量服务，LLM_PRICING配置各模型每百万token的输入/输出单价
+func initUsageService(db *sql.DB) (*services.UsageService, error) {
+	pricing, err := services.ParseModelPricing(getEnv("LLM_PRICING", ""))
+	if err != nil {
+		return nil, err
 	}
+	return services.NewUsageService(repository.NewUsageRepository(db), pricing), nil
+}
 
-	// 创建DeepSeek客户端
-	return services.NewDeepSeekClient(config)
+// llmEnv 读取大模型配置，未设置时回退到旧的环境变量名
+func llmEnv(key, legacyKey, defaultValue string) string {
+	return getEnv(key, getEnv(legacyKey, defaultValue))
 }
 
 // 初始化用户服务
@@ -198,9 +346,71 @@ func initUserService(db *sql.DB) *services.UserService {
 }
 
 // 初始化报告服务
-func initReportService(db *sql.DB, minioClient *minio.Client, deepseekClient *services.DeepSeekClient) *services.ReportService {
+func initReportService(db *sql.DB, blobStore storage.BlobStore, llmClient *services.LLMClient) *services.ReportService {
 	reportRepo := repository.NewReportRepository(db)
-	return services.NewReportService(reportRepo, minioClient, deepseekClient, getEnv("MINIO_BUCKET_NAME", "reports"))
+	pageRepo := repository.NewReportPageRepository(db)
+	blobRepo := repository.NewBlobRepository(db)
+	metadataRepo := repository.NewReportMetadataRepository(db)
+	reportService := services.NewReportService(reportRepo, pageRepo, blobRepo, metadataRepo, blobStore, llmClient, getEnv("MINIO_BUCKET_NAME", "reports"))
+	reportService.TrashRetention = time.Duration(getIntEnv("TRASH_RETENTION_DAYS", 30)) * 24 * time.Hour
+	reportService.PDFLimits = utils.PDFLimits{
+		MaxPages:           getIntEnv("PDF_MAX_PAGES", 2000),
+		MaxObjects:         getIntEnv("PDF_MAX_OBJECTS", 500000),
+		MaxStreamSize:      int64(getIntEnv("PDF_MAX_STREAM_MB", 100)) << 20,
+		MaxTotalStreamSize: int64(getIntEnv("PDF_MAX_TOTAL_STREAM_MB", 1024)) << 20,
+		MaxParseSize:       int64(getIntEnv("PDF_MAX_PARSE_MB", 32)) << 20,
+	}
+	reportService.Keys = initKeyService(db)
+	reportService.Policy = authz.NewReportPolicy(repository.NewGrantRepository(db))
+	reportService.PDFLinks = services.PDFLinkConfig{
+		Mode:    getEnv("PDF_URL_MODE", services.PDFLinkSigned),
+		TTL:     time.Duration(getIntEnv("PDF_URL_TTL_MINUTES", 24*60)) * time.Minute,
+		BaseURL: getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		Secret:  pdfURLSecret(),
+	}
+	return reportService
+}
+
+// pdfURLSecret 读取PDF签名链接的HMAC密钥，未配置时随机生成，服务重启后之前生成的链接失效
+func pdfURLSecret() []byte {
+	if secret := getEnv("PDF_URL_SECRET", ""); secret != "" {
+		return []byte(secret)
+	}
+	log.Println("未配置PDF_URL_SECRET，使用随机密钥签名PDF下载链接，服务重启或多实例部署时链接会失效")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("生成PDF链接签名密钥失败: %v", err)
+	}
+	return secret
+}
+
+// 初始化密钥服务，未配置主密钥时返回nil，PDF和报告内容不加密
+func initKeyService(db *sql.DB) *services.KeyService {
+	spec := getEnv("ENCRYPTION_MASTER_KEYS", "")
+	if spec == "" {
+		log.Println("未配置ENCRYPTION_MASTER_KEYS，PDF和报告内容将以明文保存")
+		return nil
+	}
+	masterKeys, err := services.ParseMasterKeys(spec)
+	if err != nil {
+		log.Fatalf("主密钥配置无效: %v", err)
+	}
+	keyService, err := services.NewKeyService(repository.NewUserKeyRepository(db), masterKeys, getIntEnv("ENCRYPTION_ACTIVE_KEY_VERSION", 0))
+	if err != nil {
+		log.Fatalf("初始化密钥服务失败: %v", err)
+	}
+	return keyService
+}
+
+// 初始化摘要任务服务
+func initJobService(db *sql.DB, reportService *services.ReportService) *services.JobService {
+	jobRepo := repository.NewJobRepository(db)
+	return services.NewJobService(jobRepo, reportService, services.JobConfig{
+		Workers:      getIntEnv("SUMMARY_WORKERS", 2),
+		QueueSize:    getIntEnv("SUMMARY_QUEUE_SIZE", 100),
+		PollInterval: time.Duration(getIntEnv("SUMMARY_POLL_INTERVAL_SECONDS", 30)) * time.Second,
+		JobTimeout:   time.Duration(getIntEnv("SUMMARY_JOB_TIMEOUT_SECONDS", 600)) * time.Second,
+	})
 }
 
 // JWT认证中间件
@@ -259,6 +469,26 @@ func authMiddleware() gin.HandlerFunc {
 	}
 }
 
+// adminMiddleware 要求当前用户为管理员，需在authMiddleware之后使用
+func adminMiddleware(userService *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := userService.GetUserByID(utils.GetUserIDFromContext(c))
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				c.AbortWithStatusJSON(401, models.NewAPIResponse(401, err.Error(), nil))
+				return
+			}
+			c.AbortWithStatusJSON(500, models.NewAPIResponse(500, "查询用户失败", err.Error()))
+			return
+		}
+		if user.Role != models.UserRoleAdmin {
+			c.AbortWithStatusJSON(403, models.NewAPIResponse(403, "需要管理员权限", nil))
+			return
+		}
+		c.Next()
+	}
+}
+
 // 获取环境变量整数值
 func getIntEnv(key string, defaultValue int) int {
 	valueStr := getEnv(key, "")
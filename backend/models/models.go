@@ -11,20 +11,174 @@ type User struct {
 	Email        string    `json:"email" db:"email"`
 	PasswordHash string    `json:"password_hash,omitempty" db:"password_hash"`
 	Salt         string    `json:"salt,omitempty" db:"salt"`
+	Role         string    `json:"role" db:"role"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// 用户角色
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin" // 可以查看全部用户的大模型用量
+)
+
 // Report 报告模型
 type Report struct {
-	ID        string    `json:"report_id" db:"id"`
-	UserID    string    `json:"user_id" db:"user_id"`
-	Title     string    `json:"title" db:"title"`
-	Content   string    `json:"content" db:"content"`
-	Summary   string    `json:"summary" db:"summary"`
+	ID          string     `json:"report_id" db:"id"`
+	UserID      string     `json:"user_id" db:"user_id"`
+	Title       string     `json:"title" db:"title"`
+	Content     string     `json:"content" db:"content"`
+	Summary     string     `json:"summary" db:"summary"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	PDFPath     string     `json:"pdf_path" db:"pdf_path"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ContentHash string     `json:"content_hash,omitempty" db:"content_hash"`
+	// EncryptionStatus PDF的加密状态，见 Encryption* 常量
+	EncryptionStatus string `json:"encryption_status" db:"encryption_status"`
+	// SummaryOptions 生成当前摘要时使用的选项，没有摘要或摘要生成时未记录选项时为nil
+	SummaryOptions *SummaryOptions `json:"summary_options,omitempty"`
+}
+
+// 报告PDF的加密状态
+const (
+	EncryptionNone     = "none"     // 未加密，或只设置了权限密码
+	EncryptionLocked   = "locked"   // 设置了打开密码且尚未提供，没有提取文本
+	EncryptionUnlocked = "unlocked" // 已用密码解密并提取文本
+)
+
+// Blob 按内容SHA-256寻址的PDF对象，多个报告可共享同一对象
+type Blob struct {
+	Hash      string    `json:"hash" db:"hash"`
+	ObjectKey string    `json:"object_key" db:"object_key"`
+	Size      int64     `json:"size" db:"size"`
+	RefCount  int       `json:"ref_count" db:"ref_count"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
-	PDFPath   string    `json:"pdf_path" db:"pdf_path"`
+}
+
+// UserKey 用户的数据密钥，以主密钥加密（包装）后保存；每个用户同一时间只有一个启用的密钥
+type UserKey struct {
+	ID               int64     `json:"id" db:"id"`
+	UserID           string    `json:"user_id" db:"user_id"`
+	WrappedKey       string    `json:"-" db:"wrapped_key"`
+	MasterKeyVersion int       `json:"master_key_version" db:"master_key_version"`
+	Active           bool      `json:"active" db:"active"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReportShare 报告的公开分享链接，令牌只在创建时返回，数据库中只保存其哈希
+type ReportShare struct {
+	ID             string     `json:"share_id"`
+	ReportID       string     `json:"report_id"`
+	UserID         string     `json:"-"`
+	TokenHash      string     `json:"-"`
+	PasswordHash   string     `json:"-"`
+	HasPassword    bool       `json:"has_password"`
+	AllowDownload  bool       `json:"allow_download"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	AccessCount    int64      `json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Active 分享链接是否仍可访问（未撤销且未过期）
+func (s *ReportShare) Active(now time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// 团队成员角色
+const (
+	TeamRoleAdmin  = "admin"  // 可以添加和移除成员
+	TeamRoleMember = "member" // 普通成员
+)
+
+// Team 团队，授予团队的报告权限对全部成员生效
+type Team struct {
+	ID        string    `json:"team_id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"owner_id"`
+	Role      string    `json:"role,omitempty"` // 当前用户在团队中的角色
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamMember 团队成员
+type TeamMember struct {
+	TeamID    string    `json:"team_id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 报告授权对象类型
+const (
+	GranteeUser = "user"
+	GranteeTeam = "team"
+)
+
+// ReportGrant 授予其他用户或团队的报告访问权限，Role为viewer或editor
+type ReportGrant struct {
+	ID          string    `json:"grant_id"`
+	ReportID    string    `json:"report_id"`
+	GranteeType string    `json:"grantee_type"`
+	GranteeID   string    `json:"grantee_id"`
+	GranteeName string    `json:"grantee_name"`
+	Role        string    `json:"role"`
+	GrantedBy   string    `json:"granted_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SharedReportItem 他人共享给当前用户的报告，通过多个授权获得时Role取权限最高的角色
+type SharedReportItem struct {
+	ReportID   string    `json:"report_id"`
+	Title      string    `json:"title"`
+	OwnerID    string    `json:"owner_id"`
+	OwnerName  string    `json:"owner_name"`
+	Role       string    `json:"role"`
+	HasSummary bool      `json:"has_summary"`
+	CreatedAt  time.Time `json:"created_at"`
+	SharedAt   time.Time `json:"shared_at"`
+}
+
+// ReportMetadata 从PDF文档信息字典和XMP中提取的元数据
+type ReportMetadata struct {
+	ReportID     string     `json:"-"`
+	Title        string     `json:"title"`
+	Author       string     `json:"author"`
+	Subject      string     `json:"subject"`
+	Keywords     string     `json:"keywords"`
+	Creator      string     `json:"creator"`
+	Producer     string     `json:"producer"`
+	CreationDate *time.Time `json:"creation_date"`
+	ModDate      *time.Time `json:"mod_date"`
+	PageCount    int        `json:"page_count"`
+	PageSizes    []PageSize `json:"page_sizes"`
+	PDFVersion   string     `json:"pdf_version"`
+	Encrypted    bool       `json:"encrypted"`
+}
+
+// PageSize 页面尺寸，单位为点（1/72英寸）
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// UploadReportResponse 上传报告响应，Duplicate表示相同内容的PDF已经存在并被复用
+type UploadReportResponse struct {
+	Report
+	Duplicate        bool   `json:"duplicate"`
+	ExistingReportID string `json:"existing_report_id,omitempty"`
+}
+
+// ReportPage 报告单页文本
+type ReportPage struct {
+	ReportID   string    `json:"report_id" db:"report_id"`
+	PageNumber int       `json:"page" db:"page_number"`
+	Content    string    `json:"content" db:"content"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
 // RegisterRequest 注册请求
@@ -54,21 +208,158 @@ type LoginResponse struct {
 
 // ReportListItem 报告列表项
 type ReportListItem struct {
-	ReportID   string    `json:"report_id"`
-	Title      string    `json:"title"`
-	CreatedAt  time.Time `json:"created_at"`
-	HasSummary bool      `json:"has_summary"`
+	ReportID   string     `json:"report_id"`
+	Title      string     `json:"title"`
+	CreatedAt  time.Time  `json:"created_at"`
+	HasSummary bool       `json:"has_summary"`
+	AuthoredAt *time.Time `json:"authored_at,omitempty"` // PDF元数据中的创建时间
 }
 
 // ReportListResponse 报告列表响应
 type ReportListResponse struct {
-	Reports []ReportListItem `json:"reports"`
+	Reports    []ReportListItem `json:"reports"`
+	NextCursor string           `json:"next_cursor"`
+	Total      int              `json:"total"`
+}
+
+// 报告列表排序字段
+const (
+	ReportSortCreatedAt = "created_at"
+	ReportSortTitle     = "title"
+)
+
+// ReportListQuery 报告列表查询条件
+type ReportListQuery struct {
+	Limit      int
+	Cursor     string     // 上一页返回的next_cursor
+	From       *time.Time // 创建时间起（含）
+	To         *time.Time // 创建时间止（不含）
+	HasSummary *bool
+	SortBy     string // created_at 或 title
+	Desc       bool
+
+	// 按PDF元数据过滤
+	Author       string     // 作者包含该字符串
+	Producer     string     // 生成程序包含该字符串
+	AuthoredFrom *time.Time // PDF创建时间起（含）
+	AuthoredTo   *time.Time // PDF创建时间止（不含）
+}
+
+// ReportListCursor 游标分页位置：上一页最后一条记录的排序字段值和ID，以及生成游标时的排序方式
+type ReportListCursor struct {
+	SortBy    string `json:"s"`
+	Desc      bool   `json:"d,omitempty"`
+	SortValue string `json:"v"`
+	ID        string `json:"id"`
+}
+
+// TrashItem 回收站中的报告
+type TrashItem struct {
+	ReportID  string    `json:"report_id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// RenameReportRequest 修改报告标题请求
+type RenameReportRequest struct {
+	Title string `json:"title" binding:"required,max=255"`
+}
+
+// UnlockReportRequest 解锁加密报告请求，StoreDecrypted为true时用解密后的副本替换原PDF
+type UnlockReportRequest struct {
+	Password       string `json:"password" binding:"required"`
+	StoreDecrypted bool   `json:"store_decrypted"`
+}
+
+// CreateShareRequest 创建分享链接请求，ExpiresAt为空时永不过期，Password为空时不需要密码
+type CreateShareRequest struct {
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Password      string     `json:"password" binding:"max=128"`
+	AllowDownload bool       `json:"allow_download"`
+}
+
+// ShareResponse 分享链接信息，Active表示当前是否可访问；Token和URL只在创建时返回
+type ShareResponse struct {
+	ReportShare
+	Active bool   `json:"active"`
+	Token  string `json:"token,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+// SharedReportResponse 通过分享链接查看的报告
+type SharedReportResponse struct {
+	Title         string     `json:"title"`
+	Summary       string     `json:"summary"`
+	CreatedAt     time.Time  `json:"created_at"`
+	AllowDownload bool       `json:"allow_download"`
+	PDFURL        string     `json:"pdf_url,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// CreateTeamRequest 创建团队请求
+type CreateTeamRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// AddTeamMemberRequest 添加团队成员请求，按邮箱查找用户；成员已存在时更新角色
+type AddTeamMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"omitempty,oneof=admin member"`
+}
+
+// GrantReportRequest 授予报告访问权限请求，授权给用户时填写Email，授权给团队时填写TeamID；已授权时更新角色
+type GrantReportRequest struct {
+	GranteeType string `json:"grantee_type" binding:"required,oneof=user team"`
+	Email       string `json:"email"`
+	TeamID      string `json:"team_id"`
+	Role        string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// ReportSearchMatch 全文检索命中的报告及相关度
+type ReportSearchMatch struct {
+	Report Report
+	Score  float64
+}
+
+// ReportSearchHit 报告检索结果项，高亮片段已做HTML转义，命中关键词用<mark>标记
+type ReportSearchHit struct {
+	ReportID       string    `json:"report_id"`
+	Title          string    `json:"title"`
+	CreatedAt      time.Time `json:"created_at"`
+	HasSummary     bool      `json:"has_summary"`
+	Score          float64   `json:"score"`
+	TitleHighlight string    `json:"title_highlight,omitempty"`
+	ContentSnippet string    `json:"content_snippet,omitempty"`
+	SummarySnippet string    `json:"summary_snippet,omitempty"`
+}
+
+// ReportSearchResponse 报告检索响应
+type ReportSearchResponse struct {
+	Query string            `json:"query"`
+	Hits  []ReportSearchHit `json:"hits"`
 }
 
 // ReportDetailResponse 报告详情响应
 type ReportDetailResponse struct {
 	Report
-	PDFURL string `json:"pdf_url"`
+	Role            string          `json:"role"`
+	PDFURL          string          `json:"pdf_url"`
+	PDFURLExpiresAt *time.Time      `json:"pdf_url_expires_at"`
+	Metadata        *ReportMetadata `json:"metadata"`
+}
+
+// PDFLink 有时效的PDF下载链接
+type PDFLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReportPageResponse 报告单页响应
+type ReportPageResponse struct {
+	ReportPage
+	TotalPages int `json:"total_pages"`
 }
 
 // SummaryRequest 摘要请求
@@ -81,6 +372,220 @@ type SummaryResponse struct {
 	Summary string `json:"summary"`
 }
 
+// AskRequest 报告问答请求
+type AskRequest struct {
+	Question string `json:"question" binding:"required,max=500"`
+}
+
+// AskSource 回答引用的报告段落
+type AskSource struct {
+	Page    int    `json:"page"`
+	Excerpt string `json:"excerpt"`
+}
+
+// AskResponse 报告问答响应
+type AskResponse struct {
+	Answer  string      `json:"answer"`
+	Pages   []int       `json:"pages"`
+	Sources []AskSource `json:"sources"`
+}
+
+// 摘要任务状态
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// SummaryJob 异步摘要任务
+type SummaryJob struct {
+	ID         string         `json:"job_id" db:"id"`
+	ReportID   string         `json:"report_id" db:"report_id"`
+	UserID     string         `json:"user_id" db:"user_id"`
+	Status     string         `json:"status" db:"status"`
+	Options    SummaryOptions `json:"options"`
+	Error      string         `json:"error,omitempty" db:"error"`
+	ErrorCode  int            `json:"error_code,omitempty" db:"error_code"`   // 失败时的错误码，与同步接口在同一错误下返回的HTTP状态码相同
+	RetryAfter *int           `json:"retry_after,omitempty" db:"retry_after"` // 失败时建议在finished_at之后等待的秒数，没有建议时为nil
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty" db:"finished_at"`
+	// QuotaTokens 创建任务时预留的token数，为nil表示创建时未预留配额
+	QuotaTokens *int64 `json:"-" db:"quota_reserved_tokens"`
+}
+
+// 摘要风格，每种风格对应一个提示词模板
+const (
+	SummaryStyleConcise      = "concise"       // 简洁摘要（默认）
+	SummaryStyleExecutive    = "executive"     // 面向管理层的执行摘要
+	SummaryStyleBulletPoints = "bullet_points" // 要点列表
+	SummaryStyleRisk         = "risk"          // 侧重风险
+	SummaryStylePlain        = "plain"         // 通俗易懂
+)
+
+// 摘要篇幅
+const (
+	SummaryLengthShort  = "short"
+	SummaryLengthMedium = "medium" // 默认
+	SummaryLengthLong   = "long"
+)
+
+// 摘要输出语言
+const (
+	SummaryLanguageChinese = "zh" // 默认
+	SummaryLanguageEnglish = "en"
+)
+
+// SummaryOptions 摘要生成选项，字段为空时使用默认值
+type SummaryOptions struct {
+	Style    string `json:"style" form:"style"`
+	Length   string `json:"length" form:"length"`
+	Language string `json:"language" form:"language"`
+}
+
+// 大模型调用的业务操作
+const (
+	UsageOpSummary        = "summary"         // 生成摘要
+	UsageOpSummaryChunk   = "summary_chunk"   // 长报告分块摘要
+	UsageOpSummaryCombine = "summary_combine" // 合并分块摘要
+	UsageOpAsk            = "ask"             // 报告问答
+)
+
+// 大模型调用结果
+const (
+	UsageStatusSuccess = "success"
+	UsageStatusError   = "error"
+)
+
+// LLMUsage 一次大模型调用的token用量、耗时和估算费用
+type LLMUsage struct {
+	ID               int64     `json:"id" db:"id"`
+	UserID           string    `json:"user_id" db:"user_id"`
+	ReportID         string    `json:"report_id" db:"report_id"`
+	Operation        string    `json:"operation" db:"operation"`
+	RequestID        string    `json:"request_id" db:"request_id"`
+	Provider         string    `json:"provider" db:"provider"`
+	Model            string    `json:"model" db:"model"`
+	PromptTokens     int       `json:"prompt_tokens" db:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens" db:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens" db:"total_tokens"`
+	Estimated        bool      `json:"estimated" db:"estimated"` // 服务端未返回用量，token数为估算值
+	LatencyMS        int64     `json:"latency_ms" db:"latency_ms"`
+	Cost             float64   `json:"cost" db:"cost"`
+	Status           string    `json:"status" db:"status"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// UsageTotals 大模型用量汇总
+type UsageTotals struct {
+	Calls            int64   `json:"calls"`
+	FailedCalls      int64   `json:"failed_calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageDaily 按天汇总的用量，日期为 YYYY-MM-DD
+type UsageDaily struct {
+	Date string `json:"date"`
+	UsageTotals
+}
+
+// UsageByReport 按报告汇总的用量，报告已彻底删除时Title为空
+type UsageByReport struct {
+	ReportID string `json:"report_id"`
+	Title    string `json:"title"`
+	UsageTotals
+}
+
+// UsageByUser 按用户汇总的用量
+type UsageByUser struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	UsageTotals
+}
+
+// UsageResponse 当前用户的用量统计
+type UsageResponse struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Total   UsageTotals     `json:"total"`
+	Daily   []UsageDaily    `json:"daily"`
+	Reports []UsageByReport `json:"reports"`
+}
+
+// AdminUsageResponse 全部用户的用量统计
+type AdminUsageResponse struct {
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	Total UsageTotals   `json:"total"`
+	Daily []UsageDaily  `json:"daily"`
+	Users []UsageByUser `json:"users"`
+}
+
+// DefaultQuotaPlan 新用户的默认套餐
+const DefaultQuotaPlan = "free"
+
+// QuotaLimits 大模型配额上限，请求数按用户发起的摘要、问答次数计算；字段为nil表示不限制
+type QuotaLimits struct {
+	DailyRequests   *int64 `json:"daily_requests" binding:"omitempty,min=0"`
+	DailyTokens     *int64 `json:"daily_tokens" binding:"omitempty,min=0"`
+	MonthlyRequests *int64 `json:"monthly_requests" binding:"omitempty,min=0"`
+	MonthlyTokens   *int64 `json:"monthly_tokens" binding:"omitempty,min=0"`
+}
+
+// QuotaPlan 套餐及其配额
+type QuotaPlan struct {
+	Name string `json:"name"`
+	QuotaLimits
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UserQuota 用户的套餐和单独设置的配额，Overrides中为nil的字段沿用套餐配额
+type UserQuota struct {
+	UserID     string      `json:"user_id"`
+	Plan       string      `json:"plan"`
+	PlanLimits QuotaLimits `json:"plan_limits"`
+	Overrides  QuotaLimits `json:"overrides"`
+}
+
+// UpdateUserQuotaRequest 修改用户套餐和单独配额的请求，Overrides整体替换原有设置
+type UpdateUserQuotaRequest struct {
+	Plan      string      `json:"plan" binding:"required,max=32"`
+	Overrides QuotaLimits `json:"overrides"`
+}
+
+// QuotaWindow 一个统计周期内的配额使用情况，Limit和Remaining为nil表示不限制
+type QuotaWindow struct {
+	RequestsLimit     *int64    `json:"requests_limit"`
+	RequestsUsed      int64     `json:"requests_used"`
+	RequestsRemaining *int64    `json:"requests_remaining"`
+	TokensLimit       *int64    `json:"tokens_limit"`
+	TokensUsed        int64     `json:"tokens_used"`
+	TokensRemaining   *int64    `json:"tokens_remaining"`
+	ResetAt           time.Time `json:"reset_at"`
+}
+
+// QuotaStatus 用户当天和当月的配额使用情况
+type QuotaStatus struct {
+	UserID  string      `json:"user_id"`
+	Plan    string      `json:"plan"`
+	Daily   QuotaWindow `json:"daily"`
+	Monthly QuotaWindow `json:"monthly"`
+}
+
+// QuotaExceededResponse 超出配额时的响应数据，Limit为超出的配额项，如 daily_tokens；RequiredTokens为本次请求预估需要的token数
+type QuotaExceededResponse struct {
+	Limit          string       `json:"limit"`
+	ResetAt        time.Time    `json:"reset_at"`
+	RequiredTokens int64        `json:"required_tokens"`
+	Quota          *QuotaStatus `json:"quota"`
+}
+
 // APIResponse API通用响应结构
 type APIResponse struct {
 	Code    int         `json:"code"`
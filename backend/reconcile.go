@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+)
+
+// runReconcile 执行对账子命令：go run . reconcile [-fix] [-grace 1h]
+// 默认只输出不一致的数据，指定-fix时删除孤立对象，并将缺少PDF的报告移入回收站
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "删除孤立对象，并将缺少PDF的报告移入回收站")
+	grace := fs.Duration("grace", time.Hour, "跳过最近这段时间内写入的对象，避免误删正在上传的文件")
+	fs.Parse(args)
+
+	db, err := initDB()
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer db.Close()
+
+	blobStore, err := initMinIO()
+	if err != nil {
+		log.Fatalf("对象存储初始化失败: %v", err)
+	}
+
+	reconcileService := services.NewReconcileService(repository.NewReportRepository(db), blobStore, *grace)
+	result, err := reconcileService.Reconcile(context.Background(), *fix)
+	if err != nil {
+		log.Fatalf("对账失败: %v", err)
+	}
+
+	for _, object := range result.OrphanObjects {
+		log.Printf("孤立对象: %s (%d字节, 修改于%s)", object.Key, object.Size, object.LastModified.Format(time.RFC3339))
+	}
+	for _, report := range result.MissingObjects {
+		state := ""
+		if report.DeletedAt != nil {
+			state = "，已在回收站"
+		}
+		log.Printf("PDF缺失的报告: %s (用户%s, 路径%s%s)", report.ID, report.UserID, report.PDFPath, state)
+	}
+	log.Printf("对账完成: 扫描对象%d个、报告%d个，孤立对象%d个，PDF缺失的报告%d个",
+		result.ScannedObjects, result.ScannedReports, len(result.OrphanObjects), len(result.MissingObjects))
+	if *fix {
+		log.Printf("已删除孤立对象%d个，已将%d个报告移入回收站", result.RemovedObjects, result.TrashedReports)
+	}
+}
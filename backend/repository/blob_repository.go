@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IBlobRepository PDF对象引用计数仓储接口
+type IBlobRepository interface {
+	Acquire(ctx context.Context, blob *models.Blob) error
+	Release(ctx context.Context, hash string, onLast func(objectKey string) error) error
+	ReplaceObject(ctx context.Context, hash string, oldKey string, newKey string) error
+}
+
+// ErrBlobNotFound PDF对象记录不存在，或对象键已被修改
+var ErrBlobNotFound = errors.New("PDF对象记录不存在")
+
+// BlobRepository PDF对象引用计数仓储实现
+type BlobRepository struct {
+	db *sql.DB
+}
+
+// NewBlobRepository 创建PDF对象引用计数仓储实例
+func NewBlobRepository(db *sql.DB) *BlobRepository {
+	return &BlobRepository{db: db}
+}
+
+// Acquire 增加对象的引用计数，记录不存在时创建并计为1
+// 完成后blob.ObjectKey为记录中保存的对象键，密钥轮换后该键与按哈希生成的默认键不同
+func (r *BlobRepository) Acquire(ctx context.Context, blob *models.Blob) error {
+	now := time.Now()
+	blobDAO := dao_models.BlobDAO{
+		Hash:      blob.Hash,
+		ObjectKey: blob.ObjectKey,
+		Size:      blob.Size,
+		RefCount:  1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	query := `INSERT INTO blobs (hash, object_key, size, ref_count, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE ref_count = ref_count + 1, updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query,
+		blobDAO.Hash, blobDAO.ObjectKey, blobDAO.Size, blobDAO.RefCount, blobDAO.CreatedAt, blobDAO.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("增加PDF对象引用失败: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `SELECT object_key FROM blobs WHERE hash = ?`, blob.Hash).Scan(&blob.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("查询PDF对象失败: %w", err)
+	}
+	return nil
+}
+
+// Release 减少对象的引用计数；释放最后一个引用时删除记录并调用onLast删除对象
+// onLast在持有行锁的事务中执行，保证删除对象期间不会有新的引用；onLast失败时引用计数保持不变
+func (r *BlobRepository) Release(ctx context.Context, hash string, onLast func(objectKey string) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var blobDAO dao_models.BlobDAO
+	err = tx.QueryRowContext(ctx, `SELECT object_key, ref_count FROM blobs WHERE hash = ? FOR UPDATE`, hash).
+		Scan(&blobDAO.ObjectKey, &blobDAO.RefCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询PDF对象引用失败: %w", err)
+	}
+
+	if blobDAO.RefCount > 1 {
+		_, err = tx.ExecContext(ctx, `UPDATE blobs SET ref_count = ref_count - 1, updated_at = ? WHERE hash = ?`, time.Now(), hash)
+		if err != nil {
+			return fmt.Errorf("减少PDF对象引用失败: %w", err)
+		}
+	} else {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM blobs WHERE hash = ?`, hash); err != nil {
+			return fmt.Errorf("删除PDF对象记录失败: %w", err)
+		}
+		if err := onLast(blobDAO.ObjectKey); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交PDF对象引用失败: %w", err)
+	}
+	return nil
+}
+
+// ReplaceObject 将对象记录和引用该对象的全部报告从oldKey切换到newKey，二者在同一事务中完成
+// 记录不存在或对象键已不是oldKey时返回ErrBlobNotFound
+func (r *BlobRepository) ReplaceObject(ctx context.Context, hash string, oldKey string, newKey string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE blobs SET object_key = ?, updated_at = ? WHERE hash = ? AND object_key = ?`,
+		newKey, time.Now(), hash, oldKey)
+	if err != nil {
+		return fmt.Errorf("更新PDF对象记录失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("更新PDF对象记录失败: %w", err)
+	}
+	if affected == 0 {
+		return ErrBlobNotFound
+	}
+
+	// 报告路径为bucketName/objectName，只替换对象名部分
+	_, err = tx.ExecContext(ctx, `UPDATE reports SET pdf_path = CONCAT(LEFT(pdf_path, CHAR_LENGTH(pdf_path) - CHAR_LENGTH(?)), ?)
+	          WHERE content_hash = ? AND (pdf_path = ? OR pdf_path LIKE CONCAT('%/', ?))`, oldKey, newKey, hash, oldKey, oldKey)
+	if err != nil {
+		return fmt.Errorf("更新报告PDF路径失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交PDF对象记录失败: %w", err)
+	}
+	return nil
+}
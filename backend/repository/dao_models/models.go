@@ -1,19 +1,82 @@
 package dao_models
 
 import (
+	"database/sql"
 	"time"
 )
 
 // ReportDAO 报告数据库模型
 type ReportDAO struct {
-	ID        string    `db:"id"`
-	UserID    string    `db:"user_id"`
-	Title     string    `db:"title"`
-	Content   string    `db:"content"` // 使用longtext类型存储
-	Summary   string    `db:"summary"`
+	ID               string         `db:"id"`
+	UserID           string         `db:"user_id"`
+	Title            string         `db:"title"`
+	Content          string         `db:"content"` // 使用longtext类型存储
+	Summary          string         `db:"summary"`
+	CreatedAt        time.Time      `db:"created_at"`
+	UpdatedAt        time.Time      `db:"updated_at"`
+	PDFPath          string         `db:"pdf_path"`
+	DeletedAt        sql.NullTime   `db:"deleted_at"`
+	ContentHash      sql.NullString `db:"content_hash"`
+	EncryptionStatus string         `db:"encryption_status"`
+	SummaryStyle     string         `db:"summary_style"`
+	SummaryLength    string         `db:"summary_length"`
+	SummaryLanguage  string         `db:"summary_language"`
+}
+
+// BlobDAO PDF对象引用计数数据库模型
+type BlobDAO struct {
+	Hash      string    `db:"hash"`
+	ObjectKey string    `db:"object_key"`
+	Size      int64     `db:"size"`
+	RefCount  int       `db:"ref_count"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
-	PDFPath   string    `db:"pdf_path"`
+}
+
+// ReportPageDAO 报告分页文本数据库模型
+type ReportPageDAO struct {
+	ReportID   string         `db:"report_id"`
+	PageNumber int            `db:"page_number"`
+	Content    string         `db:"content"`
+	Error      sql.NullString `db:"error"`
+	CreatedAt  time.Time      `db:"created_at"`
+}
+
+// ReportMetadataDAO PDF元数据数据库模型
+type ReportMetadataDAO struct {
+	ReportID     string         `db:"report_id"`
+	Title        string         `db:"title"`
+	Author       string         `db:"author"`
+	Subject      string         `db:"subject"`
+	Keywords     string         `db:"keywords"`
+	Creator      string         `db:"creator"`
+	Producer     string         `db:"producer"`
+	CreationDate sql.NullTime   `db:"creation_date"`
+	ModDate      sql.NullTime   `db:"mod_date"`
+	PageCount    int            `db:"page_count"`
+	PageSizes    sql.NullString `db:"page_sizes"` // JSON数组
+	PDFVersion   string         `db:"pdf_version"`
+	Encrypted    bool           `db:"encrypted"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// SummaryJobDAO 摘要任务数据库模型
+type SummaryJobDAO struct {
+	ID          string         `db:"id"`
+	ReportID    string         `db:"report_id"`
+	UserID      string         `db:"user_id"`
+	Status      string         `db:"status"`
+	Style       string         `db:"summary_style"`
+	Length      string         `db:"summary_length"`
+	Language    string         `db:"summary_language"`
+	Error       sql.NullString `db:"error"`
+	ErrorCode   sql.NullInt64  `db:"error_code"`
+	RetryAfter  sql.NullInt64  `db:"retry_after"`
+	QuotaTokens sql.NullInt64  `db:"quota_reserved_tokens"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+	StartedAt   sql.NullTime   `db:"started_at"`
+	FinishedAt  sql.NullTime   `db:"finished_at"`
 }
 
 // UserDAO 用户数据库模型
@@ -23,6 +86,91 @@ type UserDAO struct {
 	Email        string    `db:"email"`
 	PasswordHash string    `db:"password_hash"`
 	Salt         string    `db:"salt"`
+	Role         string    `db:"role"`
 	CreatedAt    time.Time `db:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at"`
-}
\ No newline at end of file
+}
+
+// UserKeyDAO 用户数据密钥数据库模型，active为NULL表示已轮换停用
+type UserKeyDAO struct {
+	ID               int64        `db:"id"`
+	UserID           string       `db:"user_id"`
+	WrappedKey       string       `db:"wrapped_key"`
+	MasterKeyVersion int          `db:"master_key_version"`
+	Active           sql.NullBool `db:"active"`
+	CreatedAt        time.Time    `db:"created_at"`
+}
+
+// ReportShareDAO 报告分享链接数据库模型
+type ReportShareDAO struct {
+	ID             string         `db:"id"`
+	ReportID       string         `db:"report_id"`
+	UserID         string         `db:"user_id"`
+	TokenHash      string         `db:"token_hash"`
+	PasswordHash   sql.NullString `db:"password_hash"`
+	AllowDownload  bool           `db:"allow_download"`
+	ExpiresAt      sql.NullTime   `db:"expires_at"`
+	RevokedAt      sql.NullTime   `db:"revoked_at"`
+	AccessCount    int64          `db:"access_count"`
+	LastAccessedAt sql.NullTime   `db:"last_accessed_at"`
+	CreatedAt      time.Time      `db:"created_at"`
+}
+
+// TeamDAO 团队数据库模型
+type TeamDAO struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	OwnerID   string    `db:"owner_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ReportGrantDAO 报告授权数据库模型
+type ReportGrantDAO struct {
+	ID          string         `db:"id"`
+	ReportID    string         `db:"report_id"`
+	GranteeType string         `db:"grantee_type"`
+	GranteeID   string         `db:"grantee_id"`
+	GranteeName sql.NullString `db:"grantee_name"`
+	Role        string         `db:"role"`
+	GrantedBy   string         `db:"granted_by"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// LLMUsageDAO 大模型用量数据库模型
+type LLMUsageDAO struct {
+	ID               int64     `db:"id"`
+	UserID           string    `db:"user_id"`
+	ReportID         string    `db:"report_id"`
+	Operation        string    `db:"operation"`
+	RequestID        string    `db:"request_id"`
+	Provider         string    `db:"provider"`
+	Model            string    `db:"model"`
+	PromptTokens     int       `db:"prompt_tokens"`
+	CompletionTokens int       `db:"completion_tokens"`
+	TotalTokens      int       `db:"total_tokens"`
+	Estimated        bool      `db:"estimated"`
+	LatencyMS        int64     `db:"latency_ms"`
+	Cost             float64   `db:"cost"`
+	Status           string    `db:"status"`
+	CreatedAt        time.Time `db:"created_at"`
+}
+
+// QuotaPlanDAO 套餐配额数据库模型
+type QuotaPlanDAO struct {
+	Name            string        `db:"name"`
+	DailyRequests   sql.NullInt64 `db:"daily_requests"`
+	DailyTokens     sql.NullInt64 `db:"daily_tokens"`
+	MonthlyRequests sql.NullInt64 `db:"monthly_requests"`
+	MonthlyTokens   sql.NullInt64 `db:"monthly_tokens"`
+	UpdatedAt       time.Time     `db:"updated_at"`
+}
+
+// UserQuotaDAO 用户单独配额数据库模型
+type UserQuotaDAO struct {
+	UserID          string        `db:"user_id"`
+	DailyRequests   sql.NullInt64 `db:"daily_requests"`
+	DailyTokens     sql.NullInt64 `db:"daily_tokens"`
+	MonthlyRequests sql.NullInt64 `db:"monthly_requests"`
+	MonthlyTokens   sql.NullInt64 `db:"monthly_tokens"`
+	UpdatedAt       time.Time     `db:"updated_at"`
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IGrantRepository 报告授权仓储接口
+type IGrantRepository interface {
+	Upsert(ctx context.Context, grant *models.ReportGrant) error
+	ListByReportID(ctx context.Context, reportID string) ([]models.ReportGrant, error)
+	Delete(ctx context.Context, grantID string, reportID string) error
+	GrantedRoles(ctx context.Context, reportID string, userID string) ([]string, error)
+	ListSharedWithUser(ctx context.Context, userID string) ([]models.SharedReportItem, error)
+}
+
+// ErrGrantNotFound 授权不存在
+var ErrGrantNotFound = errors.New("授权不存在")
+
+// grantedToUser 授予用户本人或其所在团队的条件，参数依次为两次用户ID
+const grantedToUser = `((g.grantee_type = 'user' AND g.grantee_id = ?)
+	OR (g.grantee_type = 'team' AND g.grantee_id IN (SELECT team_id FROM team_members WHERE user_id = ?)))`
+
+// GrantRepository 报告授权仓储实现
+type GrantRepository struct {
+	db *sql.DB
+}
+
+// NewGrantRepository 创建报告授权仓储实例
+func NewGrantRepository(db *sql.DB) *GrantRepository {
+	return &GrantRepository{db: db}
+}
+
+// Upsert 保存授权，同一对象已有授权时更新角色，并回填授权ID和创建时间
+func (r *GrantRepository) Upsert(ctx context.Context, grant *models.ReportGrant) error {
+	query := `INSERT INTO report_grants (id, report_id, grantee_type, grantee_id, role, granted_by, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE role = VALUES(role), granted_by = VALUES(granted_by)`
+	_, err := r.db.ExecContext(ctx, query, grant.ID, grant.ReportID, grant.GranteeType, grant.GranteeID,
+		grant.Role, grant.GrantedBy, grant.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存报告授权失败: %w", err)
+	}
+
+	query = `SELECT id, created_at FROM report_grants WHERE report_id = ? AND grantee_type = ? AND grantee_id = ?`
+	err = r.db.QueryRowContext(ctx, query, grant.ReportID, grant.GranteeType, grant.GranteeID).Scan(&grant.ID, &grant.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("查询报告授权失败: %w", err)
+	}
+	return nil
+}
+
+// ListByReportID 按创建时间列出报告的全部授权，包括被授权用户或团队的名称
+func (r *GrantRepository) ListByReportID(ctx context.Context, reportID string) ([]models.ReportGrant, error) {
+	query := `SELECT g.id, g.report_id, g.grantee_type, g.grantee_id, COALESCE(u.name, t.name), g.role, g.granted_by, g.created_at
+	          FROM report_grants g
+	          LEFT JOIN users u ON g.grantee_type = 'user' AND u.id = g.grantee_id
+	          LEFT JOIN teams t ON g.grantee_type = 'team' AND t.id = g.grantee_id
+	          WHERE g.report_id = ? ORDER BY g.created_at, g.id`
+	rows, err := r.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告授权失败: %w", err)
+	}
+	defer rows.Close()
+
+	grants := []models.ReportGrant{}
+	for rows.Next() {
+		var grantDAO dao_models.ReportGrantDAO
+		err := rows.Scan(&grantDAO.ID, &grantDAO.ReportID, &grantDAO.GranteeType, &grantDAO.GranteeID,
+			&grantDAO.GranteeName, &grantDAO.Role, &grantDAO.GrantedBy, &grantDAO.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描报告授权数据失败: %w", err)
+		}
+		grants = append(grants, models.ReportGrant{
+			ID:          grantDAO.ID,
+			ReportID:    grantDAO.ReportID,
+			GranteeType: grantDAO.GranteeType,
+			GranteeID:   grantDAO.GranteeID,
+			GranteeName: grantDAO.GranteeName.String,
+			Role:        grantDAO.Role,
+			GrantedBy:   grantDAO.GrantedBy,
+			CreatedAt:   grantDAO.CreatedAt,
+		})
+	}
+	return grants, rows.Err()
+}
+
+// Delete 删除报告的授权
+func (r *GrantRepository) Delete(ctx context.Context, grantID string, reportID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM report_grants WHERE id = ? AND report_id = ?`, grantID, reportID)
+	if err != nil {
+		return fmt.Errorf("删除报告授权失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("删除报告授权失败: %w", err)
+	}
+	if affected == 0 {
+		return ErrGrantNotFound
+	}
+	return nil
+}
+
+// GrantedRoles 返回报告直接授予用户或通过团队授予用户的全部角色
+func (r *GrantRepository) GrantedRoles(ctx context.Context, reportID string, userID string) ([]string, error) {
+	query := `SELECT g.role FROM report_grants g WHERE g.report_id = ? AND ` + grantedToUser
+	rows, err := r.db.QueryContext(ctx, query, reportID, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告授权失败: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []string
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("扫描报告授权数据失败: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// ListSharedWithUser 列出其他用户共享给该用户的未删除报告，按报告ID和共享时间排序
+// 同一报告通过多个授权共享时每个授权返回一条，Role为该授权的角色
+func (r *GrantRepository) ListSharedWithUser(ctx context.Context, userID string) ([]models.SharedReportItem, error) {
+	query := `SELECT rp.id, rp.title, rp.user_id, u.name, g.role, rp.summary IS NOT NULL AND rp.summary <> '', rp.created_at, g.created_at
+	          FROM report_grants g
+	          JOIN reports rp ON rp.id = g.report_id
+	          JOIN users u ON u.id = rp.user_id
+	          WHERE rp.deleted_at IS NULL AND rp.user_id <> ? AND ` + grantedToUser + `
+	          ORDER BY rp.id, g.created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询共享报告失败: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.SharedReportItem{}
+	for rows.Next() {
+		var item models.SharedReportItem
+		err := rows.Scan(&item.ReportID, &item.Title, &item.OwnerID, &item.OwnerName, &item.Role,
+			&item.HasSummary, &item.CreatedAt, &item.SharedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描共享报告数据失败: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// ErrJobNotFound 任务不存在，或不属于当前用户
+var ErrJobNotFound = errors.New("任务不存在或无权访问")
+
+// IJobRepository 摘要任务仓储接口
+type IJobRepository interface {
+	Create(ctx context.Context, job *models.SummaryJob) error
+	GetByID(ctx context.Context, jobID string, userID string) (*models.SummaryJob, error)
+	GetActiveByReportID(ctx context.Context, reportID string, userID string) (*models.SummaryJob, error)
+	ListQueued(ctx context.Context, limit int) ([]models.SummaryJob, error)
+	MarkRunning(ctx context.Context, jobID string) (bool, error)
+	MarkSucceeded(ctx context.Context, jobID string) error
+	// MarkFailed 将任务标记为失败，记录失败原因、错误码和建议的等待秒数，retryAfter为nil表示没有建议
+	MarkFailed(ctx context.Context, jobID string, errMsg string, errorCode int, retryAfter *int) error
+	RequeueRunning(ctx context.Context) (int64, error)
+}
+
+// JobRepository 摘要任务仓储实现
+type JobRepository struct {
+	db *sql.DB
+}
+
+// NewJobRepository 创建摘要任务仓储实例
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+const jobColumns = `id, report_id, user_id, status, summary_style, summary_length, summary_language, error,
+	error_code, retry_after, quota_reserved_tokens, created_at, updated_at, started_at, finished_at`
+
+// Create 创建新任务
+func (r *JobRepository) Create(ctx context.Context, job *models.SummaryJob) error {
+	query := `INSERT INTO summary_jobs (id, report_id, user_id, status, summary_style, summary_length, summary_language,
+	          quota_reserved_tokens, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, job.ID, job.ReportID, job.UserID, job.Status,
+		job.Options.Style, job.Options.Length, job.Options.Language, nullInt64(job.QuotaTokens), job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存摘要任务失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据任务ID和用户ID获取任务
+func (r *JobRepository) GetByID(ctx context.Context, jobID string, userID string) (*models.SummaryJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM summary_jobs WHERE id = ? AND user_id = ?`
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, jobID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("查询摘要任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// GetActiveByReportID 获取用户为报告创建的尚未完成的任务，不存在时返回nil
+// 共享报告的每个用户各自创建任务、各自预留配额，不返回其他用户的任务
+func (r *JobRepository) GetActiveByReportID(ctx context.Context, reportID string, userID string) (*models.SummaryJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM summary_jobs
+	          WHERE report_id = ? AND user_id = ? AND status IN (?, ?) ORDER BY created_at DESC LIMIT 1`
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, reportID, userID, models.JobStatusQueued, models.JobStatusRunning))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询摘要任务失败: %w", err)
+	}
+	return job, nil
+}
+
+// ListQueued 按创建时间列出排队中的任务
+func (r *JobRepository) ListQueued(ctx context.Context, limit int) ([]models.SummaryJob, error) {
+	query := `SELECT ` + jobColumns + ` FROM summary_jobs WHERE status = ? ORDER BY created_at LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, models.JobStatusQueued, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询排队任务失败: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.SummaryJob
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描任务数据失败: %w", err)
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// MarkRunning 将排队中的任务标记为运行中，返回是否抢占成功
+func (r *JobRepository) MarkRunning(ctx context.Context, jobID string) (bool, error) {
+	now := time.Now()
+	query := `UPDATE summary_jobs SET status = ?, started_at = ?, updated_at = ? WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(ctx, query, models.JobStatusRunning, now, now, jobID, models.JobStatusQueued)
+	if err != nil {
+		return false, fmt.Errorf("更新任务状态失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("更新任务状态失败: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// MarkSucceeded 将任务标记为成功
+func (r *JobRepository) MarkSucceeded(ctx context.Context, jobID string) error {
+	return r.finish(ctx, jobID, models.JobStatusSucceeded, sql.NullString{}, sql.NullInt64{}, sql.NullInt64{})
+}
+
+// MarkFailed 将任务标记为失败并记录原因
+func (r *JobRepository) MarkFailed(ctx context.Context, jobID string, errMsg string, errorCode int, retryAfter *int) error {
+	var retry sql.NullInt64
+	if retryAfter != nil {
+		retry = sql.NullInt64{Int64: int64(*retryAfter), Valid: true}
+	}
+	return r.finish(ctx, jobID, models.JobStatusFailed, sql.NullString{String: errMsg, Valid: true},
+		sql.NullInt64{Int64: int64(errorCode), Valid: true}, retry)
+}
+
+// RequeueRunning 将运行中的任务重新放回队列，用于服务重启后恢复中断的任务
+func (r *JobRepository) RequeueRunning(ctx context.Context) (int64, error) {
+	query := `UPDATE summary_jobs SET status = ?, started_at = NULL, updated_at = ? WHERE status = ?`
+	result, err := r.db.ExecContext(ctx, query, models.JobStatusQueued, time.Now(), models.JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("恢复中断任务失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *JobRepository) finish(ctx context.Context, jobID string, status string, errMsg sql.NullString, errorCode, retryAfter sql.NullInt64) error {
+	now := time.Now()
+	query := `UPDATE summary_jobs SET status = ?, error = ?, error_code = ?, retry_after = ?, finished_at = ?, updated_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, status, errMsg, errorCode, retryAfter, now, now, jobID); err != nil {
+		return fmt.Errorf("更新任务状态失败: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*models.SummaryJob, error) {
+	jobDAO := &dao_models.SummaryJobDAO{}
+	err := row.Scan(&jobDAO.ID, &jobDAO.ReportID, &jobDAO.UserID, &jobDAO.Status,
+		&jobDAO.Style, &jobDAO.Length, &jobDAO.Language, &jobDAO.Error, &jobDAO.ErrorCode, &jobDAO.RetryAfter, &jobDAO.QuotaTokens, &jobDAO.CreatedAt, &jobDAO.UpdatedAt, &jobDAO.StartedAt, &jobDAO.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.SummaryJob{
+		ID:          jobDAO.ID,
+		ReportID:    jobDAO.ReportID,
+		UserID:      jobDAO.UserID,
+		Status:      jobDAO.Status,
+		Options:     models.SummaryOptions{Style: jobDAO.Style, Length: jobDAO.Length, Language: jobDAO.Language},
+		Error:       jobDAO.Error.String,
+		ErrorCode:   int(jobDAO.ErrorCode.Int64),
+		QuotaTokens: int64Ptr(jobDAO.QuotaTokens),
+		CreatedAt:   jobDAO.CreatedAt,
+		UpdatedAt:   jobDAO.UpdatedAt,
+	}
+	if jobDAO.RetryAfter.Valid {
+		retryAfter := int(jobDAO.RetryAfter.Int64)
+		job.RetryAfter = &retryAfter
+	}
+	if jobDAO.StartedAt.Valid {
+		job.StartedAt = &jobDAO.StartedAt.Time
+	}
+	if jobDAO.FinishedAt.Valid {
+		job.FinishedAt = &jobDAO.FinishedAt.Time
+	}
+	return job, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IReportMetadataRepository PDF元数据仓储接口
+type IReportMetadataRepository interface {
+	Save(ctx context.Context, metadata *models.ReportMetadata) error
+	GetByReportID(ctx context.Context, reportID string) (*models.ReportMetadata, error)
+}
+
+// ErrMetadataNotFound 报告没有PDF元数据（如元数据功能上线前上传的报告）
+var ErrMetadataNotFound = errors.New("报告没有PDF元数据")
+
+// ReportMetadataRepository PDF元数据仓储实现
+type ReportMetadataRepository struct {
+	db *sql.DB
+}
+
+// NewReportMetadataRepository 创建PDF元数据仓储实例
+func NewReportMetadataRepository(db *sql.DB) *ReportMetadataRepository {
+	return &ReportMetadataRepository{db: db}
+}
+
+// Save 保存报告的PDF元数据，已存在时覆盖
+func (r *ReportMetadataRepository) Save(ctx context.Context, metadata *models.ReportMetadata) error {
+	pageSizes, err := json.Marshal(metadata.PageSizes)
+	if err != nil {
+		return fmt.Errorf("序列化页面尺寸失败: %w", err)
+	}
+	metadataDAO := dao_models.ReportMetadataDAO{
+		ReportID:     metadata.ReportID,
+		Title:        metadata.Title,
+		Author:       metadata.Author,
+		Subject:      metadata.Subject,
+		Keywords:     metadata.Keywords,
+		Creator:      metadata.Creator,
+		Producer:     metadata.Producer,
+		CreationDate: nullTime(metadata.CreationDate),
+		ModDate:      nullTime(metadata.ModDate),
+		PageCount:    metadata.PageCount,
+		PageSizes:    sql.NullString{String: string(pageSizes), Valid: true},
+		PDFVersion:   metadata.PDFVersion,
+		Encrypted:    metadata.Encrypted,
+		CreatedAt:    time.Now(),
+	}
+
+	query := `REPLACE INTO report_metadata (report_id, title, author, subject, keywords, creator, producer,
+	              creation_date, mod_date, page_count, page_sizes, pdf_version, encrypted, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query,
+		metadataDAO.ReportID, metadataDAO.Title, metadataDAO.Author, metadataDAO.Subject, metadataDAO.Keywords,
+		metadataDAO.Creator, metadataDAO.Producer, metadataDAO.CreationDate, metadataDAO.ModDate,
+		metadataDAO.PageCount, metadataDAO.PageSizes, metadataDAO.PDFVersion, metadataDAO.Encrypted, metadataDAO.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存PDF元数据失败: %w", err)
+	}
+	return nil
+}
+
+// GetByReportID 获取报告的PDF元数据
+func (r *ReportMetadataRepository) GetByReportID(ctx context.Context, reportID string) (*models.ReportMetadata, error) {
+	query := `SELECT report_id, title, author, subject, keywords, creator, producer,
+	                 creation_date, mod_date, page_count, page_sizes, pdf_version, encrypted
+	          FROM report_metadata WHERE report_id = ?`
+	var metadataDAO dao_models.ReportMetadataDAO
+	err := r.db.QueryRowContext(ctx, query, reportID).Scan(
+		&metadataDAO.ReportID, &metadataDAO.Title, &metadataDAO.Author, &metadataDAO.Subject, &metadataDAO.Keywords,
+		&metadataDAO.Creator, &metadataDAO.Producer, &metadataDAO.CreationDate, &metadataDAO.ModDate,
+		&metadataDAO.PageCount, &metadataDAO.PageSizes, &metadataDAO.PDFVersion, &metadataDAO.Encrypted,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMetadataNotFound
+		}
+		return nil, fmt.Errorf("查询PDF元数据失败: %w", err)
+	}
+
+	metadata := &models.ReportMetadata{
+		ReportID:   metadataDAO.ReportID,
+		Title:      metadataDAO.Title,
+		Author:     metadataDAO.Author,
+		Subject:    metadataDAO.Subject,
+		Keywords:   metadataDAO.Keywords,
+		Creator:    metadataDAO.Creator,
+		Producer:   metadataDAO.Producer,
+		PageCount:  metadataDAO.PageCount,
+		PDFVersion: metadataDAO.PDFVersion,
+		Encrypted:  metadataDAO.Encrypted,
+	}
+	if metadataDAO.CreationDate.Valid {
+		metadata.CreationDate = &metadataDAO.CreationDate.Time
+	}
+	if metadataDAO.ModDate.Valid {
+		metadata.ModDate = &metadataDAO.ModDate.Time
+	}
+	if metadataDAO.PageSizes.Valid {
+		if err := json.Unmarshal([]byte(metadataDAO.PageSizes.String), &metadata.PageSizes); err != nil {
+			return nil, fmt.Errorf("解析页面尺寸失败: %w", err)
+		}
+	}
+	return metadata, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IReportPageRepository 报告分页文本仓储接口
+type IReportPageRepository interface {
+	SaveAll(ctx context.Context, reportID string, pages []models.ReportPage) error
+	GetPage(ctx context.Context, reportID string, pageNumber int) (*models.ReportPage, error)
+	GetPages(ctx context.Context, reportID string) ([]models.ReportPage, error)
+	CountPages(ctx context.Context, reportID string) (int, error)
+	ReplaceContent(ctx context.Context, reportID string, pageNumber int, old string, content string) error
+}
+
+// ErrPageNotFound 页面不存在
+var ErrPageNotFound = errors.New("页面不存在")
+
+// ReportPageRepository 报告分页文本仓储实现
+type ReportPageRepository struct {
+	db *sql.DB
+}
+
+// NewReportPageRepository 创建报告分页文本仓储实例
+func NewReportPageRepository(db *sql.DB) *ReportPageRepository {
+	return &ReportPageRepository{db: db}
+}
+
+// SaveAll 在一个事务中保存报告的全部页面，覆盖已有的页面
+func (r *ReportPageRepository) SaveAll(ctx context.Context, reportID string, pages []models.ReportPage) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM report_pages WHERE report_id = ?`, reportID); err != nil {
+		return fmt.Errorf("清理报告页面失败: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO report_pages (report_id, page_number, content, error, created_at)
+	          VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("准备插入报告页面失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, page := range pages {
+		pageDAO := dao_models.ReportPageDAO{
+			ReportID:   reportID,
+			PageNumber: page.PageNumber,
+			Content:    page.Content,
+			Error:      sql.NullString{String: page.Error, Valid: page.Error != ""},
+			CreatedAt:  page.CreatedAt,
+		}
+		if _, err := stmt.ExecContext(ctx, pageDAO.ReportID, pageDAO.PageNumber, pageDAO.Content, pageDAO.Error, pageDAO.CreatedAt); err != nil {
+			return fmt.Errorf("保存第%d页失败: %w", page.PageNumber, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交报告页面失败: %w", err)
+	}
+	return nil
+}
+
+// GetPage 获取报告的指定页面
+func (r *ReportPageRepository) GetPage(ctx context.Context, reportID string, pageNumber int) (*models.ReportPage, error) {
+	query := `SELECT report_id, page_number, content, error, created_at FROM report_pages WHERE report_id = ? AND page_number = ?`
+	page, err := scanPage(r.db.QueryRowContext(ctx, query, reportID, pageNumber))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPageNotFound
+		}
+		return nil, fmt.Errorf("查询报告页面失败: %w", err)
+	}
+	return page, nil
+}
+
+// GetPages 按页码顺序获取报告的全部页面
+func (r *ReportPageRepository) GetPages(ctx context.Context, reportID string) ([]models.ReportPage, error) {
+	query := `SELECT report_id, page_number, content, error, created_at FROM report_pages WHERE report_id = ? ORDER BY page_number`
+	rows, err := r.db.QueryContext(ctx, query, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告页面失败: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.ReportPage
+	for rows.Next() {
+		page, err := scanPage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描报告页面失败: %w", err)
+		}
+		pages = append(pages, *page)
+	}
+	return pages, rows.Err()
+}
+
+// CountPages 获取报告的页数
+func (r *ReportPageRepository) CountPages(ctx context.Context, reportID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM report_pages WHERE report_id = ?`, reportID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("统计报告页数失败: %w", err)
+	}
+	return count, nil
+}
+
+// ReplaceContent 将页面文本从old替换为content，页面已被修改时返回ErrPageNotFound
+func (r *ReportPageRepository) ReplaceContent(ctx context.Context, reportID string, pageNumber int, old string, content string) error {
+	query := `UPDATE report_pages SET content = ? WHERE report_id = ? AND page_number = ? AND COALESCE(content, '') = ?`
+	result, err := r.db.ExecContext(ctx, query, content, reportID, pageNumber, old)
+	if err != nil {
+		return fmt.Errorf("更新报告页面失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("更新报告页面失败: %w", err)
+	}
+	if affected == 0 {
+		return ErrPageNotFound
+	}
+	return nil
+}
+
+func scanPage(row rowScanner) (*models.ReportPage, error) {
+	pageDAO := &dao_models.ReportPageDAO{}
+	err := row.Scan(&pageDAO.ReportID, &pageDAO.PageNumber, &pageDAO.Content, &pageDAO.Error, &pageDAO.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReportPage{
+		ReportID:   pageDAO.ReportID,
+		PageNumber: pageDAO.PageNumber,
+		Content:    pageDAO.Content,
+		Error:      pageDAO.Error.String,
+		CreatedAt:  pageDAO.CreatedAt,
+	}, nil
+}
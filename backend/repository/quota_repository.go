@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IQuotaRepository 套餐和用户配额仓储接口
+type IQuotaRepository interface {
+	ListPlans(ctx context.Context) ([]models.QuotaPlan, error)
+	GetPlan(ctx context.Context, name string) (*models.QuotaPlan, error)
+	UpsertPlan(ctx context.Context, plan *models.QuotaPlan) error
+	// GetUserQuota 获取用户的套餐、套餐配额和单独配额，套餐不存在时套餐配额为不限制
+	GetUserQuota(ctx context.Context, userID string) (*models.UserQuota, error)
+	// SetUserQuota 修改用户套餐并整体替换单独配额
+	SetUserQuota(ctx context.Context, userID string, plan string, overrides models.QuotaLimits) error
+	// Usage 获取用户在dayPeriod和monthPeriod两个统计周期内计入配额的用量
+	Usage(ctx context.Context, userID string, dayPeriod, monthPeriod string) (daily QuotaUsage, monthly QuotaUsage, err error)
+	// Reserve 锁定用户两个统计周期的用量，check通过后原子地增加一次请求和tokens个token；check返回错误时不做修改
+	Reserve(ctx context.Context, userID string, dayPeriod, monthPeriod string, tokens int64, check func(daily, monthly QuotaUsage) error) error
+	// Adjust 调整用户两个统计周期的用量，用于结算预留的token和释放失败请求占用的次数
+	Adjust(ctx context.Context, userID string, dayPeriod, monthPeriod string, requests, tokens int64) error
+}
+
+// QuotaUsage 一个统计周期内计入配额的请求数和token数，包括已预留、尚未结算的部分
+type QuotaUsage struct {
+	Requests int64
+	Tokens   int64
+}
+
+// ErrQuotaPlanNotFound 套餐不存在
+var ErrQuotaPlanNotFound = errors.New("套餐不存在")
+
+// QuotaRepository 套餐和用户配额仓储实现
+type QuotaRepository struct {
+	db *sql.DB
+}
+
+// NewQuotaRepository 创建套餐和用户配额仓储实例
+func NewQuotaRepository(db *sql.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// ListPlans 按名称列出全部套餐
+func (r *QuotaRepository) ListPlans(ctx context.Context) ([]models.QuotaPlan, error) {
+	query := `SELECT name, daily_requests, daily_tokens, monthly_requests, monthly_tokens, updated_at
+	          FROM quota_plans ORDER BY name`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("查询套餐失败: %w", err)
+	}
+	defer rows.Close()
+
+	plans := []models.QuotaPlan{}
+	for rows.Next() {
+		var planDAO dao_models.QuotaPlanDAO
+		err := rows.Scan(&planDAO.Name, &planDAO.DailyRequests, &planDAO.DailyTokens,
+			&planDAO.MonthlyRequests, &planDAO.MonthlyTokens, &planDAO.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("扫描套餐数据失败: %w", err)
+		}
+		plans = append(plans, planFromDAO(planDAO))
+	}
+	return plans, rows.Err()
+}
+
+// GetPlan 根据名称获取套餐
+func (r *QuotaRepository) GetPlan(ctx context.Context, name string) (*models.QuotaPlan, error) {
+	query := `SELECT name, daily_requests, daily_tokens, monthly_requests, monthly_tokens, updated_at
+	          FROM quota_plans WHERE name = ?`
+	var planDAO dao_models.QuotaPlanDAO
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&planDAO.Name, &planDAO.DailyRequests, &planDAO.DailyTokens,
+		&planDAO.MonthlyRequests, &planDAO.MonthlyTokens, &planDAO.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrQuotaPlanNotFound
+		}
+		return nil, fmt.Errorf("查询套餐失败: %w", err)
+	}
+	plan := planFromDAO(planDAO)
+	return &plan, nil
+}
+
+// UpsertPlan 创建或修改套餐配额
+func (r *QuotaRepository) UpsertPlan(ctx context.Context, plan *models.QuotaPlan) error {
+	query := `INSERT INTO quota_plans (name, daily_requests, daily_tokens, monthly_requests, monthly_tokens, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE daily_requests = VALUES(daily_requests), daily_tokens = VALUES(daily_tokens),
+	          monthly_requests = VALUES(monthly_requests), monthly_tokens = VALUES(monthly_tokens), updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, plan.Name, nullInt64(plan.DailyRequests), nullInt64(plan.DailyTokens),
+		nullInt64(plan.MonthlyRequests), nullInt64(plan.MonthlyTokens), plan.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存套餐失败: %w", err)
+	}
+	return nil
+}
+
+// GetUserQuota 获取用户的套餐、套餐配额和单独配额
+func (r *QuotaRepository) GetUserQuota(ctx context.Context, userID string) (*models.UserQuota, error) {
+	query := `SELECT u.plan, p.daily_requests, p.daily_tokens, p.monthly_requests, p.monthly_tokens,
+	            o.daily_requests, o.daily_tokens, o.monthly_requests, o.monthly_tokens
+	          FROM users u
+	          LEFT JOIN quota_plans p ON p.name = u.plan
+	          LEFT JOIN user_quotas o ON o.user_id = u.id
+	          WHERE u.id = ?`
+	var planDAO dao_models.QuotaPlanDAO
+	var overrideDAO dao_models.UserQuotaDAO
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&planDAO.Name,
+		&planDAO.DailyRequests, &planDAO.DailyTokens, &planDAO.MonthlyRequests, &planDAO.MonthlyTokens,
+		&overrideDAO.DailyRequests, &overrideDAO.DailyTokens, &overrideDAO.MonthlyRequests, &overrideDAO.MonthlyTokens)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("查询用户配额失败: %w", err)
+	}
+	return &models.UserQuota{
+		UserID:     userID,
+		Plan:       planDAO.Name,
+		PlanLimits: planFromDAO(planDAO).QuotaLimits,
+		Overrides: models.QuotaLimits{
+			DailyRequests:   int64Ptr(overrideDAO.DailyRequests),
+			DailyTokens:     int64Ptr(overrideDAO.DailyTokens),
+			MonthlyRequests: int64Ptr(overrideDAO.MonthlyRequests),
+			MonthlyTokens:   int64Ptr(overrideDAO.MonthlyTokens),
+		},
+	}, nil
+}
+
+// SetUserQuota 在一个事务中修改用户套餐并替换单独配额，没有单独配额时删除记录
+func (r *QuotaRepository) SetUserQuota(ctx context.Context, userID string, plan string, overrides models.QuotaLimits) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET plan = ? WHERE id = ?`, plan, userID)
+	if err != nil {
+		return fmt.Errorf("修改用户套餐失败: %w", err)
+	}
+	// 套餐未变化时影响行数为0，需要确认用户是否存在
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM users WHERE id = ?`, userID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("查询用户失败: %w", err)
+		}
+	}
+
+	if overrides == (models.QuotaLimits{}) {
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_quotas WHERE user_id = ?`, userID)
+	} else {
+		query := `INSERT INTO user_quotas (user_id, daily_requests, daily_tokens, monthly_requests, monthly_tokens, updated_at)
+		          VALUES (?, ?, ?, ?, ?, ?)
+		          ON DUPLICATE KEY UPDATE daily_requests = VALUES(daily_requests), daily_tokens = VALUES(daily_tokens),
+		          monthly_requests = VALUES(monthly_requests), monthly_tokens = VALUES(monthly_tokens), updated_at = VALUES(updated_at)`
+		_, err = tx.ExecContext(ctx, query, userID, nullInt64(overrides.DailyRequests), nullInt64(overrides.DailyTokens),
+			nullInt64(overrides.MonthlyRequests), nullInt64(overrides.MonthlyTokens), time.Now())
+	}
+	if err != nil {
+		return fmt.Errorf("保存用户配额失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// Usage 获取用户两个统计周期的用量，没有记录的周期用量为0
+func (r *QuotaRepository) Usage(ctx context.Context, userID string, dayPeriod, monthPeriod string) (QuotaUsage, QuotaUsage, error) {
+	query := `SELECT period, requests, tokens FROM quota_usage WHERE user_id = ? AND period IN (?, ?)`
+	rows, err := r.db.QueryContext(ctx, query, userID, dayPeriod, monthPeriod)
+	if err != nil {
+		return QuotaUsage{}, QuotaUsage{}, fmt.Errorf("查询配额用量失败: %w", err)
+	}
+	defer rows.Close()
+	daily, monthly, err := scanQuotaUsage(rows, dayPeriod)
+	if err != nil {
+		return QuotaUsage{}, QuotaUsage{}, err
+	}
+	return daily, monthly, nil
+}
+
+// Reserve 在一个事务中以 SELECT ... FOR UPDATE 锁定两个周期的计数行，并发的预留按顺序执行，不会同时通过检查
+func (r *QuotaRepository) Reserve(ctx context.Context, userID string, dayPeriod, monthPeriod string, tokens int64, check func(daily, monthly QuotaUsage) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO quota_usage (user_id, period, requests, tokens, updated_at)
+	          VALUES (?, ?, 0, 0, ?), (?, ?, 0, 0, ?)`, userID, dayPeriod, now, userID, monthPeriod, now)
+	if err != nil {
+		return fmt.Errorf("初始化配额用量失败: %w", err)
+	}
+
+	query := `SELECT period, requests, tokens FROM quota_usage WHERE user_id = ? AND period IN (?, ?) FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, userID, dayPeriod, monthPeriod)
+	if err != nil {
+		return fmt.Errorf("查询配额用量失败: %w", err)
+	}
+	daily, monthly, err := scanQuotaUsage(rows, dayPeriod)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if err := check(daily, monthly); err != nil {
+		return err
+	}
+
+	query = `UPDATE quota_usage SET requests = requests + 1, tokens = tokens + ?, updated_at = ?
+	         WHERE user_id = ? AND period IN (?, ?)`
+	if _, err := tx.ExecContext(ctx, query, tokens, now, userID, dayPeriod, monthPeriod); err != nil {
+		return fmt.Errorf("预留配额失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// Adjust 调整两个周期的用量，结果不小于0
+func (r *QuotaRepository) Adjust(ctx context.Context, userID string, dayPeriod, monthPeriod string, requests, tokens int64) error {
+	query := `UPDATE quota_usage SET requests = GREATEST(requests + ?, 0), tokens = GREATEST(tokens + ?, 0), updated_at = ?
+	          WHERE user_id = ? AND period IN (?, ?)`
+	if _, err := r.db.ExecContext(ctx, query, requests, tokens, time.Now(), userID, dayPeriod, monthPeriod); err != nil {
+		return fmt.Errorf("结算配额用量失败: %w", err)
+	}
+	return nil
+}
+
+// scanQuotaUsage 扫描period、requests、tokens三列，period为dayPeriod的是当天用量，其余为当月用量
+func scanQuotaUsage(rows *sql.Rows, dayPeriod string) (daily QuotaUsage, monthly QuotaUsage, err error) {
+	for rows.Next() {
+		var period string
+		var usage QuotaUsage
+		if err := rows.Scan(&period, &usage.Requests, &usage.Tokens); err != nil {
+			return QuotaUsage{}, QuotaUsage{}, fmt.Errorf("扫描配额用量失败: %w", err)
+		}
+		if period == dayPeriod {
+			daily = usage
+		} else {
+			monthly = usage
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return QuotaUsage{}, QuotaUsage{}, fmt.Errorf("扫描配额用量失败: %w", err)
+	}
+	return daily, monthly, nil
+}
+
+func planFromDAO(planDAO dao_models.QuotaPlanDAO) models.QuotaPlan {
+	return models.QuotaPlan{
+		Name: planDAO.Name,
+		QuotaLimits: models.QuotaLimits{
+			DailyRequests:   int64Ptr(planDAO.DailyRequests),
+			DailyTokens:     int64Ptr(planDAO.DailyTokens),
+			MonthlyRequests: int64Ptr(planDAO.MonthlyRequests),
+			MonthlyTokens:   int64Ptr(planDAO.MonthlyTokens),
+		},
+		UpdatedAt: planDAO.UpdatedAt,
+	}
+}
+
+func nullInt64(n *int64) sql.NullInt64 {
+	if n == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *n, Valid: true}
+}
+
+func int64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
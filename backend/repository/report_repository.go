@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/qujing226/pdf-enhancer/backend/models"
@@ -14,11 +15,32 @@ import (
 // IReportRepository 报告仓储接口
 type IReportRepository interface {
 	Create(ctx context.Context, report *models.Report) error
-	GetByID(ctx context.Context, reportID string, userID string) (*models.Report, error)
-	GetByUserID(ctx context.Context, userID string) ([]models.ReportListItem, error)
-	UpdateSummary(ctx context.Context, reportID string, summary string) error
+	GetByID(ctx context.Context, reportID string) (*models.Report, error)
+	ListByUserID(ctx context.Context, userID string, query models.ReportListQuery, after *models.ReportListCursor) ([]models.ReportListItem, error)
+	CountByUserID(ctx context.Context, userID string, query models.ReportListQuery) (int, error)
+	// UpdateSummary 更新报告摘要和生成摘要时使用的选项
+	UpdateSummary(ctx context.Context, reportID string, summary string, opts models.SummaryOptions) error
+	Search(ctx context.Context, userID string, query string, limit int) ([]models.ReportSearchMatch, error)
+	SearchTitles(ctx context.Context, userID string, query string, limit int) ([]models.ReportSearchMatch, error)
+	UpdateTitle(ctx context.Context, reportID string, title string) error
+	Unlock(ctx context.Context, report *models.Report) error
+	SoftDelete(ctx context.Context, reportID string) error
+	Restore(ctx context.Context, reportID string, userID string) error
+	ListDeleted(ctx context.Context, userID string) ([]models.TrashItem, error)
+	ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]models.Report, error)
+	Purge(ctx context.Context, reportID string, deletedBefore time.Time) error
+	ListPDFPaths(ctx context.Context) ([]models.Report, error)
+	GetByContentHash(ctx context.Context, userID string, contentHash string) (*models.Report, error)
+	GetContentByHash(ctx context.Context, contentHash string) (*models.Report, error)
+	ListUserIDs(ctx context.Context) ([]string, error)
+	ListTextByUserID(ctx context.Context, userID string, afterID string, limit int) ([]models.Report, error)
+	ReplaceText(ctx context.Context, old *models.Report, report *models.Report) error
+	ReplacePDF(ctx context.Context, old *models.Report, report *models.Report) error
 }
 
+// ErrReportNotFound 报告不存在、已删除或当前用户无权访问
+var ErrReportNotFound = errors.New("报告不存在或无权访问")
+
 // ReportRepository 报告仓储实现
 type ReportRepository struct {
 	db *sql.DB
@@ -33,89 +55,486 @@ func NewReportRepository(db *sql.DB) *ReportRepository {
 func (r *ReportRepository) Create(ctx context.Context, report *models.Report) error {
 	// 转换为DAO模型
 	reportDAO := &dao_models.ReportDAO{
-		ID:        report.ID,
-		UserID:    report.UserID,
-		Title:     report.Title,
-		Content:   report.Content, // 内容将存储在longtext字段中
-		Summary:   report.Summary,
-		CreatedAt: report.CreatedAt,
-		UpdatedAt: report.UpdatedAt,
-		PDFPath:   report.PDFPath,
-	}
-
-	query := `INSERT INTO reports (id, user_id, title, content, summary, created_at, updated_at, pdf_path)
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		ID:               report.ID,
+		UserID:           report.UserID,
+		Title:            report.Title,
+		Content:          report.Content, // 内容将存储在longtext字段中
+		Summary:          report.Summary,
+		CreatedAt:        report.CreatedAt,
+		UpdatedAt:        report.UpdatedAt,
+		PDFPath:          report.PDFPath,
+		ContentHash:      sql.NullString{String: report.ContentHash, Valid: report.ContentHash != ""},
+		EncryptionStatus: report.EncryptionStatus,
+	}
+	if reportDAO.EncryptionStatus == "" {
+		reportDAO.EncryptionStatus = models.EncryptionNone
+	}
+
+	query := `INSERT INTO reports (id, user_id, title, content, summary, created_at, updated_at, pdf_path, content_hash, encryption_status)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	_, err := r.db.ExecContext(ctx, query,
 		reportDAO.ID, reportDAO.UserID, reportDAO.Title, reportDAO.Content,
-		reportDAO.Summary, reportDAO.CreatedAt, reportDAO.UpdatedAt, reportDAO.PDFPath)
+		reportDAO.Summary, reportDAO.CreatedAt, reportDAO.UpdatedAt, reportDAO.PDFPath, reportDAO.ContentHash,
+		reportDAO.EncryptionStatus)
 	if err != nil {
 		return fmt.Errorf("保存报告到数据库失败: %w", err)
 	}
 	return nil
 }
 
-// GetByID 根据报告ID和用户ID获取报告
-func (r *ReportRepository) GetByID(ctx context.Context, reportID string, userID string) (*models.Report, error) {
-	query := `SELECT id, user_id, title, content, summary, created_at, updated_at, pdf_path 
-	          FROM reports WHERE id = ? AND user_id = ?`
+// GetByID 根据报告ID获取未删除的报告，调用方负责检查访问权限
+func (r *ReportRepository) GetByID(ctx context.Context, reportID string) (*models.Report, error) {
+	query := `SELECT id, user_id, title, content, summary, created_at, updated_at, pdf_path, content_hash, encryption_status,
+	            summary_style, summary_length, summary_language
+	          FROM reports WHERE id = ? AND deleted_at IS NULL`
 
 	// 使用DAO模型接收数据库数据
 	reportDAO := &dao_models.ReportDAO{}
-	err := r.db.QueryRowContext(ctx, query, reportID, userID).Scan(
+	err := r.db.QueryRowContext(ctx, query, reportID).Scan(
 		&reportDAO.ID, &reportDAO.UserID, &reportDAO.Title, &reportDAO.Content,
-		&reportDAO.Summary, &reportDAO.CreatedAt, &reportDAO.UpdatedAt, &reportDAO.PDFPath,
+		&reportDAO.Summary, &reportDAO.CreatedAt, &reportDAO.UpdatedAt, &reportDAO.PDFPath, &reportDAO.ContentHash,
+		&reportDAO.EncryptionStatus, &reportDAO.SummaryStyle, &reportDAO.SummaryLength, &reportDAO.SummaryLanguage,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			fmt.Println(err)
-			return nil, fmt.Errorf("报告不存在或无权访问")
+			return nil, ErrReportNotFound
 		}
 		return nil, fmt.Errorf("查询报告详情失败: %w", err)
 	}
 
 	// 转换为业务模型
 	report := &models.Report{
-		ID:        reportDAO.ID,
-		UserID:    reportDAO.UserID,
-		Title:     reportDAO.Title,
-		Content:   reportDAO.Content,
-		Summary:   reportDAO.Summary,
-		CreatedAt: reportDAO.CreatedAt,
-		UpdatedAt: reportDAO.UpdatedAt,
-		PDFPath:   reportDAO.PDFPath,
+		ID:               reportDAO.ID,
+		UserID:           reportDAO.UserID,
+		Title:            reportDAO.Title,
+		Content:          reportDAO.Content,
+		Summary:          reportDAO.Summary,
+		CreatedAt:        reportDAO.CreatedAt,
+		UpdatedAt:        reportDAO.UpdatedAt,
+		PDFPath:          reportDAO.PDFPath,
+		ContentHash:      reportDAO.ContentHash.String,
+		EncryptionStatus: reportDAO.EncryptionStatus,
+	}
+	if reportDAO.Summary != "" && reportDAO.SummaryStyle != "" {
+		report.SummaryOptions = &models.SummaryOptions{
+			Style:    reportDAO.SummaryStyle,
+			Length:   reportDAO.SummaryLength,
+			Language: reportDAO.SummaryLanguage,
+		}
 	}
 
 	return report, nil
 }
 
-// GetByUserID 获取用户的所有报告
-func (r *ReportRepository) GetByUserID(ctx context.Context, userID string) ([]models.ReportListItem, error) {
-	query := `SELECT id, title, created_at, summary FROM reports WHERE user_id = ? ORDER BY created_at DESC`
-	rows, err := r.db.QueryContext(ctx, query, userID)
+// ListByUserID 按条件分页获取用户的报告，after为nil时从第一页开始
+// 使用 (排序字段, id) 作为游标进行keyset分页，避免大偏移量的OFFSET扫描
+func (r *ReportRepository) ListByUserID(ctx context.Context, userID string, query models.ReportListQuery, after *models.ReportListCursor) ([]models.ReportListItem, error) {
+	column := "created_at"
+	if query.SortBy == models.ReportSortTitle {
+		column = "title"
+	}
+	direction, compare := "ASC", ">"
+	if query.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	where, args := reportListFilter(userID, query)
+	if after != nil {
+		var sortValue any = after.SortValue
+		if column == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, after.SortValue)
+			if err != nil {
+				return nil, fmt.Errorf("无效的分页游标: %w", err)
+			}
+			sortValue = t
+		}
+		where += fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND id %s ?))", column, compare, column, compare)
+		args = append(args, sortValue, sortValue, after.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT id, title, created_at, summary,
+	                 (SELECT creation_date FROM report_metadata WHERE report_metadata.report_id = reports.id) AS authored_at
+	          FROM reports WHERE %s ORDER BY %s %s, id %s LIMIT ?`,
+		where, column, direction, direction)
+	args = append(args, query.Limit)
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("查询报告列表失败: %w", err)
 	}
 	defer rows.Close()
 
-	var reports []models.ReportListItem
+	reports := []models.ReportListItem{}
 	for rows.Next() {
 		var reportItem models.ReportListItem
 		var summary sql.NullString
-		if err := rows.Scan(&reportItem.ReportID, &reportItem.Title, &reportItem.CreatedAt, &summary); err != nil {
+		var authoredAt sql.NullTime
+		if err := rows.Scan(&reportItem.ReportID, &reportItem.Title, &reportItem.CreatedAt, &summary, &authoredAt); err != nil {
 			return nil, fmt.Errorf("扫描报告数据失败: %w", err)
 		}
 		reportItem.HasSummary = summary.Valid && summary.String != ""
+		if authoredAt.Valid {
+			reportItem.AuthoredAt = &authoredAt.Time
+		}
 		reports = append(reports, reportItem)
 	}
-	return reports, nil
+	return reports, rows.Err()
 }
 
-// UpdateSummary 更新报告摘要
-func (r *ReportRepository) UpdateSummary(ctx context.Context, reportID string, summary string) error {
-	query := `UPDATE reports SET summary = ?, updated_at = ? WHERE id = ?`
-	_, err := r.db.ExecContext(ctx, query, summary, time.Now(), reportID)
+// CountByUserID 统计符合条件的报告数量
+func (r *ReportRepository) CountByUserID(ctx context.Context, userID string, query models.ReportListQuery) (int, error) {
+	where, args := reportListFilter(userID, query)
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM reports WHERE `+where, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("统计报告数量失败: %w", err)
+	}
+	return total, nil
+}
+
+// reportListFilter 构建报告列表的过滤条件
+func reportListFilter(userID string, query models.ReportListQuery) (string, []any) {
+	where := "user_id = ? AND deleted_at IS NULL"
+	args := []any{userID}
+	if query.From != nil {
+		where += " AND created_at >= ?"
+		args = append(args, *query.From)
+	}
+	if query.To != nil {
+		where += " AND created_at < ?"
+		args = append(args, *query.To)
+	}
+	if query.HasSummary != nil {
+		if *query.HasSummary {
+			where += " AND summary IS NOT NULL AND summary <> ''"
+		} else {
+			where += " AND (summary IS NULL OR summary = '')"
+		}
+	}
+
+	// PDF元数据条件
+	var metadataWhere []string
+	if query.Author != "" {
+		metadataWhere = append(metadataWhere, "author LIKE ?")
+		args = append(args, "%"+escapeLike(query.Author)+"%")
+	}
+	if query.Producer != "" {
+		metadataWhere = append(metadataWhere, "producer LIKE ?")
+		args = append(args, "%"+escapeLike(query.Producer)+"%")
+	}
+	if query.AuthoredFrom != nil {
+		metadataWhere = append(metadataWhere, "creation_date >= ?")
+		args = append(args, *query.AuthoredFrom)
+	}
+	if query.AuthoredTo != nil {
+		metadataWhere = append(metadataWhere, "creation_date < ?")
+		args = append(args, *query.AuthoredTo)
+	}
+	if len(metadataWhere) > 0 {
+		where += " AND id IN (SELECT report_id FROM report_metadata WHERE " + strings.Join(metadataWhere, " AND ") + ")"
+	}
+	return where, args
+}
+
+// escapeLike 转义LIKE模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// UpdateSummary 更新报告摘要及其选项
+func (r *ReportRepository) UpdateSummary(ctx context.Context, reportID string, summary string, opts models.SummaryOptions) error {
+	query := `UPDATE reports SET summary = ?, summary_style = ?, summary_length = ?, summary_language = ?, updated_at = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, summary, opts.Style, opts.Length, opts.Language, time.Now(), reportID)
 	if err != nil {
 		return fmt.Errorf("更新报告摘要到数据库失败: %w", err)
 	}
 	return nil
 }
+
+// Search 使用FULLTEXT索引（ngram分词）在用户的报告标题、内容和摘要中检索，按相关度排序
+func (r *ReportRepository) Search(ctx context.Context, userID string, query string, limit int) ([]models.ReportSearchMatch, error) {
+	return r.search(ctx, "title, content, summary", userID, query, limit)
+}
+
+// SearchTitles 只在报告标题中检索，用于内容和摘要已加密、无法建立全文索引的情况
+func (r *ReportRepository) SearchTitles(ctx context.Context, userID string, query string, limit int) ([]models.ReportSearchMatch, error) {
+	return r.search(ctx, "title", userID, query, limit)
+}
+
+// search 在指定的FULLTEXT索引列中检索，columns须与索引定义的列一致
+func (r *ReportRepository) search(ctx context.Context, columns string, userID string, query string, limit int) ([]models.ReportSearchMatch, error) {
+	sqlQuery := fmt.Sprintf(`SELECT id, user_id, title, content, summary, created_at, updated_at, pdf_path,
+	                    MATCH(%[1]s) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+	             FROM reports
+	             WHERE user_id = ? AND deleted_at IS NULL
+	               AND MATCH(%[1]s) AGAINST (? IN NATURAL LANGUAGE MODE)
+	             ORDER BY score DESC, created_at DESC
+	             LIMIT ?`, columns)
+	rows, err := r.db.QueryContext(ctx, sqlQuery, query, userID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("检索报告失败: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []models.ReportSearchMatch
+	for rows.Next() {
+		reportDAO := &dao_models.ReportDAO{}
+		var content, summary sql.NullString
+		var score float64
+		if err := rows.Scan(&reportDAO.ID, &reportDAO.UserID, &reportDAO.Title, &content, &summary,
+			&reportDAO.CreatedAt, &reportDAO.UpdatedAt, &reportDAO.PDFPath, &score); err != nil {
+			return nil, fmt.Errorf("扫描检索结果失败: %w", err)
+		}
+		matches = append(matches, models.ReportSearchMatch{
+			Report: models.Report{
+				ID:        reportDAO.ID,
+				UserID:    reportDAO.UserID,
+				Title:     reportDAO.Title,
+				Content:   content.String,
+				Summary:   summary.String,
+				CreatedAt: reportDAO.CreatedAt,
+				UpdatedAt: reportDAO.UpdatedAt,
+				PDFPath:   reportDAO.PDFPath,
+			},
+			Score: score,
+		})
+	}
+	return matches, rows.Err()
+}
+
+// UpdateTitle 修改报告标题
+func (r *ReportRepository) UpdateTitle(ctx context.Context, reportID string, title string) error {
+	query := `UPDATE reports SET title = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL`
+	return r.execAffectingReport(ctx, "修改报告标题失败", query, title, time.Now(), reportID)
+}
+
+// Unlock 保存加密报告解密后提取的内容，并更新PDF路径、内容哈希和加密状态
+// 只更新仍处于锁定状态的报告，并发解锁时只有一次成功，其余返回ErrReportNotFound
+func (r *ReportRepository) Unlock(ctx context.Context, report *models.Report) error {
+	query := `UPDATE reports SET content = ?, pdf_path = ?, content_hash = ?, encryption_status = ?, updated_at = ?
+	          WHERE id = ? AND user_id = ? AND encryption_status = ? AND deleted_at IS NULL`
+	return r.execAffectingReport(ctx, "保存解锁后的报告失败", query,
+		report.Content, report.PDFPath, sql.NullString{String: report.ContentHash, Valid: report.ContentHash != ""},
+		report.EncryptionStatus, time.Now(), report.ID, report.UserID, models.EncryptionLocked)
+}
+
+// ListUserIDs 列出拥有报告的全部用户，包括只有回收站中报告的用户
+func (r *ReportRepository) ListUserIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT user_id FROM reports ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告用户失败: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("扫描报告用户失败: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// ListTextByUserID 按ID顺序分批列出用户的报告内容、摘要、PDF路径和内容哈希，包括回收站中的报告，用于重新加密
+func (r *ReportRepository) ListTextByUserID(ctx context.Context, userID string, afterID string, limit int) ([]models.Report, error) {
+	query := `SELECT id, user_id, content, summary, pdf_path, content_hash FROM reports
+	          WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告内容失败: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		var content, summary, pdfPath, contentHash sql.NullString
+		reportDAO := &dao_models.ReportDAO{}
+		if err := rows.Scan(&reportDAO.ID, &reportDAO.UserID, &content, &summary, &pdfPath, &contentHash); err != nil {
+			return nil, fmt.Errorf("扫描报告内容失败: %w", err)
+		}
+		reports = append(reports, models.Report{
+			ID:          reportDAO.ID,
+			UserID:      reportDAO.UserID,
+			Content:     content.String,
+			Summary:     summary.String,
+			PDFPath:     pdfPath.String,
+			ContentHash: contentHash.String,
+		})
+	}
+	return reports, rows.Err()
+}
+
+// ReplaceText 将报告的内容和摘要从old替换为report中的值，不修改更新时间
+// old读取之后报告已被修改时不做替换并返回ErrReportNotFound
+func (r *ReportRepository) ReplaceText(ctx context.Context, old *models.Report, report *models.Report) error {
+	query := `UPDATE reports SET content = ?, summary = ? WHERE id = ? AND COALESCE(content, '') = ? AND COALESCE(summary, '') = ?`
+	return r.execAffectingReport(ctx, "更新报告内容失败", query,
+		report.Content, report.Summary, report.ID, old.Content, old.Summary)
+}
+
+// ReplacePDF 将报告的PDF路径和内容哈希从old替换为report中的值，不修改更新时间
+// old读取之后报告的PDF已被替换时不做修改并返回ErrReportNotFound
+func (r *ReportRepository) ReplacePDF(ctx context.Context, old *models.Report, report *models.Report) error {
+	query := `UPDATE reports SET pdf_path = ?, content_hash = ? WHERE id = ? AND COALESCE(pdf_path, '') = ? AND COALESCE(content_hash, '') = ?`
+	return r.execAffectingReport(ctx, "更新报告PDF失败", query,
+		report.PDFPath, sql.NullString{String: report.ContentHash, Valid: report.ContentHash != ""}, report.ID, old.PDFPath, old.ContentHash)
+}
+
+// SoftDelete 将报告移入回收站
+func (r *ReportRepository) SoftDelete(ctx context.Context, reportID string) error {
+	query := `UPDATE reports SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`
+	return r.execAffectingReport(ctx, "删除报告失败", query, time.Now(), reportID)
+}
+
+// Restore 从回收站恢复报告，回收站只属于报告的所有者
+func (r *ReportRepository) Restore(ctx context.Context, reportID string, userID string) error {
+	query := `UPDATE reports SET deleted_at = NULL, updated_at = ? WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL`
+	return r.execAffectingReport(ctx, "恢复报告失败", query, time.Now(), reportID, userID)
+}
+
+// ListDeleted 按删除时间倒序列出用户回收站中的报告
+func (r *ReportRepository) ListDeleted(ctx context.Context, userID string) ([]models.TrashItem, error) {
+	query := `SELECT id, title, created_at, deleted_at FROM reports
+	          WHERE user_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询回收站失败: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		if err := rows.Scan(&item.ReportID, &item.Title, &item.CreatedAt, &item.DeletedAt); err != nil {
+			return nil, fmt.Errorf("扫描回收站数据失败: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ListPurgeable 列出在指定时间之前删除、已超过保留期的报告
+func (r *ReportRepository) ListPurgeable(ctx context.Context, deletedBefore time.Time, limit int) ([]models.Report, error) {
+	query := `SELECT id, user_id, title, pdf_path, deleted_at, content_hash FROM reports
+	          WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY deleted_at LIMIT ?`
+	rows, err := r.db.QueryContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询待清理报告失败: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		var pdfPath sql.NullString
+		reportDAO := &dao_models.ReportDAO{}
+		if err := rows.Scan(&reportDAO.ID, &reportDAO.UserID, &reportDAO.Title, &pdfPath, &reportDAO.DeletedAt, &reportDAO.ContentHash); err != nil {
+			return nil, fmt.Errorf("扫描待清理报告失败: %w", err)
+		}
+		report := models.Report{
+			ID:          reportDAO.ID,
+			UserID:      reportDAO.UserID,
+			Title:       reportDAO.Title,
+			PDFPath:     pdfPath.String,
+			ContentHash: reportDAO.ContentHash.String,
+		}
+		if reportDAO.DeletedAt.Valid {
+			report.DeletedAt = &reportDAO.DeletedAt.Time
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// Purge 彻底删除在deletedBefore之前移入回收站的报告，分页文本、元数据、摘要任务等派生数据由外键级联删除
+// 报告已被恢复或不存在时不做删除并返回ErrReportNotFound
+func (r *ReportRepository) Purge(ctx context.Context, reportID string, deletedBefore time.Time) error {
+	query := `DELETE FROM reports WHERE id = ? AND deleted_at IS NOT NULL AND deleted_at < ?`
+	return r.execAffectingReport(ctx, "彻底删除报告失败", query, reportID, deletedBefore)
+}
+
+// ListPDFPaths 列出所有报告（含回收站中的）的ID、所属用户和PDF路径，用于与对象存储对账
+func (r *ReportRepository) ListPDFPaths(ctx context.Context) ([]models.Report, error) {
+	query := `SELECT id, user_id, pdf_path, created_at, deleted_at FROM reports ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("查询报告文件路径失败: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []models.Report
+	for rows.Next() {
+		var pdfPath sql.NullString
+		reportDAO := &dao_models.ReportDAO{}
+		if err := rows.Scan(&reportDAO.ID, &reportDAO.UserID, &pdfPath, &reportDAO.CreatedAt, &reportDAO.DeletedAt); err != nil {
+			return nil, fmt.Errorf("扫描报告文件路径失败: %w", err)
+		}
+		report := models.Report{
+			ID:        reportDAO.ID,
+			UserID:    reportDAO.UserID,
+			PDFPath:   pdfPath.String,
+			CreatedAt: reportDAO.CreatedAt,
+		}
+		if reportDAO.DeletedAt.Valid {
+			report.DeletedAt = &reportDAO.DeletedAt.Time
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// GetByContentHash 获取用户最早上传的、内容哈希相同的未删除报告
+func (r *ReportRepository) GetByContentHash(ctx context.Context, userID string, contentHash string) (*models.Report, error) {
+	query := `SELECT id, title, created_at FROM reports
+	          WHERE user_id = ? AND content_hash = ? AND deleted_at IS NULL ORDER BY created_at, id LIMIT 1`
+	reportDAO := &dao_models.ReportDAO{}
+	err := r.db.QueryRowContext(ctx, query, userID, contentHash).Scan(&reportDAO.ID, &reportDAO.Title, &reportDAO.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportNotFound
+		}
+		return nil, fmt.Errorf("按内容哈希查询报告失败: %w", err)
+	}
+	return &models.Report{
+		ID:          reportDAO.ID,
+		UserID:      userID,
+		Title:       reportDAO.Title,
+		CreatedAt:   reportDAO.CreatedAt,
+		ContentHash: contentHash,
+	}, nil
+}
+
+// GetContentByHash 获取任一内容哈希相同的报告（含其他用户和回收站中的）的ID和提取文本，用于复用文本提取结果
+func (r *ReportRepository) GetContentByHash(ctx context.Context, contentHash string) (*models.Report, error) {
+	query := `SELECT id, content FROM reports WHERE content_hash = ? ORDER BY created_at, id LIMIT 1`
+	var content sql.NullString
+	reportDAO := &dao_models.ReportDAO{}
+	err := r.db.QueryRowContext(ctx, query, contentHash).Scan(&reportDAO.ID, &content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrReportNotFound
+		}
+		return nil, fmt.Errorf("按内容哈希查询报告失败: %w", err)
+	}
+	return &models.Report{
+		ID:          reportDAO.ID,
+		Content:     content.String,
+		ContentHash: contentHash,
+	}, nil
+}
+
+// execAffectingReport 执行针对单个报告的更新，没有行被更新时返回ErrReportNotFound
+func (r *ReportRepository) execAffectingReport(ctx context.Context, action string, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if affected == 0 {
+		return ErrReportNotFound
+	}
+	return nil
+}
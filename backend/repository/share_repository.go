@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IShareRepository 报告分享链接仓储接口
+type IShareRepository interface {
+	Create(ctx context.Context, share *models.ReportShare) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.ReportShare, error)
+	ListByReportID(ctx context.Context, reportID string, userID string) ([]models.ReportShare, error)
+	Revoke(ctx context.Context, shareID string, reportID string, userID string) error
+	RecordAccess(ctx context.Context, shareID string) error
+}
+
+// ErrShareNotFound 分享链接不存在、已撤销或无权访问
+var ErrShareNotFound = errors.New("分享链接不存在")
+
+// shareColumns 查询分享链接的字段
+const shareColumns = `id, report_id, user_id, token_hash, password_hash, allow_download, expires_at, revoked_at,
+	access_count, last_accessed_at, created_at`
+
+// ShareRepository 报告分享链接仓储实现
+type ShareRepository struct {
+	db *sql.DB
+}
+
+// NewShareRepository 创建报告分享链接仓储实例
+func NewShareRepository(db *sql.DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// Create 保存新的分享链接
+func (r *ShareRepository) Create(ctx context.Context, share *models.ReportShare) error {
+	query := `INSERT INTO report_shares (id, report_id, user_id, token_hash, password_hash, allow_download, expires_at, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	passwordHash := sql.NullString{String: share.PasswordHash, Valid: share.PasswordHash != ""}
+	_, err := r.db.ExecContext(ctx, query, share.ID, share.ReportID, share.UserID, share.TokenHash, passwordHash,
+		share.AllowDownload, nullTime(share.ExpiresAt), share.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存分享链接失败: %w", err)
+	}
+	return nil
+}
+
+// GetByTokenHash 根据令牌哈希获取分享链接，包括已撤销和已过期的链接
+func (r *ShareRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.ReportShare, error) {
+	query := `SELECT ` + shareColumns + ` FROM report_shares WHERE token_hash = ?`
+	share, err := scanShare(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("查询分享链接失败: %w", err)
+	}
+	return share, nil
+}
+
+// ListByReportID 按创建时间倒序列出用户为报告创建的分享链接
+func (r *ShareRepository) ListByReportID(ctx context.Context, reportID string, userID string) ([]models.ReportShare, error) {
+	query := `SELECT ` + shareColumns + ` FROM report_shares WHERE report_id = ? AND user_id = ? ORDER BY created_at DESC, id`
+	rows, err := r.db.QueryContext(ctx, query, reportID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询分享链接失败: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []models.ReportShare{}
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("扫描分享链接数据失败: %w", err)
+		}
+		shares = append(shares, *share)
+	}
+	return shares, rows.Err()
+}
+
+// Revoke 撤销分享链接，链接不存在或已撤销时返回ErrShareNotFound
+func (r *ShareRepository) Revoke(ctx context.Context, shareID string, reportID string, userID string) error {
+	query := `UPDATE report_shares SET revoked_at = ? WHERE id = ? AND report_id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), shareID, reportID, userID)
+	if err != nil {
+		return fmt.Errorf("撤销分享链接失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("撤销分享链接失败: %w", err)
+	}
+	if affected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// RecordAccess 累加分享链接的访问次数并记录访问时间
+func (r *ShareRepository) RecordAccess(ctx context.Context, shareID string) error {
+	query := `UPDATE report_shares SET access_count = access_count + 1, last_accessed_at = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, time.Now(), shareID); err != nil {
+		return fmt.Errorf("记录分享链接访问失败: %w", err)
+	}
+	return nil
+}
+
+func scanShare(row rowScanner) (*models.ReportShare, error) {
+	shareDAO := &dao_models.ReportShareDAO{}
+	err := row.Scan(&shareDAO.ID, &shareDAO.ReportID, &shareDAO.UserID, &shareDAO.TokenHash, &shareDAO.PasswordHash,
+		&shareDAO.AllowDownload, &shareDAO.ExpiresAt, &shareDAO.RevokedAt, &shareDAO.AccessCount,
+		&shareDAO.LastAccessedAt, &shareDAO.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	share := &models.ReportShare{
+		ID:            shareDAO.ID,
+		ReportID:      shareDAO.ReportID,
+		UserID:        shareDAO.UserID,
+		TokenHash:     shareDAO.TokenHash,
+		PasswordHash:  shareDAO.PasswordHash.String,
+		HasPassword:   shareDAO.PasswordHash.Valid,
+		AllowDownload: shareDAO.AllowDownload,
+		AccessCount:   shareDAO.AccessCount,
+		CreatedAt:     shareDAO.CreatedAt,
+	}
+	if shareDAO.ExpiresAt.Valid {
+		share.ExpiresAt = &shareDAO.ExpiresAt.Time
+	}
+	if shareDAO.RevokedAt.Valid {
+		share.RevokedAt = &shareDAO.RevokedAt.Time
+	}
+	if shareDAO.LastAccessedAt.Valid {
+		share.LastAccessedAt = &shareDAO.LastAccessedAt.Time
+	}
+	return share, nil
+}
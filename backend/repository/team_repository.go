@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// ITeamRepository 团队仓储接口
+type ITeamRepository interface {
+	Create(ctx context.Context, team *models.Team) error
+	GetByID(ctx context.Context, teamID string) (*models.Team, error)
+	ListByUserID(ctx context.Context, userID string) ([]models.Team, error)
+	GetMemberRole(ctx context.Context, teamID string, userID string) (string, error)
+	ListMembers(ctx context.Context, teamID string) ([]models.TeamMember, error)
+	UpsertMember(ctx context.Context, teamID string, userID string, role string) error
+	RemoveMember(ctx context.Context, teamID string, userID string) error
+}
+
+var (
+	// ErrTeamNotFound 团队不存在或当前用户不是团队成员
+	ErrTeamNotFound = errors.New("团队不存在或无权访问")
+	// ErrTeamMemberNotFound 用户不是团队成员
+	ErrTeamMemberNotFound = errors.New("用户不是团队成员")
+)
+
+// TeamRepository 团队仓储实现
+type TeamRepository struct {
+	db *sql.DB
+}
+
+// NewTeamRepository 创建团队仓储实例
+func NewTeamRepository(db *sql.DB) *TeamRepository {
+	return &TeamRepository{db: db}
+}
+
+// Create 创建团队，创建者成为团队管理员
+func (r *TeamRepository) Create(ctx context.Context, team *models.Team) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	teamDAO := dao_models.TeamDAO{ID: team.ID, Name: team.Name, OwnerID: team.OwnerID, CreatedAt: team.CreatedAt}
+	_, err = tx.ExecContext(ctx, `INSERT INTO teams (id, name, owner_id, created_at) VALUES (?, ?, ?, ?)`,
+		teamDAO.ID, teamDAO.Name, teamDAO.OwnerID, teamDAO.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存团队失败: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO team_members (team_id, user_id, role, created_at) VALUES (?, ?, ?, ?)`,
+		teamDAO.ID, teamDAO.OwnerID, models.TeamRoleAdmin, teamDAO.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存团队成员失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据ID获取团队
+func (r *TeamRepository) GetByID(ctx context.Context, teamID string) (*models.Team, error) {
+	var teamDAO dao_models.TeamDAO
+	err := r.db.QueryRowContext(ctx, `SELECT id, name, owner_id, created_at FROM teams WHERE id = ?`, teamID).
+		Scan(&teamDAO.ID, &teamDAO.Name, &teamDAO.OwnerID, &teamDAO.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTeamNotFound
+		}
+		return nil, fmt.Errorf("查询团队失败: %w", err)
+	}
+	return &models.Team{ID: teamDAO.ID, Name: teamDAO.Name, OwnerID: teamDAO.OwnerID, CreatedAt: teamDAO.CreatedAt}, nil
+}
+
+// ListByUserID 按创建时间列出用户所在的团队及其在团队中的角色
+func (r *TeamRepository) ListByUserID(ctx context.Context, userID string) ([]models.Team, error) {
+	query := `SELECT t.id, t.name, t.owner_id, t.created_at, m.role
+	          FROM team_members m JOIN teams t ON t.id = m.team_id
+	          WHERE m.user_id = ? ORDER BY t.created_at, t.id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询团队列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	teams := []models.Team{}
+	for rows.Next() {
+		var teamDAO dao_models.TeamDAO
+		var role string
+		if err := rows.Scan(&teamDAO.ID, &teamDAO.Name, &teamDAO.OwnerID, &teamDAO.CreatedAt, &role); err != nil {
+			return nil, fmt.Errorf("扫描团队数据失败: %w", err)
+		}
+		teams = append(teams, models.Team{
+			ID:        teamDAO.ID,
+			Name:      teamDAO.Name,
+			OwnerID:   teamDAO.OwnerID,
+			Role:      role,
+			CreatedAt: teamDAO.CreatedAt,
+		})
+	}
+	return teams, rows.Err()
+}
+
+// GetMemberRole 获取用户在团队中的角色，用户不是成员时返回ErrTeamNotFound
+func (r *TeamRepository) GetMemberRole(ctx context.Context, teamID string, userID string) (string, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx, `SELECT role FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrTeamNotFound
+		}
+		return "", fmt.Errorf("查询团队成员失败: %w", err)
+	}
+	return role, nil
+}
+
+// ListMembers 按加入时间列出团队成员
+func (r *TeamRepository) ListMembers(ctx context.Context, teamID string) ([]models.TeamMember, error) {
+	query := `SELECT m.team_id, m.user_id, u.name, u.email, m.role, m.created_at
+	          FROM team_members m JOIN users u ON u.id = m.user_id
+	          WHERE m.team_id = ? ORDER BY m.created_at, m.user_id`
+	rows, err := r.db.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("查询团队成员失败: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.TeamMember{}
+	for rows.Next() {
+		var member models.TeamMember
+		if err := rows.Scan(&member.TeamID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, fmt.Errorf("扫描团队成员数据失败: %w", err)
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// UpsertMember 添加团队成员，已是成员时更新角色
+func (r *TeamRepository) UpsertMember(ctx context.Context, teamID string, userID string, role string) error {
+	query := `INSERT INTO team_members (team_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+	          ON DUPLICATE KEY UPDATE role = VALUES(role)`
+	if _, err := r.db.ExecContext(ctx, query, teamID, userID, role, time.Now()); err != nil {
+		return fmt.Errorf("保存团队成员失败: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember 移除团队成员，成员通过团队获得的报告权限随之失效
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamID string, userID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("移除团队成员失败: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("移除团队成员失败: %w", err)
+	}
+	if affected == 0 {
+		return ErrTeamMemberNotFound
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IUsageRepository 大模型用量仓储接口，统计区间为 [from, to)
+type IUsageRepository interface {
+	Create(ctx context.Context, usage *models.LLMUsage) error
+	// Daily 按天汇总用量，userID为空时汇总全部用户
+	Daily(ctx context.Context, userID string, from, to time.Time) ([]models.UsageDaily, error)
+	// ByReport 按报告汇总用户的用量，按费用和token数倒序
+	ByReport(ctx context.Context, userID string, from, to time.Time) ([]models.UsageByReport, error)
+	// ByUser 按用户汇总全部用量，按费用和token数倒序
+	ByUser(ctx context.Context, from, to time.Time) ([]models.UsageByUser, error)
+}
+
+// UsageRepository 大模型用量仓储实现
+type UsageRepository struct {
+	db *sql.DB
+}
+
+// NewUsageRepository 创建大模型用量仓储实例
+func NewUsageRepository(db *sql.DB) *UsageRepository {
+	return &UsageRepository{db: db}
+}
+
+// usageAggregates 汇总列，与scanUsageTotals的字段顺序一致
+const usageAggregates = `COUNT(*), COALESCE(SUM(u.status = 'error'), 0),
+	COALESCE(SUM(u.prompt_tokens), 0), COALESCE(SUM(u.completion_tokens), 0), COALESCE(SUM(u.total_tokens), 0),
+	COALESCE(SUM(u.cost), 0)`
+
+// Create 保存一次调用的用量
+func (r *UsageRepository) Create(ctx context.Context, usage *models.LLMUsage) error {
+	usageDAO := dao_models.LLMUsageDAO{
+		UserID:           usage.UserID,
+		ReportID:         usage.ReportID,
+		Operation:        usage.Operation,
+		RequestID:        usage.RequestID,
+		Provider:         usage.Provider,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Estimated:        usage.Estimated,
+		LatencyMS:        usage.LatencyMS,
+		Cost:             usage.Cost,
+		Status:           usage.Status,
+		CreatedAt:        usage.CreatedAt,
+	}
+	query := `INSERT INTO llm_usage (user_id, report_id, operation, request_id, provider, model, prompt_tokens, completion_tokens,
+	          total_tokens, estimated, latency_ms, cost, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, usageDAO.UserID, usageDAO.ReportID, usageDAO.Operation, usageDAO.RequestID, usageDAO.Provider,
+		usageDAO.Model, usageDAO.PromptTokens, usageDAO.CompletionTokens, usageDAO.TotalTokens, usageDAO.Estimated,
+		usageDAO.LatencyMS, usageDAO.Cost, usageDAO.Status, usageDAO.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("保存大模型用量失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		usage.ID = id
+	}
+	return nil
+}
+
+// Daily 按天汇总用量
+func (r *UsageRepository) Daily(ctx context.Context, userID string, from, to time.Time) ([]models.UsageDaily, error) {
+	query := `SELECT DATE_FORMAT(u.created_at, '%Y-%m-%d') AS day, ` + usageAggregates + `
+	          FROM llm_usage u WHERE u.created_at >= ? AND u.created_at < ?`
+	args := []any{from, to}
+	if userID != "" {
+		query += ` AND u.user_id = ?`
+		args = append(args, userID)
+	}
+	query += ` GROUP BY day ORDER BY day`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询大模型用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	daily := []models.UsageDaily{}
+	for rows.Next() {
+		var item models.UsageDaily
+		if err := scanUsageTotals(rows, &item.UsageTotals, &item.Date); err != nil {
+			return nil, err
+		}
+		daily = append(daily, item)
+	}
+	return daily, rows.Err()
+}
+
+// ByReport 按报告汇总用户的用量
+func (r *UsageRepository) ByReport(ctx context.Context, userID string, from, to time.Time) ([]models.UsageByReport, error) {
+	query := `SELECT u.report_id, COALESCE(MAX(rp.title), ''), ` + usageAggregates + `
+	          FROM llm_usage u LEFT JOIN reports rp ON rp.id = u.report_id
+	          WHERE u.user_id = ? AND u.report_id <> '' AND u.created_at >= ? AND u.created_at < ?
+	          GROUP BY u.report_id ORDER BY SUM(u.cost) DESC, SUM(u.total_tokens) DESC`
+	rows, err := r.db.QueryContext(ctx, query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查询大模型用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.UsageByReport{}
+	for rows.Next() {
+		var item models.UsageByReport
+		if err := scanUsageTotals(rows, &item.UsageTotals, &item.ReportID, &item.Title); err != nil {
+			return nil, err
+		}
+		reports = append(reports, item)
+	}
+	return reports, rows.Err()
+}
+
+// ByUser 按用户汇总全部用量
+func (r *UsageRepository) ByUser(ctx context.Context, from, to time.Time) ([]models.UsageByUser, error) {
+	query := `SELECT u.user_id, COALESCE(MAX(us.name), ''), COALESCE(MAX(us.email), ''), ` + usageAggregates + `
+	          FROM llm_usage u LEFT JOIN users us ON us.id = u.user_id
+	          WHERE u.created_at >= ? AND u.created_at < ?
+	          GROUP BY u.user_id ORDER BY SUM(u.cost) DESC, SUM(u.total_tokens) DESC`
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查询大模型用量失败: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.UsageByUser{}
+	for rows.Next() {
+		var item models.UsageByUser
+		if err := scanUsageTotals(rows, &item.UsageTotals, &item.UserID, &item.Name, &item.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, item)
+	}
+	return users, rows.Err()
+}
+
+// scanUsageTotals 扫描分组列keys和汇总列
+func scanUsageTotals(rows *sql.Rows, totals *models.UsageTotals, keys ...any) error {
+	dest := append(keys, &totals.Calls, &totals.FailedCalls, &totals.PromptTokens, &totals.CompletionTokens, &totals.TotalTokens, &totals.Cost)
+	if err := rows.Scan(dest...); err != nil {
+		return fmt.Errorf("扫描大模型用量数据失败: %w", err)
+	}
+	return nil
+}
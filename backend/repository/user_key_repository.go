@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository/dao_models"
+)
+
+// IUserKeyRepository 用户数据密钥仓储接口
+type IUserKeyRepository interface {
+	GetActive(ctx context.Context, userID string) (*models.UserKey, error)
+	GetByID(ctx context.Context, id int64) (*models.UserKey, error)
+	Create(ctx context.Context, key *models.UserKey) error
+	Rotate(ctx context.Context, key *models.UserKey) error
+	ListByOtherMasterVersion(ctx context.Context, version int) ([]models.UserKey, error)
+	UpdateWrapped(ctx context.Context, id int64, wrappedKey string, version int) error
+}
+
+var (
+	// ErrUserKeyNotFound 数据密钥不存在
+	ErrUserKeyNotFound = errors.New("数据密钥不存在")
+	// ErrUserKeyExists 用户已有启用的数据密钥
+	ErrUserKeyExists = errors.New("用户已有启用的数据密钥")
+)
+
+// mysqlDuplicateEntry MySQL唯一键冲突的错误码
+const mysqlDuplicateEntry = 1062
+
+// userKeyColumns 查询数据密钥的字段
+const userKeyColumns = `id, user_id, wrapped_key, master_key_version, active, created_at`
+
+// UserKeyRepository 用户数据密钥仓储实现
+type UserKeyRepository struct {
+	db *sql.DB
+}
+
+// NewUserKeyRepository 创建用户数据密钥仓储实例
+func NewUserKeyRepository(db *sql.DB) *UserKeyRepository {
+	return &UserKeyRepository{db: db}
+}
+
+// GetActive 获取用户当前启用的数据密钥
+func (r *UserKeyRepository) GetActive(ctx context.Context, userID string) (*models.UserKey, error) {
+	query := `SELECT ` + userKeyColumns + ` FROM user_keys WHERE user_id = ? AND active = 1`
+	return r.queryOne(ctx, query, userID)
+}
+
+// GetByID 根据ID获取数据密钥，包括已停用的密钥
+func (r *UserKeyRepository) GetByID(ctx context.Context, id int64) (*models.UserKey, error) {
+	query := `SELECT ` + userKeyColumns + ` FROM user_keys WHERE id = ?`
+	return r.queryOne(ctx, query, id)
+}
+
+// Create 为用户创建启用的数据密钥并回填ID；用户已有启用的密钥时返回ErrUserKeyExists
+func (r *UserKeyRepository) Create(ctx context.Context, key *models.UserKey) error {
+	id, err := insertUserKey(ctx, r.db, key)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+			return ErrUserKeyExists
+		}
+		return fmt.Errorf("保存数据密钥失败: %w", err)
+	}
+	key.ID = id
+	return nil
+}
+
+// Rotate 停用用户当前的数据密钥并启用新密钥，回填新密钥的ID；停用的密钥仍可用于解密
+func (r *UserKeyRepository) Rotate(ctx context.Context, key *models.UserKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE user_keys SET active = NULL WHERE user_id = ? AND active = 1`, key.UserID); err != nil {
+		return fmt.Errorf("停用数据密钥失败: %w", err)
+	}
+	id, err := insertUserKey(ctx, tx, key)
+	if err != nil {
+		return fmt.Errorf("保存数据密钥失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交数据密钥失败: %w", err)
+	}
+	key.ID = id
+	return nil
+}
+
+// ListByOtherMasterVersion 列出不是由指定版本主密钥包装的数据密钥
+func (r *UserKeyRepository) ListByOtherMasterVersion(ctx context.Context, version int) ([]models.UserKey, error) {
+	query := `SELECT ` + userKeyColumns + ` FROM user_keys WHERE master_key_version <> ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query, version)
+	if err != nil {
+		return nil, fmt.Errorf("查询数据密钥失败: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.UserKey
+	for rows.Next() {
+		key, err := scanUserKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// UpdateWrapped 更新数据密钥的包装结果和主密钥版本
+func (r *UserKeyRepository) UpdateWrapped(ctx context.Context, id int64, wrappedKey string, version int) error {
+	query := `UPDATE user_keys SET wrapped_key = ?, master_key_version = ? WHERE id = ?`
+	if _, err := r.db.ExecContext(ctx, query, wrappedKey, version, id); err != nil {
+		return fmt.Errorf("更新数据密钥失败: %w", err)
+	}
+	return nil
+}
+
+// queryOne 查询单个数据密钥
+func (r *UserKeyRepository) queryOne(ctx context.Context, query string, args ...any) (*models.UserKey, error) {
+	key, err := scanUserKey(r.db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserKeyNotFound
+	}
+	return key, err
+}
+
+// insertUserKey 插入启用的数据密钥并返回ID
+func insertUserKey(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, key *models.UserKey) (int64, error) {
+	keyDAO := dao_models.UserKeyDAO{
+		UserID:           key.UserID,
+		WrappedKey:       key.WrappedKey,
+		MasterKeyVersion: key.MasterKeyVersion,
+		Active:           sql.NullBool{Bool: true, Valid: true},
+		CreatedAt:        time.Now(),
+	}
+	query := `INSERT INTO user_keys (user_id, wrapped_key, master_key_version, active, created_at) VALUES (?, ?, ?, ?, ?)`
+	result, err := db.ExecContext(ctx, query,
+		keyDAO.UserID, keyDAO.WrappedKey, keyDAO.MasterKeyVersion, keyDAO.Active, keyDAO.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	key.Active = true
+	key.CreatedAt = keyDAO.CreatedAt
+	return result.LastInsertId()
+}
+
+// scanUserKey 扫描一行数据密钥
+func scanUserKey(row rowScanner) (*models.UserKey, error) {
+	var keyDAO dao_models.UserKeyDAO
+	err := row.Scan(&keyDAO.ID, &keyDAO.UserID, &keyDAO.WrappedKey, &keyDAO.MasterKeyVersion, &keyDAO.Active, &keyDAO.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("扫描数据密钥失败: %w", err)
+	}
+	return &models.UserKey{
+		ID:               keyDAO.ID,
+		UserID:           keyDAO.UserID,
+		WrappedKey:       keyDAO.WrappedKey,
+		MasterKeyVersion: keyDAO.MasterKeyVersion,
+		Active:           keyDAO.Active.Valid && keyDAO.Active.Bool,
+		CreatedAt:        keyDAO.CreatedAt,
+	}, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/qujing226/pdf-enhancer/backend/models"
@@ -14,6 +15,9 @@ type IUserRepository interface {
 	Create(user *models.User) error
 }
 
+// ErrUserNotFound 用户不存在
+var ErrUserNotFound = errors.New("用户不存在")
+
 // UserRepository 用户仓储实现
 type UserRepository struct {
 	db *sql.DB
@@ -26,12 +30,12 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 // GetByID 根据ID获取用户
 func (r *UserRepository) GetByID(userID string) (*models.User, error) {
-	query := `SELECT id, name, email, created_at, updated_at FROM users WHERE id = ?`
+	query := `SELECT id, name, email, role, created_at, updated_at FROM users WHERE id = ?`
 	user := &models.User{}
-	err := r.db.QueryRow(query, userID).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	err := r.db.QueryRow(query, userID).Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("用户不存在")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("查询用户失败: %w", err)
 	}
@@ -40,12 +44,12 @@ func (r *UserRepository) GetByID(userID string) (*models.User, error) {
 
 // GetByEmail 根据邮箱获取用户
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	query := `SELECT id, name, email, password_hash, salt, created_at, updated_at FROM users WHERE email = ?`
+	query := `SELECT id, name, email, password_hash, salt, role, created_at, updated_at FROM users WHERE email = ?`
 	user := &models.User{}
-	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Salt, &user.CreatedAt, &user.UpdatedAt)
+	err := r.db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Salt, &user.Role, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("用户不存在")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("查询用户失败: %w", err)
 	}
@@ -54,9 +58,9 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 
 // Create 创建新用户
 func (r *UserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (id, name, email, password_hash,salt, created_at, updated_at) 
-          VALUES (?, ?, ?,?, ?, ?, ?)`
-	_, err := r.db.Exec(query, user.ID, user.Name, user.Email, user.PasswordHash, user.Salt, user.CreatedAt, user.UpdatedAt)
+	query := `INSERT INTO users (id, name, email, password_hash,salt, role, created_at, updated_at) 
+          VALUES (?, ?, ?,?, ?, ?, ?, ?)`
+	_, err := r.db.Exec(query, user.ID, user.Name, user.Email, user.PasswordHash, user.Salt, user.Role, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("创建用户失败: %w", err)
 	}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/services"
+)
+
+// runRotateKeys 执行密钥轮换子命令：go run . rotate-keys [-data]
+// 默认以ENCRYPTION_ACTIVE_KEY_VERSION（未设置时为最大版本）的主密钥重新包装全部数据密钥，完成后可从配置中移除旧主密钥
+// 指定-data时再为每个用户生成新的数据密钥，并重新加密其报告内容、摘要、分页文本和PDF
+func runRotateKeys(args []string) {
+	fs := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	data := fs.Bool("data", false, "为每个用户生成新的数据密钥并重新加密全部数据")
+	fs.Parse(args)
+
+	db, err := initDB()
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer db.Close()
+
+	keyService := initKeyService(db)
+	if keyService == nil {
+		log.Fatal("未配置ENCRYPTION_MASTER_KEYS，无法轮换密钥")
+	}
+
+	blobStore, err := initMinIO()
+	if err != nil {
+		log.Fatalf("对象存储初始化失败: %v", err)
+	}
+
+	rekeyService := services.NewRekeyService(keyService, repository.NewReportRepository(db), repository.NewReportPageRepository(db), repository.NewBlobRepository(db), blobStore)
+	result, err := rekeyService.Rekey(context.Background(), *data)
+	log.Printf("重新包装数据密钥%d个", result.RewrappedKeys)
+	if *data {
+		log.Printf("轮换用户数据密钥%d个，重新加密报告%d个、页面%d个、PDF%d个，迁移明文PDF的报告%d个，跳过%d个",
+			result.RotatedUsers, result.Reports, result.Pages, result.Objects, result.MigratedPDFs, result.Skipped)
+	}
+	if err != nil {
+		log.Fatalf("密钥轮换失败: %v", err)
+	}
+}
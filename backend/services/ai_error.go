@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+)
+
+// AIErrorStatus 将大模型服务和配额的错误映射为HTTP状态码，其他错误返回500
+// 同步接口的响应、流式摘要的error事件和异步任务的error_code使用同一映射
+func AIErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrQuotaExceeded), errors.Is(err, llm.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, llm.ErrContextTooLong):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, llm.ErrAuthFailed), errors.Is(err, llm.ErrInsufficientBalance):
+		// 服务端的API密钥无效或账户余额不足，不是当前用户的问题
+		return http.StatusBadGateway
+	case errors.Is(err, llm.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// AIErrorRetryAfter 建议的等待时间：超出配额时为距离配额重置的时间，大模型服务要求等待时为其Retry-After，没有时返回0
+func AIErrorRetryAfter(err error) time.Duration {
+	var exceeded *QuotaExceededError
+	if errors.As(err, &exceeded) {
+		return max(time.Until(exceeded.ResetAt), time.Second)
+	}
+	return llm.RetryAfter(err)
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+)
+
+func TestAIErrorStatus(t *testing.T) {
+	exceeded := fmt.Errorf("生成摘要失败: %w", &QuotaExceededError{Limit: QuotaDailyRequests, ResetAt: time.Now().Add(time.Hour)})
+	assert.Equal(t, http.StatusTooManyRequests, AIErrorStatus(exceeded))
+	assert.InDelta(t, time.Hour.Seconds(), AIErrorRetryAfter(exceeded).Seconds(), 5)
+
+	limited := &llm.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second, Kind: llm.ErrRateLimited}
+	assert.Equal(t, http.StatusTooManyRequests, AIErrorStatus(limited))
+	assert.Equal(t, 30*time.Second, AIErrorRetryAfter(limited))
+
+	balance := &llm.APIError{StatusCode: http.StatusPaymentRequired, Kind: llm.ErrInsufficientBalance}
+	assert.Equal(t, http.StatusBadGateway, AIErrorStatus(balance))
+	assert.Zero(t, AIErrorRetryAfter(balance))
+
+	assert.Equal(t, http.StatusServiceUnavailable, AIErrorStatus(llm.ErrCircuitOpen))
+	assert.Equal(t, http.StatusInternalServerError, AIErrorStatus(fmt.Errorf("报告不存在")))
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// ErrInvalidGrant 授权参数无效
+var ErrInvalidGrant = errors.New("无效的授权参数")
+
+// GrantService 报告授权服务，管理共享给其他用户和团队的报告权限
+type GrantService struct {
+	grantRepo     repository.IGrantRepository
+	teamRepo      repository.ITeamRepository
+	userRepo      repository.IUserRepository
+	reportService *ReportService
+}
+
+// NewGrantService 创建报告授权服务
+func NewGrantService(grantRepo repository.IGrantRepository, teamRepo repository.ITeamRepository, userRepo repository.IUserRepository, reportService *ReportService) *GrantService {
+	return &GrantService{
+		grantRepo:     grantRepo,
+		teamRepo:      teamRepo,
+		userRepo:      userRepo,
+		reportService: reportService,
+	}
+}
+
+// GrantAccess 将报告共享给用户或团队，已共享时更新角色；只有所有者可以授权，团队授权要求所有者是该团队成员
+func (s *GrantService) GrantAccess(ctx context.Context, reportID string, userID string, req models.GrantReportRequest) (*models.ReportGrant, error) {
+	report, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage)
+	if err != nil {
+		return nil, err
+	}
+	if !authz.Role(req.Role).IsGrantable() {
+		return nil, fmt.Errorf("%w: 角色只能是viewer或editor", ErrInvalidGrant)
+	}
+
+	grant := &models.ReportGrant{
+		ID:          uuid.New().String(),
+		ReportID:    report.ID,
+		GranteeType: req.GranteeType,
+		Role:        req.Role,
+		GrantedBy:   userID,
+		CreatedAt:   time.Now(),
+	}
+	switch req.GranteeType {
+	case models.GranteeUser:
+		grantee, err := s.userRepo.GetByEmail(strings.TrimSpace(req.Email))
+		if err != nil {
+			return nil, err
+		}
+		if grantee.ID == report.UserID {
+			return nil, fmt.Errorf("%w: 不能共享给报告所有者", ErrInvalidGrant)
+		}
+		grant.GranteeID, grant.GranteeName = grantee.ID, grantee.Name
+	case models.GranteeTeam:
+		if _, err := s.teamRepo.GetMemberRole(ctx, req.TeamID, userID); err != nil {
+			return nil, err
+		}
+		grant.GranteeID = req.TeamID
+	default:
+		return nil, fmt.Errorf("%w: 授权对象只能是user或team", ErrInvalidGrant)
+	}
+
+	if err := s.grantRepo.Upsert(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// ListGrants 列出报告的全部授权，只有所有者可以查看
+func (s *GrantService) ListGrants(ctx context.Context, reportID string, userID string) ([]models.ReportGrant, error) {
+	if _, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return nil, err
+	}
+	return s.grantRepo.ListByReportID(ctx, reportID)
+}
+
+// RevokeGrant 取消授权，被授权的用户或团队立即失去访问权限
+func (s *GrantService) RevokeGrant(ctx context.Context, reportID string, grantID string, userID string) error {
+	if _, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return err
+	}
+	return s.grantRepo.Delete(ctx, grantID, reportID)
+}
+
+// ListSharedWithMe 列出其他用户直接共享或通过团队共享给该用户的报告，按最近共享时间倒序
+// 同一报告通过多个授权共享时只返回一条，角色取权限最高的一个，共享时间取最早的一次
+func (s *GrantService) ListSharedWithMe(ctx context.Context, userID string) ([]models.SharedReportItem, error) {
+	shared, err := s.grantRepo.ListSharedWithUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return mergeSharedReports(shared), nil
+}
+
+// mergeSharedReports 合并同一报告的多条授权，items需按报告ID和共享时间排序
+func mergeSharedReports(items []models.SharedReportItem) []models.SharedReportItem {
+	merged := []models.SharedReportItem{}
+	for _, item := range items {
+		if n := len(merged); n > 0 && merged[n-1].ReportID == item.ReportID {
+			merged[n-1].Role = string(authz.MaxRole(authz.Role(merged[n-1].Role), authz.Role(item.Role)))
+			continue
+		}
+		merged = append(merged, item)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].SharedAt.After(merged[j].SharedAt) })
+	return merged
+}
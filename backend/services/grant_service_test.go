@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+func TestMergeSharedReports(t *testing.T) {
+	t1 := time.Date(2025, 4, 20, 9, 0, 0, 0, time.UTC)
+	t2, t3 := t1.Add(time.Hour), t1.Add(2*time.Hour)
+	merged := mergeSharedReports([]models.SharedReportItem{
+		{ReportID: "r1", Role: "editor", SharedAt: t1},
+		{ReportID: "r1", Role: "viewer", SharedAt: t3},
+		{ReportID: "r2", Role: "viewer", SharedAt: t2},
+		{ReportID: "r2", Role: "editor", SharedAt: t3},
+	})
+
+	// 角色取最高的一个，共享时间取最早的一次，按共享时间倒序
+	assert.Equal(t, []models.SharedReportItem{
+		{ReportID: "r2", Role: "editor", SharedAt: t2},
+		{ReportID: "r1", Role: "editor", SharedAt: t1},
+	}, merged)
+}
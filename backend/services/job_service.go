@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// ErrSummaryJobConflict 报告已有选项不同的未完成摘要任务
+var ErrSummaryJobConflict = errors.New("报告已有使用其他选项的摘要任务正在进行")
+
+// JobConfig 异步任务配置
+type JobConfig struct {
+	Workers      int           // 工作协程数量
+	QueueSize    int           // 内存队列容量，队列满时任务留在数据库中等待轮询
+	PollInterval time.Duration // 轮询数据库中排队任务的间隔
+	JobTimeout   time.Duration // 单个任务的最长执行时间
+}
+
+// JobService 异步摘要任务服务，任务持久化在数据库中，由工作协程池执行
+type JobService struct {
+	jobRepo       repository.IJobRepository
+	reportService *ReportService
+	config        JobConfig
+	queue         chan models.SummaryJob
+
+	// pending 已放入内存队列、尚未被工作协程取出的任务ID，轮询时跳过这些任务，避免同一任务重复入队
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+// NewJobService 创建新的任务服务
+func NewJobService(jobRepo repository.IJobRepository, reportService *ReportService, config JobConfig) *JobService {
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = 30 * time.Second
+	}
+	if config.JobTimeout <= 0 {
+		config.JobTimeout = 10 * time.Minute
+	}
+	return &JobService{
+		jobRepo:       jobRepo,
+		reportService: reportService,
+		config:        config,
+		queue:         make(chan models.SummaryJob, config.QueueSize),
+		pending:       make(map[string]struct{}),
+	}
+}
+
+// Start 恢复中断的任务并启动工作协程，ctx取消时停止
+func (s *JobService) Start(ctx context.Context) error {
+	requeued, err := s.jobRepo.RequeueRunning(ctx)
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		log.Printf("已恢复%d个中断的摘要任务", requeued)
+	}
+
+	for i := 0; i < s.config.Workers; i++ {
+		go s.worker(ctx)
+	}
+	go s.poll(ctx)
+
+	log.Printf("摘要任务工作池已启动，工作协程数: %d", s.config.Workers)
+	return nil
+}
+
+// EnqueueSummary 为报告创建摘要任务，用户在该报告上已有选项相同的未完成任务时直接返回该任务，
+// 选项不同时返回该任务和ErrSummaryJobConflict；opts无效时返回ErrInvalidSummaryOptions；创建任务时即预留配额，剩余配额不足时不创建任务，返回 *QuotaExceededError
+func (s *JobService) EnqueueSummary(ctx context.Context, report *models.Report, userID string, opts models.SummaryOptions) (*models.SummaryJob, error) {
+	opts, err := NormalizeSummaryOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.jobRepo.GetActiveByReportID(ctx, report.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		// 选项为空的任务按默认选项执行
+		activeOpts, err := NormalizeSummaryOptions(active.Options)
+		if err == nil && activeOpts == opts {
+			return active, nil
+		}
+		return active, ErrSummaryJobConflict
+	}
+	reservation, err := s.reportService.ReserveSummary(ctx, report, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &models.SummaryJob{
+		ID:        uuid.New().String(),
+		ReportID:  report.ID,
+		UserID:    userID,
+		Status:    models.JobStatusQueued,
+		Options:   opts,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if reservation != nil {
+		// 任务的创建时间即预留时间，执行时据此结算到预留所在的统计周期
+		job.QuotaTokens = &reservation.Tokens
+		job.CreatedAt, job.UpdatedAt = reservation.At, reservation.At
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		s.reportService.ReleaseQuota(context.WithoutCancel(ctx), reservation)
+		return nil, err
+	}
+
+	// 队列已满时不阻塞请求，任务会在下一次轮询时被取出
+	s.offer(*job)
+	return job, nil
+}
+
+// GetJob 获取任务状态，任务不存在或不属于该用户时返回repository.ErrJobNotFound
+func (s *JobService) GetJob(ctx context.Context, jobID string, userID string) (*models.SummaryJob, error) {
+	return s.jobRepo.GetByID(ctx, jobID, userID)
+}
+
+// poll 定期从数据库取出排队中的任务，覆盖队列已满和服务重启的情况
+func (s *JobService) poll(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.dispatchQueued(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *JobService) dispatchQueued(ctx context.Context) {
+	jobs, err := s.jobRepo.ListQueued(ctx, s.config.QueueSize)
+	if err != nil {
+		log.Printf("轮询摘要任务失败: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil || !s.offer(job) {
+			return
+		}
+	}
+}
+
+// offer 将任务放入内存队列，不阻塞；任务已在队列中时视为成功，队列已满时返回false
+func (s *JobService) offer(job models.SummaryJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[job.ID]; ok {
+		return true
+	}
+	select {
+	case s.queue <- job:
+		s.pending[job.ID] = struct{}{}
+		return true
+	default:
+		return false
+	}
+}
+
+// take 从内存队列中取出任务后清除其入队标记，之后轮询到仍在排队的同一任务时可以再次入队
+func (s *JobService) take(job models.SummaryJob) {
+	s.mu.Lock()
+	delete(s.pending, job.ID)
+	s.mu.Unlock()
+}
+
+func (s *JobService) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.take(job)
+			s.process(ctx, job)
+		}
+	}
+}
+
+// process 执行单个任务，通过状态条件更新保证同一任务只被一个协程执行
+func (s *JobService) process(ctx context.Context, job models.SummaryJob) {
+	claimed, err := s.jobRepo.MarkRunning(ctx, job.ID)
+	if err != nil {
+		log.Printf("抢占摘要任务%s失败: %v", job.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, s.config.JobTimeout)
+	defer cancel()
+
+	if err := s.runSummary(jobCtx, job); err != nil {
+		log.Printf("摘要任务%s失败: %v", job.ID, err)
+		var retryAfter *int
+		if wait := AIErrorRetryAfter(err); wait > 0 {
+			seconds := int(math.Ceil(wait.Seconds()))
+			retryAfter = &seconds
+		}
+		if markErr := s.jobRepo.MarkFailed(context.Background(), job.ID, err.Error(), AIErrorStatus(err), retryAfter); markErr != nil {
+			log.Printf("记录摘要任务%s失败状态出错: %v", job.ID, markErr)
+		}
+		return
+	}
+	if err := s.jobRepo.MarkSucceeded(context.Background(), job.ID); err != nil {
+		log.Printf("记录摘要任务%s成功状态出错: %v", job.ID, err)
+	}
+}
+
+func (s *JobService) runSummary(ctx context.Context, job models.SummaryJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行异常: %v", r)
+		}
+	}()
+
+	reservation := jobReservation(job)
+	report, err := s.reportService.GetReportByID(job.ReportID, job.UserID)
+	if err != nil {
+		s.reportService.ReleaseQuota(context.WithoutCancel(ctx), reservation)
+		return err
+	}
+	_, err = s.reportService.GenerateSummary(ctx, report, job.UserID, job.Options, reservation)
+	return err
+}
+
+// jobReservation 还原任务创建时预留的配额，未预留时返回nil，执行时再预留
+func jobReservation(job models.SummaryJob) *QuotaReservation {
+	if job.QuotaTokens == nil {
+		return nil
+	}
+	return &QuotaReservation{UserID: job.UserID, At: job.CreatedAt, Tokens: *job.QuotaTokens}
+}
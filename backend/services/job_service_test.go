@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// fakeJobRepo 在内存中保存任务，模拟状态条件更新
+type fakeJobRepo struct {
+	mu       sync.Mutex
+	jobs     map[string]*models.SummaryJob
+	requeued int
+}
+
+func newFakeJobRepo(jobs ...models.SummaryJob) *fakeJobRepo {
+	r := &fakeJobRepo{jobs: make(map[string]*models.SummaryJob)}
+	for i := range jobs {
+		r.jobs[jobs[i].ID] = &jobs[i]
+	}
+	return r
+}
+
+func (r *fakeJobRepo) Create(_ context.Context, job *models.SummaryJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	created := *job
+	r.jobs[job.ID] = &created
+	return nil
+}
+
+func (r *fakeJobRepo) GetByID(_ context.Context, jobID string, userID string) (*models.SummaryJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok || job.UserID != userID {
+		return nil, repository.ErrJobNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+func (r *fakeJobRepo) GetActiveByReportID(_ context.Context, reportID string, userID string) (*models.SummaryJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, job := range r.jobs {
+		if job.ReportID == reportID && job.UserID == userID && (job.Status == models.JobStatusQueued || job.Status == models.JobStatusRunning) {
+			found := *job
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeJobRepo) ListQueued(_ context.Context, limit int) ([]models.SummaryJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var jobs []models.SummaryJob
+	for _, job := range r.jobs {
+		if job.Status == models.JobStatusQueued && len(jobs) < limit {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
+
+func (r *fakeJobRepo) MarkRunning(_ context.Context, jobID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok || job.Status != models.JobStatusQueued {
+		return false, nil
+	}
+	job.Status = models.JobStatusRunning
+	return true, nil
+}
+
+func (r *fakeJobRepo) MarkSucceeded(_ context.Context, jobID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[jobID].Status = models.JobStatusSucceeded
+	return nil
+}
+
+func (r *fakeJobRepo) MarkFailed(_ context.Context, jobID string, errMsg string, errorCode int, retryAfter *int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := r.jobs[jobID]
+	job.Status = models.JobStatusFailed
+	job.Error = errMsg
+	job.ErrorCode = errorCode
+	job.RetryAfter = retryAfter
+	return nil
+}
+
+func (r *fakeJobRepo) RequeueRunning(context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, job := range r.jobs {
+		if job.Status == models.JobStatusRunning {
+			job.Status = models.JobStatusQueued
+			n++
+		}
+	}
+	r.requeued++
+	return n, nil
+}
+
+func (r *fakeJobRepo) status(jobID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.jobs[jobID].Status
+}
+
+// summaryReportRepo 在fakeReportRepo的基础上记录保存的摘要
+type summaryReportRepo struct {
+	fakeReportRepo
+	summaries map[string]string
+}
+
+func (r *summaryReportRepo) UpdateSummary(_ context.Context, reportID string, summary string, _ models.SummaryOptions) error {
+	r.summaries[reportID] = summary
+	return nil
+}
+
+func newTestJobService(jobRepo *fakeJobRepo, reply func(llm.ChatRequest) (string, error)) (*JobService, *summaryReportRepo) {
+	reportRepo := &summaryReportRepo{
+		fakeReportRepo: fakeReportRepo{reports: []models.Report{{ID: "r1", UserID: "u1", Title: "报告", Content: "报告正文"}}},
+		summaries:      make(map[string]string),
+	}
+	provider := llm.NewFakeProvider()
+	provider.Reply = reply
+	llmClient := NewLLMClient(provider, LLMConfig{MaxTokens: 500, ChunkTokens: 4000})
+	reportService := NewReportService(reportRepo, nil, nil, nil, nil, llmClient, "")
+	return NewJobService(jobRepo, reportService, JobConfig{QueueSize: 4}), reportRepo
+}
+
+func TestJobService_Process(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("成功时保存摘要并标记成功", func(t *testing.T) {
+		jobRepo := newFakeJobRepo(models.SummaryJob{ID: "j1", ReportID: "r1", UserID: "u1", Status: models.JobStatusQueued})
+		s, reportRepo := newTestJobService(jobRepo, func(llm.ChatRequest) (string, error) { return "摘要", nil })
+
+		s.process(ctx, *jobRepo.jobs["j1"])
+		assert.Equal(t, models.JobStatusSucceeded, jobRepo.status("j1"))
+		assert.Equal(t, "摘要", reportRepo.summaries["r1"])
+	})
+
+	t.Run("失败时记录错误码和建议的等待时间", func(t *testing.T) {
+		jobRepo := newFakeJobRepo(models.SummaryJob{ID: "j1", ReportID: "r1", UserID: "u1", Status: models.JobStatusQueued})
+		s, reportRepo := newTestJobService(jobRepo, func(llm.ChatRequest) (string, error) {
+			return "", &llm.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 1500 * time.Millisecond, Kind: llm.ErrRateLimited}
+		})
+
+		s.process(ctx, *jobRepo.jobs["j1"])
+		job := jobRepo.jobs["j1"]
+		assert.Equal(t, models.JobStatusFailed, job.Status)
+		assert.Equal(t, http.StatusTooManyRequests, job.ErrorCode)
+		require.NotNil(t, job.RetryAfter)
+		assert.Equal(t, 2, *job.RetryAfter)
+		assert.NotEmpty(t, job.Error)
+		assert.Empty(t, reportRepo.summaries)
+	})
+
+	t.Run("已被其他协程抢占的任务不再执行", func(t *testing.T) {
+		jobRepo := newFakeJobRepo(models.SummaryJob{ID: "j1", ReportID: "r1", UserID: "u1", Status: models.JobStatusRunning})
+		called := false
+		s, _ := newTestJobService(jobRepo, func(llm.ChatRequest) (string, error) {
+			called = true
+			return "摘要", nil
+		})
+
+		s.process(ctx, *jobRepo.jobs["j1"])
+		assert.False(t, called)
+		assert.Equal(t, models.JobStatusRunning, jobRepo.status("j1"))
+	})
+}
+
+func TestJobService_StartRequeuesRunning(t *testing.T) {
+	jobRepo := newFakeJobRepo(
+		models.SummaryJob{ID: "j1", ReportID: "r1", UserID: "u1", Status: models.JobStatusRunning},
+		models.SummaryJob{ID: "j2", ReportID: "r2", UserID: "u1", Status: models.JobStatusSucceeded},
+	)
+	s, _ := newTestJobService(jobRepo, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, s.Start(ctx))
+	assert.Equal(t, 1, jobRepo.requeued)
+	assert.Equal(t, models.JobStatusQueued, jobRepo.status("j1"))
+	assert.Equal(t, models.JobStatusSucceeded, jobRepo.status("j2"))
+}
+
+func TestJobService_DispatchQueuedSkipsPending(t *testing.T) {
+	jobRepo := newFakeJobRepo(
+		models.SummaryJob{ID: "j1", ReportID: "r1", UserID: "u1", Status: models.JobStatusQueued},
+		models.SummaryJob{ID: "j2", ReportID: "r2", UserID: "u1", Status: models.JobStatusQueued},
+	)
+	s, _ := newTestJobService(jobRepo, nil)
+	ctx := context.Background()
+
+	// 任务未被取出前多次轮询不会重复入队
+	s.dispatchQueued(ctx)
+	s.dispatchQueued(ctx)
+	assert.Len(t, s.queue, 2)
+
+	// 取出后仍在排队的任务可以再次入队
+	s.take(<-s.queue)
+	s.dispatchQueued(ctx)
+	assert.Len(t, s.queue, 2)
+}
+
+func TestJobService_EnqueueSummary(t *testing.T) {
+	jobRepo := newFakeJobRepo()
+	s, reportRepo := newTestJobService(jobRepo, nil)
+	ctx := context.Background()
+	report := &reportRepo.reports[0]
+
+	job, err := s.EnqueueSummary(ctx, report, "u1", models.SummaryOptions{})
+	require.NoError(t, err)
+	assert.Len(t, s.queue, 1)
+
+	// 选项相同时复用未完成的任务
+	again, err := s.EnqueueSummary(ctx, report, "u1", job.Options)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID)
+	assert.Len(t, s.queue, 1)
+
+	// 选项不同时返回冲突和正在进行的任务
+	other := job.Options
+	other.Length = models.SummaryLengthLong
+	active, err := s.EnqueueSummary(ctx, report, "u1", other)
+	assert.ErrorIs(t, err, ErrSummaryJobConflict)
+	require.NotNil(t, active)
+	assert.Equal(t, job.ID, active.ID)
+	assert.Len(t, jobRepo.jobs, 1)
+}
+
+func TestJobService_EnqueueSummaryByEditor(t *testing.T) {
+	jobRepo := newFakeJobRepo()
+	s, reportRepo := newTestJobService(jobRepo, nil)
+	ctx := context.Background()
+	report := &reportRepo.reports[0]
+
+	owned, err := s.EnqueueSummary(ctx, report, "u1", models.SummaryOptions{})
+	require.NoError(t, err)
+
+	// 共享报告的编辑者创建自己的任务，不会拿到所有者的任务
+	edited, err := s.EnqueueSummary(ctx, report, "u2", models.SummaryOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, owned.ID, edited.ID)
+	assert.Equal(t, "u2", edited.UserID)
+
+	found, err := s.GetJob(ctx, edited.ID, "u2")
+	require.NoError(t, err)
+	assert.Equal(t, edited.ID, found.ID)
+	_, err = s.GetJob(ctx, owned.ID, "u2")
+	assert.ErrorIs(t, err, repository.ErrJobNotFound)
+}
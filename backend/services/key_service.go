@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// 加密字段的格式为 enc:v1:<数据密钥ID>:<base64(AES-GCM密文)>，没有该前缀的值视为加密功能启用前写入的明文
+const (
+	encryptedValuePrefix = "enc:v1:"
+	dataKeySize          = 32
+)
+
+// ErrEncryptionDisabled 数据已加密但没有配置主密钥
+var ErrEncryptionDisabled = errors.New("数据已加密，但未配置主密钥")
+
+// KeyService 信封加密：每个用户一个数据密钥，数据密钥由配置中的主密钥包装后保存在数据库
+// 主密钥按版本号区分，新包装的数据密钥使用当前版本，轮换主密钥后旧版本仍可用于解包，直到全部重新包装
+// 为nil时表示未启用加密，加密方法原样返回明文
+type KeyService struct {
+	keyRepo       repository.IUserKeyRepository
+	masterKeys    map[int][]byte
+	activeVersion int
+
+	mu       sync.RWMutex
+	dataKeys map[int64][]byte // 已解包的数据密钥，密钥内容不会变化，可以一直缓存
+}
+
+// NewKeyService 创建密钥服务，activeVersion为新包装数据密钥使用的主密钥版本，为0时使用最大的版本号
+func NewKeyService(keyRepo repository.IUserKeyRepository, masterKeys map[int][]byte, activeVersion int) (*KeyService, error) {
+	if len(masterKeys) == 0 {
+		return nil, errors.New("至少需要配置一个主密钥")
+	}
+	if activeVersion == 0 {
+		for version := range masterKeys {
+			activeVersion = max(activeVersion, version)
+		}
+	}
+	if _, ok := masterKeys[activeVersion]; !ok {
+		return nil, fmt.Errorf("未配置版本为%d的主密钥", activeVersion)
+	}
+	return &KeyService{
+		keyRepo:       keyRepo,
+		masterKeys:    masterKeys,
+		activeVersion: activeVersion,
+		dataKeys:      make(map[int64][]byte),
+	}, nil
+}
+
+// ParseMasterKeys 解析主密钥配置，格式为逗号分隔的 版本号:base64编码的32字节密钥
+func ParseMasterKeys(spec string) (map[int][]byte, error) {
+	masterKeys := make(map[int][]byte)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		versionStr, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, errors.New("主密钥格式应为 版本号:密钥")
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("无效的主密钥版本号: %s", versionStr)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("版本%d的主密钥应为base64编码的%d字节", version, dataKeySize)
+		}
+		if _, exists := masterKeys[version]; exists {
+			return nil, fmt.Errorf("主密钥版本号%d重复", version)
+		}
+		masterKeys[version] = key
+	}
+	return masterKeys, nil
+}
+
+// Enabled 是否启用了加密
+func (k *KeyService) Enabled() bool {
+	return k != nil
+}
+
+// EncryptString 使用用户当前的数据密钥加密字段值，空字符串不加密，便于按是否为空过滤
+func (k *KeyService) EncryptString(ctx context.Context, userID string, plaintext string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	keyID, key, err := k.activeKey(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := utils.EncryptData([]byte(plaintext), key)
+	if err != nil {
+		return "", fmt.Errorf("加密数据失败: %w", err)
+	}
+	return fmt.Sprintf("%s%d:%s", encryptedValuePrefix, keyID, base64.StdEncoding.EncodeToString(ciphertext)), nil
+}
+
+// DecryptString 解密字段值，明文原样返回
+func (k *KeyService) DecryptString(ctx context.Context, value string) (string, error) {
+	keyID, encoded, ok := parseEncryptedValue(value)
+	if !ok {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrEncryptionDisabled
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("加密数据格式无效: %w", err)
+	}
+	key, err := k.dataKey(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := utils.DecryptData(ciphertext, key)
+	if err != nil {
+		return "", fmt.Errorf("解密数据失败: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// parseEncryptedValue 解析加密字段值，返回数据密钥ID和base64密文；不是加密格式时ok为false
+func parseEncryptedValue(value string) (keyID int64, encoded string, ok bool) {
+	rest, found := strings.CutPrefix(value, encryptedValuePrefix)
+	if !found {
+		return 0, "", false
+	}
+	idStr, encoded, found := strings.Cut(rest, ":")
+	if !found {
+		return 0, "", false
+	}
+	keyID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return keyID, encoded, true
+}
+
+// EncryptObject 返回使用用户当前数据密钥加密src的reader及加密后的长度
+func (k *KeyService) EncryptObject(ctx context.Context, userID string, src io.ReaderAt, size int64) (io.Reader, int64, error) {
+	if k == nil {
+		return io.NewSectionReader(src, 0, size), size, nil
+	}
+	keyID, key, err := k.activeKey(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	reader, err := utils.NewEncryptReader(src, size, key, keyID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("加密PDF失败: %w", err)
+	}
+	return reader, utils.EncryptedSize(size), nil
+}
+
+// OpenObject 打开存储中的对象，加密的对象返回按需解密的对象，info中的大小改为明文长度；未加密的对象原样返回
+func (k *KeyService) OpenObject(ctx context.Context, object storage.Object, info storage.ObjectInfo) (storage.Object, storage.ObjectInfo, error) {
+	keyID, err := utils.ReadEncryptedKeyID(object)
+	if errors.Is(err, utils.ErrNotEncrypted) {
+		return object, info, nil
+	}
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	if k == nil {
+		return nil, storage.ObjectInfo{}, ErrEncryptionDisabled
+	}
+	key, err := k.dataKey(ctx, keyID)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	reader, err := utils.NewDecryptReader(object, info.Size, key)
+	if err != nil {
+		return nil, storage.ObjectInfo{}, fmt.Errorf("打开加密PDF失败: %w", err)
+	}
+	info.Size = reader.Size()
+	return decryptedObject{DecryptReader: reader, closer: object}, info, nil
+}
+
+// decryptedObject 解密后的对象，关闭时关闭底层对象
+type decryptedObject struct {
+	*utils.DecryptReader
+	closer io.Closer
+}
+
+func (o decryptedObject) Close() error {
+	return o.closer.Close()
+}
+
+// activeKey 获取用户当前启用的数据密钥，用户还没有数据密钥时创建
+// 启用的密钥每次从数据库读取，密钥轮换命令在其他进程中执行后立即生效
+func (k *KeyService) activeKey(ctx context.Context, userID string) (int64, []byte, error) {
+	userKey, err := k.keyRepo.GetActive(ctx, userID)
+	if errors.Is(err, repository.ErrUserKeyNotFound) {
+		userKey, err = k.createKey(ctx, userID, false)
+		if errors.Is(err, repository.ErrUserKeyExists) {
+			// 并发创建时使用另一个请求创建的密钥
+			userKey, err = k.keyRepo.GetActive(ctx, userID)
+		}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	key, err := k.dataKey(ctx, userKey.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return userKey.ID, key, nil
+}
+
+// createKey 生成新的数据密钥并以当前主密钥包装后保存；rotate为true时停用用户原有的密钥
+func (k *KeyService) createKey(ctx context.Context, userID string, rotate bool) (*models.UserKey, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	wrapped, err := k.wrap(key)
+	if err != nil {
+		return nil, err
+	}
+	userKey := &models.UserKey{UserID: userID, WrappedKey: wrapped, MasterKeyVersion: k.activeVersion}
+	if rotate {
+		err = k.keyRepo.Rotate(ctx, userKey)
+	} else {
+		err = k.keyRepo.Create(ctx, userKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	k.dataKeys[userKey.ID] = key
+	k.mu.Unlock()
+	return userKey, nil
+}
+
+// dataKey 获取并解包指定ID的数据密钥
+func (k *KeyService) dataKey(ctx context.Context, keyID int64) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.dataKeys[keyID]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	userKey, err := k.keyRepo.GetByID(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("获取数据密钥%d失败: %w", keyID, err)
+	}
+	key, err = k.unwrap(userKey)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	k.dataKeys[keyID] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// wrap 使用当前主密钥包装数据密钥
+func (k *KeyService) wrap(key []byte) (string, error) {
+	wrapped, err := utils.EncryptData(key, k.masterKeys[k.activeVersion])
+	if err != nil {
+		return "", fmt.Errorf("包装数据密钥失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrap 使用包装时的主密钥解包数据密钥
+func (k *KeyService) unwrap(userKey *models.UserKey) ([]byte, error) {
+	masterKey, ok := k.masterKeys[userKey.MasterKeyVersion]
+	if !ok {
+		return nil, fmt.Errorf("数据密钥%d由版本%d的主密钥包装，但未配置该版本", userKey.ID, userKey.MasterKeyVersion)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(userKey.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("数据密钥%d格式无效: %w", userKey.ID, err)
+	}
+	key, err := utils.DecryptData(wrapped, masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("解包数据密钥%d失败: %w", userKey.ID, err)
+	}
+	return key, nil
+}
+
+// RewrapKeys 使用当前主密钥重新包装由旧版本主密钥包装的数据密钥，返回重新包装的数量
+// 数据本身不需要重新加密，完成后即可从配置中移除旧版本的主密钥
+func (k *KeyService) RewrapKeys(ctx context.Context) (int, error) {
+	userKeys, err := k.keyRepo.ListByOtherMasterVersion(ctx, k.activeVersion)
+	if err != nil {
+		return 0, err
+	}
+	for i, userKey := range userKeys {
+		key, err := k.unwrap(&userKey)
+		if err != nil {
+			return i, err
+		}
+		wrapped, err := k.wrap(key)
+		if err != nil {
+			return i, err
+		}
+		if err := k.keyRepo.UpdateWrapped(ctx, userKey.ID, wrapped, k.activeVersion); err != nil {
+			return i, err
+		}
+	}
+	return len(userKeys), nil
+}
+
+// RotateUserKey 为用户生成新的数据密钥并停用原有密钥，之后写入的数据使用新密钥；原有密钥保留用于解密旧数据
+func (k *KeyService) RotateUserKey(ctx context.Context, userID string) (*models.UserKey, error) {
+	return k.createKey(ctx, userID, true)
+}
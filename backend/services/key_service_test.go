@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// fakeUserKeyRepo 内存中的数据密钥仓储
+type fakeUserKeyRepo struct {
+	keys []models.UserKey
+}
+
+func (r *fakeUserKeyRepo) GetActive(_ context.Context, userID string) (*models.UserKey, error) {
+	for _, key := range r.keys {
+		if key.UserID == userID && key.Active {
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrUserKeyNotFound
+}
+
+func (r *fakeUserKeyRepo) GetByID(_ context.Context, id int64) (*models.UserKey, error) {
+	for _, key := range r.keys {
+		if key.ID == id {
+			return &key, nil
+		}
+	}
+	return nil, repository.ErrUserKeyNotFound
+}
+
+func (r *fakeUserKeyRepo) Create(_ context.Context, key *models.UserKey) error {
+	key.ID = int64(len(r.keys) + 1)
+	key.Active = true
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+func (r *fakeUserKeyRepo) Rotate(ctx context.Context, key *models.UserKey) error {
+	for i := range r.keys {
+		if r.keys[i].UserID == key.UserID {
+			r.keys[i].Active = false
+		}
+	}
+	return r.Create(ctx, key)
+}
+
+func (r *fakeUserKeyRepo) ListByOtherMasterVersion(_ context.Context, version int) ([]models.UserKey, error) {
+	var keys []models.UserKey
+	for _, key := range r.keys {
+		if key.MasterKeyVersion != version {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeUserKeyRepo) UpdateWrapped(_ context.Context, id int64, wrappedKey string, version int) error {
+	for i := range r.keys {
+		if r.keys[i].ID == id {
+			r.keys[i].WrappedKey, r.keys[i].MasterKeyVersion = wrappedKey, version
+		}
+	}
+	return nil
+}
+
+func TestKeyService_EncryptString(t *testing.T) {
+	ctx := context.Background()
+	masterKeys, err := ParseMasterKeys("1:" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	require.NoError(t, err)
+	repo := &fakeUserKeyRepo{}
+	keys, err := NewKeyService(repo, masterKeys, 0)
+	require.NoError(t, err)
+
+	encrypted, err := keys.EncryptString(ctx, "u1", "基金净值增长3.2%")
+	require.NoError(t, err)
+	assert.NotContains(t, encrypted, "基金")
+	empty, err := keys.EncryptString(ctx, "u1", "")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	// 明文原样返回，未启用加密时无法解密
+	plain, err := keys.DecryptString(ctx, "明文")
+	require.NoError(t, err)
+	assert.Equal(t, "明文", plain)
+	var disabled *KeyService
+	_, err = disabled.DecryptString(ctx, encrypted)
+	assert.ErrorIs(t, err, ErrEncryptionDisabled)
+
+	// 轮换数据密钥后旧数据仍可解密，新数据使用新密钥
+	_, err = keys.RotateUserKey(ctx, "u1")
+	require.NoError(t, err)
+	rotated, err := keys.EncryptString(ctx, "u1", "新内容")
+	require.NoError(t, err)
+	oldID, _, _ := parseEncryptedValue(encrypted)
+	newID, _, _ := parseEncryptedValue(rotated)
+	assert.NotEqual(t, oldID, newID)
+
+	// 轮换主密钥：重新包装后只保留新版本的主密钥也能解密
+	masterKeys[2] = bytes.Repeat([]byte{2}, dataKeySize)
+	keys, err = NewKeyService(repo, masterKeys, 0)
+	require.NoError(t, err)
+	rewrapped, err := keys.RewrapKeys(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rewrapped)
+
+	keys, err = NewKeyService(repo, map[int][]byte{2: masterKeys[2]}, 0)
+	require.NoError(t, err)
+	plain, err = keys.DecryptString(ctx, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "基金净值增长3.2%", plain)
+}
+
+func TestKeyService_Object(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewKeyService(&fakeUserKeyRepo{}, map[int][]byte{1: bytes.Repeat([]byte{1}, dataKeySize)}, 0)
+	require.NoError(t, err)
+	store := storage.NewMemoryStore()
+
+	pdf := bytes.Repeat([]byte("%PDF-1.7 报告"), 20000)
+	reader, size, err := keys.EncryptObject(ctx, "u1", bytes.NewReader(pdf), int64(len(pdf)))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, "a.pdf", reader, size, "application/pdf")
+	require.NoError(t, err)
+
+	object, info, err := store.Get(ctx, "a.pdf")
+	require.NoError(t, err)
+	assert.False(t, bytes.HasPrefix(readAll(t, object), []byte("%PDF")))
+
+	object, info, err = store.Get(ctx, "a.pdf")
+	require.NoError(t, err)
+	opened, info, err := keys.OpenObject(ctx, object, info)
+	require.NoError(t, err)
+	defer opened.Close()
+	assert.Equal(t, int64(len(pdf)), info.Size)
+	assert.Equal(t, pdf, readAll(t, opened))
+}
+
+func readAll(t *testing.T, r io.Reader) []byte {
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
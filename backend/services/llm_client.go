@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// LLMConfig 配置报告摘要和问答使用的大模型参数
+type LLMConfig struct {
+	MaxTokens   int
+	Temperature float64
+	// ChunkTokens 单次请求中报告内容的token上限，超过时按块分别摘要后再合并
+	ChunkTokens int
+	// ChunkOverlap 相邻块之间重叠的token数，避免跨块的上下文丢失
+	ChunkOverlap int
+}
+
+// LLMClient 基于大模型服务生成报告摘要和回答问题，具体服务由llm.Provider实现
+type LLMClient struct {
+	provider llm.Provider
+	config   LLMConfig
+}
+
+// NewLLMClient 创建新的大模型客户端
+func NewLLMClient(provider llm.Provider, config LLMConfig) *LLMClient {
+	return &LLMClient{provider: provider, config: config}
+}
+
+// GenerateSummary 按摘要选项生成报告摘要，opts需已经过NormalizeSummaryOptions
+func (c *LLMClient) GenerateSummary(ctx context.Context, opts models.SummaryOptions, reportContent string) (string, error) {
+	prompt, err := summaryPrompt(opts, reportContent)
+	if err != nil {
+		return "", err
+	}
+	return c.chat(ctx, models.UsageOpSummary, prompt)
+}
+
+// GenerateSummaryStream 以流式方式生成报告摘要，每收到一段文本调用一次onDelta，返回完整摘要
+func (c *LLMClient) GenerateSummaryStream(ctx context.Context, opts models.SummaryOptions, reportContent string, onDelta func(string) error) (string, error) {
+	prompt, err := summaryPrompt(opts, reportContent)
+	if err != nil {
+		return "", err
+	}
+	return c.chatStream(ctx, models.UsageOpSummary, prompt, onDelta)
+}
+
+// SummarizeChunk 为长报告的其中一块生成要点摘要（map阶段），按摘要风格确定提取的侧重点，保持报告原文的语言
+func (c *LLMClient) SummarizeChunk(ctx context.Context, opts models.SummaryOptions, title string, chunk string, index, total int) (string, error) {
+	prompt := fmt.Sprintf("以下是报告《%s》的第%d/%d部分，请提取这一部分的%s，生成简洁的要点摘要（不超过300字）:\n\n%s",
+		title, index, total, summaryFocusOf(opts), chunk)
+	return c.chat(ctx, models.UsageOpSummaryChunk, prompt)
+}
+
+// MergeSummaries 部分摘要过多时将其中一组合并为一份要点摘要，作为最终合并的输入
+func (c *LLMClient) MergeSummaries(ctx context.Context, opts models.SummaryOptions, title string, partials []string) (string, error) {
+	prompt := fmt.Sprintf("以下是报告《%s》连续若干部分的摘要，请将它们合并为一份要点摘要，保留%s（不超过300字）:\n\n%s",
+		title, summaryFocusOf(opts), formatPartials(partials))
+	return c.chat(ctx, models.UsageOpSummaryCombine, prompt)
+}
+
+// CombineSummaries 将各部分摘要按摘要选项合并为完整的报告摘要（reduce阶段）
+func (c *LLMClient) CombineSummaries(ctx context.Context, opts models.SummaryOptions, title string, partials []string) (string, error) {
+	prompt, err := combinePrompt(opts, title, partials)
+	if err != nil {
+		return "", err
+	}
+	return c.chat(ctx, models.UsageOpSummaryCombine, prompt)
+}
+
+// CombineSummariesStream 以流式方式合并各部分摘要
+func (c *LLMClient) CombineSummariesStream(ctx context.Context, opts models.SummaryOptions, title string, partials []string, onDelta func(string) error) (string, error) {
+	prompt, err := combinePrompt(opts, title, partials)
+	if err != nil {
+		return "", err
+	}
+	return c.chatStream(ctx, models.UsageOpSummaryCombine, prompt, onDelta)
+}
+
+// AnswerQuestion 基于报告中检索到的段落回答问题，要求模型以 [第N页] 的形式标注引用页码
+func (c *LLMClient) AnswerQuestion(ctx context.Context, title string, question string, passages []utils.Passage) (string, error) {
+	var builder strings.Builder
+	for _, passage := range passages {
+		fmt.Fprintf(&builder, "[第%d页]\n%s\n\n", passage.Page, strings.TrimSpace(passage.Text))
+	}
+	prompt := fmt.Sprintf("以下是报告《%s》中与问题相关的段落，每段前标注了所在页码。\n"+
+		"请仅根据这些段落回答问题，并在引用内容后用 [第N页] 的形式标注来源页码；"+
+		"如果段落中没有答案，请直接说明报告中未找到相关信息。\n\n%s问题：%s",
+		title, builder.String(), question)
+	return c.chat(ctx, models.UsageOpAsk, prompt)
+}
+
+func summaryPrompt(opts models.SummaryOptions, reportContent string) (string, error) {
+	instruction, err := summaryInstruction(opts, "以下报告")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n\n%s", instruction, reportContent), nil
+}
+
+func combinePrompt(opts models.SummaryOptions, title string, partials []string) (string, error) {
+	instruction, err := summaryInstruction(opts, "该报告")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("以下是报告《%s》各部分的摘要，请基于这些内容整体概括，不要逐部分复述。%s\n\n%s",
+		title, instruction, formatPartials(partials)), nil
+}
+
+func formatPartials(partials []string) string {
+	var builder strings.Builder
+	for i, partial := range partials {
+		fmt.Fprintf(&builder, "第%d部分摘要:\n%s\n\n", i+1, partial)
+	}
+	return builder.String()
+}
+
+// chat 发送单轮对话请求并返回模型回复，operation为用量记录中的业务操作
+func (c *LLMClient) chat(ctx context.Context, operation string, prompt string) (string, error) {
+	ctx = llm.WithUsageScope(ctx, llm.UsageScope{Operation: operation})
+	resp, err := c.provider.Chat(ctx, c.chatRequest(prompt))
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// chatStream 以流式方式发送单轮对话请求，返回完整回复
+func (c *LLMClient) chatStream(ctx context.Context, operation string, prompt string, onDelta func(string) error) (string, error) {
+	ctx = llm.WithUsageScope(ctx, llm.UsageScope{Operation: operation})
+	resp, err := c.provider.ChatStream(ctx, c.chatRequest(prompt), onDelta)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (c *LLMClient) chatRequest(prompt string) llm.ChatRequest {
+	return llm.ChatRequest{
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		MaxTokens:   c.config.MaxTokens,
+		Temperature: c.config.Temperature,
+	}
+}
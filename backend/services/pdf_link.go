@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// PDF下载链接的生成方式
+const (
+	// PDFLinkSigned 由本服务的 /api/v1/files/report/:report_id 接口提供文件，链接使用HMAC签名
+	PDFLinkSigned = "signed"
+	// PDFLinkPresigned 使用对象存储的预签名URL直接下载；存储不支持预签名或启用了加密时退回签名链接
+	PDFLinkPresigned = "presigned"
+)
+
+// presignMaxTTL 对象存储预签名URL的最长有效期（S3/MinIO限制为7天）
+const presignMaxTTL = 7 * 24 * time.Hour
+
+// 签名链接相关错误
+var (
+	// ErrPDFLinkInvalid 签名链接参数缺失或签名不匹配
+	ErrPDFLinkInvalid = errors.New("无效的下载链接")
+	// ErrPDFLinkExpired 签名链接已过期
+	ErrPDFLinkExpired = errors.New("下载链接已过期")
+)
+
+// PDFLinkConfig PDF下载链接配置
+type PDFLinkConfig struct {
+	// Mode 链接生成方式，signed或presigned，为空时使用signed
+	Mode string
+	// TTL 链接有效期
+	TTL time.Duration
+	// BaseURL 客户端访问本服务使用的地址，如 https://reports.example.com
+	BaseURL string
+	// Secret 签名链接使用的HMAC密钥
+	Secret []byte
+}
+
+// GeneratePDFURL 为用户有权查看的报告生成有时效的PDF下载链接，持有链接即可下载，不需要JWT令牌
+// 签名链接绑定生成链接的用户，下载时重新检查该用户的权限，取消授权后链接随之失效
+func (s *ReportService) GeneratePDFURL(ctx context.Context, reportID, userID string) (*models.PDFLink, error) {
+	report, _, err := s.authorizeReport(ctx, reportID, userID, authz.ActionView) // 只需要PDF路径和标题，不解密内容
+	if err != nil {
+		return nil, fmt.Errorf("获取报告信息失败: %w", err)
+	}
+
+	ttl := s.PDFLinks.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	// 加密的PDF只能由服务端解密后返回，存储服务直接返回的是密文
+	if s.PDFLinks.Mode == PDFLinkPresigned && !s.Keys.Enabled() {
+		ttl = min(ttl, presignMaxTTL)
+		params := url.Values{"response-content-disposition": {utils.ContentDisposition("inline", utils.PDFFilename(report.Title))}}
+		presignedURL, err := s.Store.PresignedGetURL(ctx, objectKey(report.PDFPath), ttl, params)
+		if err == nil {
+			return &models.PDFLink{URL: presignedURL, ExpiresAt: time.Now().Add(ttl)}, nil
+		}
+		if !errors.Is(err, storage.ErrPresignNotSupported) {
+			return nil, fmt.Errorf("生成预签名URL失败: %w", err)
+		}
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{
+		"uid":       {userID},
+		"expires":   {expires},
+		"signature": {s.signPDFLink(report.ID, userID, expires)},
+	}
+	link := fmt.Sprintf("%s/api/v1/files/report/%s?%s", strings.TrimRight(s.PDFLinks.BaseURL, "/"), url.PathEscape(report.ID), query.Encode())
+	return &models.PDFLink{URL: link, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyPDFLink 校验签名链接的参数，通过时返回生成链接的用户ID
+func (s *ReportService) VerifyPDFLink(reportID string, query url.Values) (string, error) {
+	userID, expires, signature := query.Get("uid"), query.Get("expires"), query.Get("signature")
+	if userID == "" || expires == "" || signature == "" || len(s.PDFLinks.Secret) == 0 {
+		return "", ErrPDFLinkInvalid
+	}
+	// 先校验签名再判断是否过期，避免通过篡改过期时间探测链接
+	if !hmac.Equal([]byte(signature), []byte(s.signPDFLink(reportID, userID, expires))) {
+		return "", ErrPDFLinkInvalid
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrPDFLinkInvalid
+	}
+	if time.Now().Unix() > unix {
+		return "", ErrPDFLinkExpired
+	}
+	return userID, nil
+}
+
+// signPDFLink 计算签名链接的签名，签名覆盖报告ID、用户ID和过期时间
+func (s *ReportService) signPDFLink(reportID, userID, expires string) string {
+	mac := hmac.New(sha256.New, s.PDFLinks.Secret)
+	mac.Write([]byte(reportID + "\n" + userID + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPDFLink(t *testing.T) {
+	s := &ReportService{PDFLinks: PDFLinkConfig{Secret: []byte("secret")}}
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	query := url.Values{
+		"uid":       {"user-1"},
+		"expires":   {expires},
+		"signature": {s.signPDFLink("report-1", "user-1", expires)},
+	}
+
+	userID, err := s.VerifyPDFLink("report-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+
+	// 签名绑定报告ID和用户ID
+	_, err = s.VerifyPDFLink("report-2", query)
+	assert.ErrorIs(t, err, ErrPDFLinkInvalid)
+	tampered := url.Values{"uid": {"user-2"}, "expires": query["expires"], "signature": query["signature"]}
+	_, err = s.VerifyPDFLink("report-1", tampered)
+	assert.ErrorIs(t, err, ErrPDFLinkInvalid)
+
+	// 延长过期时间会使签名失效
+	tampered = url.Values{"uid": query["uid"], "expires": {expires + "0"}, "signature": query["signature"]}
+	_, err = s.VerifyPDFLink("report-1", tampered)
+	assert.ErrorIs(t, err, ErrPDFLinkInvalid)
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	query = url.Values{"uid": {"user-1"}, "expires": {expired}, "signature": {s.signPDFLink("report-1", "user-1", expired)}}
+	_, err = s.VerifyPDFLink("report-1", query)
+	assert.ErrorIs(t, err, ErrPDFLinkExpired)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// 问答检索参数
+const (
+	askPassageTokens = 500 // 检索段落的token上限
+	askTopK          = 6   // 发送给模型的段落数量
+	askExcerptRunes  = 120 // 返回给客户端的引用摘录长度
+)
+
+// citationPattern 匹配回答中的 [第N页] 引用
+var citationPattern = regexp.MustCompile(`第\s*(\d+)\s*页`)
+
+// AskQuestion 针对单个报告进行问答，返回回答及其引用的页码；大模型用量记在userID名下
+func (s *ReportService) AskQuestion(ctx context.Context, report *models.Report, userID string, question string) (*models.AskResponse, error) {
+	if report.Content == "" {
+		return nil, fmt.Errorf("报告内容为空，无法回答问题")
+	}
+	reservation, err := s.reserveQuota(ctx, userID, estimateAskTokens(s.LLM.config, question))
+	if err != nil {
+		return nil, err
+	}
+
+	var response *models.AskResponse
+	err = s.runAIRequest(ctx, report, userID, reservation, func(ctx context.Context) error {
+		response, err = s.answerQuestion(ctx, report, question)
+		return err
+	})
+	return response, err
+}
+
+func (s *ReportService) answerQuestion(ctx context.Context, report *models.Report, question string) (*models.AskResponse, error) {
+	pages, err := s.reportPageTexts(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	passages := utils.SplitPassages(pages, askPassageTokens)
+	if len(passages) == 0 {
+		return nil, fmt.Errorf("报告内容为空，无法回答问题")
+	}
+	relevant := utils.RankPassages(question, passages, askTopK)
+	if len(relevant) == 0 {
+		// 没有关键词命中时退回到报告开头的段落，由模型判断能否回答
+		relevant = passages[:min(askTopK, len(passages))]
+	}
+
+	// 按页码顺序提供上下文，便于模型理解
+	sort.SliceStable(relevant, func(i, j int) bool { return relevant[i].Page < relevant[j].Page })
+
+	answer, err := s.LLM.AnswerQuestion(ctx, report.Title, question, relevant)
+	if err != nil {
+		return nil, fmt.Errorf("调用大模型回答问题失败: %w", err)
+	}
+
+	return buildAskResponse(answer, relevant), nil
+}
+
+// estimateAskTokens 按检索段落数的上限预估一次问答需要的token数
+func estimateAskTokens(config LLMConfig, question string) int64 {
+	output := int64(config.MaxTokens)
+	if output <= 0 {
+		output = quotaDefaultOutputTokens
+	}
+	return int64(utils.EstimateTokens(question)+askTopK*askPassageTokens) + quotaPromptOverheadTokens + output
+}
+
+// buildAskResponse 从回答中解析引用页码；模型未标注引用时以检索到的段落页码作为来源
+func buildAskResponse(answer string, passages []utils.Passage) *models.AskResponse {
+	retrieved := make(map[int]bool)
+	for _, passage := range passages {
+		retrieved[passage.Page] = true
+	}
+
+	cited := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		page, err := strconv.Atoi(match[1])
+		if err == nil && retrieved[page] {
+			cited[page] = true
+		}
+	}
+	if len(cited) == 0 {
+		cited = retrieved
+	}
+
+	response := &models.AskResponse{Answer: answer, Pages: []int{}, Sources: []models.AskSource{}}
+	for _, passage := range passages {
+		if !cited[passage.Page] {
+			continue
+		}
+		if len(response.Pages) == 0 || response.Pages[len(response.Pages)-1] != passage.Page {
+			response.Pages = append(response.Pages, passage.Page)
+		}
+		response.Sources = append(response.Sources, models.AskSource{
+			Page:    passage.Page,
+			Excerpt: excerpt(passage.Text, askExcerptRunes),
+		})
+	}
+	return response
+}
+
+func excerpt(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	return string(runes[:maxRunes]) + "..."
+}
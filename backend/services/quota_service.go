@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// 配额相关错误
+var (
+	// ErrQuotaExceeded 超出大模型配额
+	ErrQuotaExceeded = errors.New("已超出大模型配额")
+	// ErrInvalidQuotaLimits 配额不能为负数
+	ErrInvalidQuotaLimits = errors.New("配额不能为负数")
+)
+
+// 配额项名称
+const (
+	QuotaDailyRequests   = "daily_requests"
+	QuotaDailyTokens     = "daily_tokens"
+	QuotaMonthlyRequests = "monthly_requests"
+	QuotaMonthlyTokens   = "monthly_tokens"
+)
+
+// QuotaExceededError 超出配额的详细信息，errors.Is(err, ErrQuotaExceeded) 为true
+type QuotaExceededError struct {
+	// Limit 超出的配额项，同时超出多项时为重置时间最晚的一项
+	Limit   string
+	ResetAt time.Time
+	// RequiredTokens 本次请求预估需要的token数
+	RequiredTokens int64
+	Status         *models.QuotaStatus
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s，将于%s重置", ErrQuotaExceeded.Error(), e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaService 按套餐和用户单独配额限制大模型用量
+// 配额每次检查时从数据库读取，管理员修改后立即生效；按自然日和自然月统计，以服务器时区为准
+// 每次调用大模型前先预留一次请求和预估的token数，预留在数据库中加锁完成，并发请求不会同时通过检查；
+// 调用结束后按实际用量结算，失败的请求释放占用的次数
+type QuotaService struct {
+	quotaRepo repository.IQuotaRepository
+	now       func() time.Time
+}
+
+// NewQuotaService 创建配额服务
+func NewQuotaService(quotaRepo repository.IQuotaRepository) *QuotaService {
+	return &QuotaService{quotaRepo: quotaRepo, now: time.Now}
+}
+
+// QuotaReservation 一次已预留的配额，需要通过Settle结算
+type QuotaReservation struct {
+	UserID string
+	// At 预留时间，决定计入哪一天和哪个月
+	At time.Time
+	// Tokens 预留的token数
+	Tokens int64
+}
+
+// Reserve 预留一次请求和tokens个token，剩余请求次数为0或剩余token不足tokens时返回 *QuotaExceededError
+func (s *QuotaService) Reserve(ctx context.Context, userID string, tokens int64) (*QuotaReservation, error) {
+	quota, err := s.quotaRepo.GetUserQuota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	limits := effectiveLimits(quota.PlanLimits, quota.Overrides)
+
+	at := s.now()
+	dayPeriod, monthPeriod, dayStart, monthStart := quotaPeriods(at)
+	err = s.quotaRepo.Reserve(ctx, userID, dayPeriod, monthPeriod, tokens, func(daily, monthly repository.QuotaUsage) error {
+		status := &models.QuotaStatus{
+			UserID:  userID,
+			Plan:    quota.Plan,
+			Daily:   quotaWindow(limits.DailyRequests, limits.DailyTokens, daily, dayStart.AddDate(0, 0, 1)),
+			Monthly: quotaWindow(limits.MonthlyRequests, limits.MonthlyTokens, monthly, monthStart.AddDate(0, 1, 0)),
+		}
+		// 先检查月配额，同时超出时返回重置时间更晚的一项
+		windows := []struct {
+			requests, tokens string
+			window           models.QuotaWindow
+		}{
+			{QuotaMonthlyRequests, QuotaMonthlyTokens, status.Monthly},
+			{QuotaDailyRequests, QuotaDailyTokens, status.Daily},
+		}
+		for _, w := range windows {
+			limit := ""
+			switch {
+			case insufficient(w.window.RequestsRemaining, 1):
+				limit = w.requests
+			case insufficient(w.window.TokensRemaining, tokens):
+				limit = w.tokens
+			default:
+				continue
+			}
+			return &QuotaExceededError{Limit: limit, ResetAt: w.window.ResetAt, RequiredTokens: tokens, Status: status}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &QuotaReservation{UserID: userID, At: at, Tokens: tokens}, nil
+}
+
+// Settle 按实际使用的token数结算预留，succeeded为false时同时释放占用的请求次数；r为nil时不做任何事
+func (s *QuotaService) Settle(ctx context.Context, r *QuotaReservation, usedTokens int64, succeeded bool) error {
+	if r == nil {
+		return nil
+	}
+	var requests int64
+	if !succeeded {
+		requests = -1
+	}
+	tokens := usedTokens - r.Tokens
+	if requests == 0 && tokens == 0 {
+		return nil
+	}
+	dayPeriod, monthPeriod, _, _ := quotaPeriods(r.At.In(s.now().Location()))
+	return s.quotaRepo.Adjust(ctx, r.UserID, dayPeriod, monthPeriod, requests, tokens)
+}
+
+// Status 获取用户当天和当月的配额使用情况，已用量包括进行中请求预留的部分
+func (s *QuotaService) Status(ctx context.Context, userID string) (*models.QuotaStatus, error) {
+	quota, err := s.quotaRepo.GetUserQuota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	limits := effectiveLimits(quota.PlanLimits, quota.Overrides)
+
+	dayPeriod, monthPeriod, dayStart, monthStart := quotaPeriods(s.now())
+	daily, monthly, err := s.quotaRepo.Usage(ctx, userID, dayPeriod, monthPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaStatus{
+		UserID:  userID,
+		Plan:    quota.Plan,
+		Daily:   quotaWindow(limits.DailyRequests, limits.DailyTokens, daily, dayStart.AddDate(0, 0, 1)),
+		Monthly: quotaWindow(limits.MonthlyRequests, limits.MonthlyTokens, monthly, monthStart.AddDate(0, 1, 0)),
+	}, nil
+}
+
+// ListPlans 列出全部套餐
+func (s *QuotaService) ListPlans(ctx context.Context) ([]models.QuotaPlan, error) {
+	return s.quotaRepo.ListPlans(ctx)
+}
+
+// UpdatePlan 创建或修改套餐配额，字段为nil表示不限制
+func (s *QuotaService) UpdatePlan(ctx context.Context, name string, limits models.QuotaLimits) (*models.QuotaPlan, error) {
+	if err := validateLimits(limits); err != nil {
+		return nil, err
+	}
+	plan := &models.QuotaPlan{Name: name, QuotaLimits: limits, UpdatedAt: s.now()}
+	if err := s.quotaRepo.UpsertPlan(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// GetUserQuota 获取用户的套餐和单独配额
+func (s *QuotaService) GetUserQuota(ctx context.Context, userID string) (*models.UserQuota, error) {
+	return s.quotaRepo.GetUserQuota(ctx, userID)
+}
+
+// UpdateUserQuota 修改用户套餐并整体替换单独配额，套餐必须已存在
+func (s *QuotaService) UpdateUserQuota(ctx context.Context, userID string, req models.UpdateUserQuotaRequest) (*models.UserQuota, error) {
+	if err := validateLimits(req.Overrides); err != nil {
+		return nil, err
+	}
+	if _, err := s.quotaRepo.GetPlan(ctx, req.Plan); err != nil {
+		return nil, err
+	}
+	if err := s.quotaRepo.SetUserQuota(ctx, userID, req.Plan, req.Overrides); err != nil {
+		return nil, err
+	}
+	return s.quotaRepo.GetUserQuota(ctx, userID)
+}
+
+// validateLimits 检查配额不为负数，nil表示不限制，0表示禁止使用
+func validateLimits(limits models.QuotaLimits) error {
+	fields := []struct {
+		name  string
+		limit *int64
+	}{
+		{QuotaDailyRequests, limits.DailyRequests},
+		{QuotaDailyTokens, limits.DailyTokens},
+		{QuotaMonthlyRequests, limits.MonthlyRequests},
+		{QuotaMonthlyTokens, limits.MonthlyTokens},
+	}
+	for _, field := range fields {
+		if field.limit != nil && *field.limit < 0 {
+			return fmt.Errorf("%w: %s", ErrInvalidQuotaLimits, field.name)
+		}
+	}
+	return nil
+}
+
+// effectiveLimits 用户单独设置的配额优先，未设置的沿用套餐配额
+func effectiveLimits(plan, overrides models.QuotaLimits) models.QuotaLimits {
+	pick := func(override, fallback *int64) *int64 {
+		if override != nil {
+			return override
+		}
+		return fallback
+	}
+	return models.QuotaLimits{
+		DailyRequests:   pick(overrides.DailyRequests, plan.DailyRequests),
+		DailyTokens:     pick(overrides.DailyTokens, plan.DailyTokens),
+		MonthlyRequests: pick(overrides.MonthlyRequests, plan.MonthlyRequests),
+		MonthlyTokens:   pick(overrides.MonthlyTokens, plan.MonthlyTokens),
+	}
+}
+
+func quotaWindow(requestsLimit, tokensLimit *int64, used repository.QuotaUsage, resetAt time.Time) models.QuotaWindow {
+	return models.QuotaWindow{
+		RequestsLimit:     requestsLimit,
+		RequestsUsed:      used.Requests,
+		RequestsRemaining: remaining(requestsLimit, used.Requests),
+		TokensLimit:       tokensLimit,
+		TokensUsed:        used.Tokens,
+		TokensRemaining:   remaining(tokensLimit, used.Tokens),
+		ResetAt:           resetAt,
+	}
+}
+
+// remaining 剩余配额，不限制时返回nil
+func remaining(limit *int64, used int64) *int64 {
+	if limit == nil {
+		return nil
+	}
+	left := max(*limit-used, 0)
+	return &left
+}
+
+// insufficient 剩余配额不足need时返回true
+func insufficient(remaining *int64, need int64) bool {
+	return remaining != nil && (*remaining <= 0 || *remaining < need)
+}
+
+// quotaPeriods 时间at所在自然日和自然月的统计周期名称及起始时间
+func quotaPeriods(at time.Time) (dayPeriod, monthPeriod string, dayStart, monthStart time.Time) {
+	dayStart = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	monthStart = time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, at.Location())
+	return "d:" + dayStart.Format("2006-01-02"), "m:" + monthStart.Format("2006-01"), dayStart, monthStart
+}
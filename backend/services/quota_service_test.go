@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// fakeQuotaRepo 以互斥锁模拟数据库行锁的配额仓储
+type fakeQuotaRepo struct {
+	repository.IQuotaRepository
+	quota models.UserQuota
+
+	mu    sync.Mutex
+	usage map[string]repository.QuotaUsage
+}
+
+func (r *fakeQuotaRepo) GetUserQuota(ctx context.Context, userID string) (*models.UserQuota, error) {
+	quota := r.quota
+	return &quota, nil
+}
+
+func (r *fakeQuotaRepo) Usage(ctx context.Context, userID string, dayPeriod, monthPeriod string) (repository.QuotaUsage, repository.QuotaUsage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.usage[dayPeriod], r.usage[monthPeriod], nil
+}
+
+func (r *fakeQuotaRepo) Reserve(ctx context.Context, userID string, dayPeriod, monthPeriod string, tokens int64, check func(daily, monthly repository.QuotaUsage) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := check(r.usage[dayPeriod], r.usage[monthPeriod]); err != nil {
+		return err
+	}
+	r.add(dayPeriod, 1, tokens)
+	r.add(monthPeriod, 1, tokens)
+	return nil
+}
+
+func (r *fakeQuotaRepo) Adjust(ctx context.Context, userID string, dayPeriod, monthPeriod string, requests, tokens int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(dayPeriod, requests, tokens)
+	r.add(monthPeriod, requests, tokens)
+	return nil
+}
+
+func (r *fakeQuotaRepo) add(period string, requests, tokens int64) {
+	usage := r.usage[period]
+	usage.Requests = max(usage.Requests+requests, 0)
+	usage.Tokens = max(usage.Tokens+tokens, 0)
+	r.usage[period] = usage
+}
+
+func quotaLimit(n int64) *int64 { return &n }
+
+func newTestQuotaService(limits models.QuotaLimits, daily, monthly repository.QuotaUsage) (*QuotaService, *fakeQuotaRepo) {
+	repo := &fakeQuotaRepo{
+		quota: models.UserQuota{Plan: "free", PlanLimits: limits},
+		usage: map[string]repository.QuotaUsage{"d:2025-04-20": daily, "m:2025-04": monthly},
+	}
+	s := NewQuotaService(repo)
+	s.now = func() time.Time { return time.Date(2025, 4, 20, 15, 0, 0, 0, time.UTC) }
+	return s, repo
+}
+
+func TestQuotaService_Reserve(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestQuotaService(
+		models.QuotaLimits{DailyRequests: quotaLimit(10), DailyTokens: quotaLimit(1000), MonthlyTokens: quotaLimit(5000)},
+		repository.QuotaUsage{Requests: 8, Tokens: 800},
+		repository.QuotaUsage{Requests: 40, Tokens: 4000},
+	)
+
+	status, err := s.Status(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *status.Daily.RequestsRemaining)
+	assert.Nil(t, status.Monthly.RequestsRemaining)
+	assert.Equal(t, time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC), status.Daily.ResetAt)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), status.Monthly.ResetAt)
+
+	// 预估的token数超过剩余配额时不预留
+	var exceeded *QuotaExceededError
+	_, err = s.Reserve(ctx, "u1", 300)
+	require.ErrorAs(t, err, &exceeded)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Equal(t, QuotaDailyTokens, exceeded.Limit)
+	assert.Equal(t, int64(300), exceeded.RequiredTokens)
+	assert.Equal(t, repository.QuotaUsage{Requests: 8, Tokens: 800}, repo.usage["d:2025-04-20"])
+
+	// 预留立即计入用量，结算时按实际用量调整
+	reservation, err := s.Reserve(ctx, "u1", 150)
+	require.NoError(t, err)
+	assert.Equal(t, repository.QuotaUsage{Requests: 9, Tokens: 950}, repo.usage["d:2025-04-20"])
+	require.NoError(t, s.Settle(ctx, reservation, 100, true))
+	assert.Equal(t, repository.QuotaUsage{Requests: 9, Tokens: 900}, repo.usage["d:2025-04-20"])
+	assert.Equal(t, repository.QuotaUsage{Requests: 41, Tokens: 4100}, repo.usage["m:2025-04"])
+
+	// 失败的请求释放占用的次数，只计入实际使用的token
+	reservation, err = s.Reserve(ctx, "u1", 50)
+	require.NoError(t, err)
+	_, err = s.Reserve(ctx, "u1", 10)
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, QuotaDailyRequests, exceeded.Limit)
+	require.NoError(t, s.Settle(ctx, reservation, 20, false))
+	assert.Equal(t, repository.QuotaUsage{Requests: 9, Tokens: 920}, repo.usage["d:2025-04-20"])
+
+	// 同时超出日配额和月配额时返回重置更晚的月配额
+	repo.usage["m:2025-04"] = repository.QuotaUsage{Tokens: 6000}
+	_, err = s.Reserve(ctx, "u1", 10)
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, QuotaMonthlyTokens, exceeded.Limit)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), exceeded.ResetAt)
+
+	// 单独设置的配额优先于套餐配额
+	repo.quota.Overrides = models.QuotaLimits{MonthlyTokens: quotaLimit(10000)}
+	_, err = s.Reserve(ctx, "u1", 10)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Settle(ctx, nil, 100, true))
+}
+
+func TestQuotaService_ReserveConcurrent(t *testing.T) {
+	s, repo := newTestQuotaService(
+		models.QuotaLimits{DailyRequests: quotaLimit(10)},
+		repository.QuotaUsage{Requests: 7},
+		repository.QuotaUsage{},
+	)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Reserve(context.Background(), "u1", 100); err == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, reserved)
+	assert.Equal(t, int64(10), repo.usage["d:2025-04-20"].Requests)
+}
+
+func TestQuotaService_RejectNegativeLimits(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestQuotaService(models.QuotaLimits{}, repository.QuotaUsage{}, repository.QuotaUsage{})
+
+	_, err := s.UpdatePlan(ctx, "free", models.QuotaLimits{DailyTokens: quotaLimit(-1)})
+	assert.ErrorIs(t, err, ErrInvalidQuotaLimits)
+
+	_, err = s.UpdateUserQuota(ctx, "u1", models.UpdateUserQuotaRequest{
+		Plan:      "free",
+		Overrides: models.QuotaLimits{MonthlyRequests: quotaLimit(-5)},
+	})
+	assert.ErrorIs(t, err, ErrInvalidQuotaLimits)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// ReconcileService 对账服务，找出对象存储与数据库之间不一致的数据
+type ReconcileService struct {
+	reportRepo repository.IReportRepository
+	store      storage.BlobStore
+	// gracePeriod 最近这段时间内写入的对象不视为孤立对象，避免误删正在上传、尚未写入数据库的文件
+	gracePeriod time.Duration
+}
+
+// ReconcileResult 对账结果
+type ReconcileResult struct {
+	ScannedObjects int
+	ScannedReports int
+	// OrphanObjects 存储桶中没有任何报告引用的对象
+	OrphanObjects []storage.ObjectInfo
+	// MissingObjects PDF对象不存在的报告
+	MissingObjects []models.Report
+	// RemovedObjects 修复时删除的孤立对象数量
+	RemovedObjects int
+	// TrashedReports 修复时移入回收站的报告数量
+	TrashedReports int
+}
+
+// NewReconcileService 创建对账服务
+func NewReconcileService(reportRepo repository.IReportRepository, store storage.BlobStore, gracePeriod time.Duration) *ReconcileService {
+	return &ReconcileService{
+		reportRepo:  reportRepo,
+		store:       store,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Reconcile 比对存储桶对象与报告记录；fix为true时删除孤立对象，并将缺少PDF的报告移入回收站
+// 缺少PDF的报告只移入回收站而不直接删除，保留期内仍可恢复其文本内容
+func (s *ReconcileService) Reconcile(ctx context.Context, fix bool) (*ReconcileResult, error) {
+	// 先读数据库再列对象：两次读取之间新上传的对象会因宽限期被跳过，而不会被误判为孤立对象
+	reports, err := s.reportRepo.ListPDFPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := s.store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{
+		ScannedObjects: len(objects),
+		ScannedReports: len(reports),
+	}
+
+	referenced := make(map[string]bool, len(reports))
+	for _, report := range reports {
+		if key := objectKey(report.PDFPath); key != "" {
+			referenced[key] = true
+		}
+	}
+	existing := make(map[string]bool, len(objects))
+	cutoff := time.Now().Add(-s.gracePeriod)
+	for _, object := range objects {
+		existing[object.Key] = true
+		if !referenced[object.Key] && object.LastModified.Before(cutoff) {
+			result.OrphanObjects = append(result.OrphanObjects, object)
+		}
+	}
+
+	for _, report := range reports {
+		key := objectKey(report.PDFPath)
+		if key == "" || existing[key] {
+			continue
+		}
+		// 列出对象后才创建的报告，其对象不在列表中，再确认一次
+		if _, err := s.store.Stat(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+			if err != nil {
+				return nil, fmt.Errorf("检查报告 %s 的PDF失败: %w", report.ID, err)
+			}
+			continue
+		}
+		result.MissingObjects = append(result.MissingObjects, report)
+	}
+
+	if !fix {
+		return result, nil
+	}
+
+	for _, object := range result.OrphanObjects {
+		if err := s.store.Remove(ctx, object.Key); err != nil {
+			log.Printf("删除孤立对象 %s 失败: %v", object.Key, err)
+			continue
+		}
+		result.RemovedObjects++
+	}
+	for _, report := range result.MissingObjects {
+		if report.DeletedAt != nil {
+			continue
+		}
+		if err := s.reportRepo.SoftDelete(ctx, report.ID); err != nil {
+			log.Printf("将报告 %s 移入回收站失败: %v", report.ID, err)
+			continue
+		}
+		result.TrashedReports++
+	}
+	return result, nil
+}
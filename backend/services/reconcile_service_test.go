@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// fakeReportRepo 只实现对账和权限检查用到的方法
+type fakeReportRepo struct {
+	repository.IReportRepository
+	reports []models.Report
+	trashed []string
+}
+
+func (r *fakeReportRepo) ListPDFPaths(context.Context) ([]models.Report, error) {
+	return r.reports, nil
+}
+
+func (r *fakeReportRepo) GetByID(_ context.Context, reportID string) (*models.Report, error) {
+	for _, report := range r.reports {
+		if report.ID == reportID && report.DeletedAt == nil {
+			return &report, nil
+		}
+	}
+	return nil, repository.ErrReportNotFound
+}
+
+func (r *fakeReportRepo) SoftDelete(_ context.Context, reportID string) error {
+	r.trashed = append(r.trashed, reportID)
+	return nil
+}
+
+func TestReconcileService_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	for _, key := range []string{"kept.pdf", "orphan.pdf"} {
+		_, err := store.Put(ctx, key, strings.NewReader("%PDF"), 4, "application/pdf")
+		require.NoError(t, err)
+	}
+	deletedAt := time.Now()
+	repo := &fakeReportRepo{reports: []models.Report{
+		{ID: "r1", UserID: "u1", PDFPath: "reports/kept.pdf"},
+		{ID: "r2", UserID: "u1", PDFPath: "reports/missing.pdf"},
+		{ID: "r3", UserID: "u1", PDFPath: "reports/gone.pdf", DeletedAt: &deletedAt},
+	}}
+
+	// 宽限期内的对象不算孤立
+	result, err := NewReconcileService(repo, store, time.Hour).Reconcile(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.OrphanObjects)
+	assert.Len(t, result.MissingObjects, 2)
+
+	result, err = NewReconcileService(repo, store, 0).Reconcile(ctx, true)
+	require.NoError(t, err)
+	if assert.Len(t, result.OrphanObjects, 1) {
+		assert.Equal(t, "orphan.pdf", result.OrphanObjects[0].Key)
+	}
+	assert.Equal(t, 1, result.RemovedObjects)
+	assert.Equal(t, 1, result.TrashedReports)
+	assert.Equal(t, []string{"r2"}, repo.trashed)
+
+	_, err = store.Stat(ctx, "orphan.pdf")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.Stat(ctx, "kept.pdf")
+	assert.NoError(t, err)
+}
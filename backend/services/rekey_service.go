@@ -0,0 +1,328 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// rekeyBatchSize 重新加密时每批读取的报告数量
+const rekeyBatchSize = 100
+
+// RekeyService 密钥轮换服务
+type RekeyService struct {
+	keys       *KeyService
+	reportRepo repository.IReportRepository
+	pageRepo   repository.IReportPageRepository
+	blobRepo   repository.IBlobRepository
+	store      storage.BlobStore
+}
+
+// RekeyResult 密钥轮换结果
+type RekeyResult struct {
+	// RewrappedKeys 以当前主密钥重新包装的数据密钥数量
+	RewrappedKeys int
+	// RotatedUsers 生成了新数据密钥的用户数量
+	RotatedUsers int
+	// Reports、Pages、Objects 重新加密的报告、页面和PDF对象数量
+	Reports int
+	Pages   int
+	Objects int
+	// MigratedPDFs 从加密功能启用前的明文PDF迁移到用户自己的加密PDF的报告数量
+	MigratedPDFs int
+	// Skipped 因读取后被并发修改而跳过的数量，再次执行时会重新处理被修改的数据
+	Skipped int
+}
+
+// NewRekeyService 创建密钥轮换服务
+func NewRekeyService(keys *KeyService, reportRepo repository.IReportRepository, pageRepo repository.IReportPageRepository, blobRepo repository.IBlobRepository, store storage.BlobStore) *RekeyService {
+	return &RekeyService{
+		keys:       keys,
+		reportRepo: reportRepo,
+		pageRepo:   pageRepo,
+		blobRepo:   blobRepo,
+		store:      store,
+	}
+}
+
+// Rekey 以当前主密钥重新包装全部数据密钥；rotateDataKeys为true时再为每个用户生成新的数据密钥，
+// 并用新密钥重新加密报告内容、摘要、分页文本和PDF，加密功能启用前写入的明文内容也会被加密
+// 重新加密的PDF写入新的对象键后再切换引用，不覆盖正在被读取的对象，旧对象由对账任务清理；
+// 加密功能启用前上传的明文PDF可能被多个用户共享，每个用户的报告改为引用该用户自己的加密副本，最后一个引用释放后删除明文对象
+func (s *RekeyService) Rekey(ctx context.Context, rotateDataKeys bool) (*RekeyResult, error) {
+	result := &RekeyResult{}
+	rewrapped, err := s.keys.RewrapKeys(ctx)
+	result.RewrappedKeys = rewrapped
+	if err != nil || !rotateDataKeys {
+		return result, err
+	}
+
+	userIDs, err := s.reportRepo.ListUserIDs(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, userID := range userIDs {
+		userKey, err := s.keys.RotateUserKey(ctx, userID)
+		if err != nil {
+			return result, fmt.Errorf("轮换用户%s的数据密钥失败: %w", userID, err)
+		}
+		result.RotatedUsers++
+		if err := s.reencryptUser(ctx, userID, userKey.ID, result); err != nil {
+			return result, fmt.Errorf("重新加密用户%s的数据失败: %w", userID, err)
+		}
+	}
+	return result, nil
+}
+
+// reencryptUser 用用户当前的数据密钥重新加密其全部报告
+func (s *RekeyService) reencryptUser(ctx context.Context, userID string, keyID int64, result *RekeyResult) error {
+	objects := &userObjects{rekeyed: make(map[string]bool), plainHashes: make(map[string]string)}
+	afterID := ""
+	for {
+		reports, err := s.reportRepo.ListTextByUserID(ctx, userID, afterID, rekeyBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, report := range reports {
+			if err := s.reencryptReport(ctx, &report, keyID, result); err != nil {
+				return err
+			}
+			if err := s.reencryptPDF(ctx, &report, keyID, objects, result); err != nil {
+				return fmt.Errorf("报告%s的PDF: %w", report.ID, err)
+			}
+		}
+		if len(reports) < rekeyBatchSize {
+			return nil
+		}
+		afterID = reports[len(reports)-1].ID
+	}
+}
+
+// userObjects 同一用户的多个报告可能引用同一个PDF对象，记录已处理过的对象
+type userObjects struct {
+	// rekeyed 已重新加密（或已使用当前密钥）的对象键
+	rekeyed map[string]bool
+	// plainHashes 明文对象键到该用户加密副本的对象哈希
+	plainHashes map[string]string
+}
+
+// reencryptReport 重新加密报告的内容、摘要和分页文本
+func (s *RekeyService) reencryptReport(ctx context.Context, report *models.Report, keyID int64, result *RekeyResult) error {
+	updated := *report
+	changed := false
+	for _, field := range []*string{&updated.Content, &updated.Summary} {
+		value, ok, err := s.reencryptValue(ctx, report.UserID, *field, keyID)
+		if err != nil {
+			return fmt.Errorf("报告%s: %w", report.ID, err)
+		}
+		*field = value
+		changed = changed || ok
+	}
+	if changed {
+		err := s.reportRepo.ReplaceText(ctx, report, &updated)
+		switch {
+		case errors.Is(err, repository.ErrReportNotFound):
+			result.Skipped++
+		case err != nil:
+			return err
+		default:
+			result.Reports++
+		}
+	}
+
+	pages, err := s.pageRepo.GetPages(ctx, report.ID)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		value, ok, err := s.reencryptValue(ctx, report.UserID, page.Content, keyID)
+		if err != nil {
+			return fmt.Errorf("报告%s第%d页: %w", report.ID, page.PageNumber, err)
+		}
+		if !ok {
+			continue
+		}
+		err = s.pageRepo.ReplaceContent(ctx, report.ID, page.PageNumber, page.Content, value)
+		switch {
+		case errors.Is(err, repository.ErrPageNotFound):
+			result.Skipped++
+		case err != nil:
+			return err
+		default:
+			result.Pages++
+		}
+	}
+	return nil
+}
+
+// reencryptValue 用当前数据密钥重新加密字段值，已使用该密钥加密或为空时返回false
+func (s *RekeyService) reencryptValue(ctx context.Context, userID string, value string, keyID int64) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if id, _, ok := parseEncryptedValue(value); ok && id == keyID {
+		return value, false, nil
+	}
+	plaintext, err := s.keys.DecryptString(ctx, value)
+	if err != nil {
+		return "", false, err
+	}
+	encrypted, err := s.keys.EncryptString(ctx, userID, plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return encrypted, true, nil
+}
+
+// reencryptPDF 重新加密报告引用的PDF对象，明文对象迁移到用户自己的加密副本
+func (s *RekeyService) reencryptPDF(ctx context.Context, report *models.Report, keyID int64, objects *userObjects, result *RekeyResult) error {
+	key := objectKey(report.PDFPath)
+	if key == "" || objects.rekeyed[key] {
+		return nil
+	}
+	object, info, err := s.store.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	objectKeyID, err := utils.ReadEncryptedKeyID(object)
+	if errors.Is(err, utils.ErrNotEncrypted) {
+		return s.migratePlainObject(ctx, report, object, info, keyID, objects, result)
+	}
+	if err != nil {
+		return err
+	}
+	objects.rekeyed[key] = true
+	if objectKeyID == keyID {
+		return nil
+	}
+	return s.reencryptObject(ctx, report, key, object, info, keyID, result)
+}
+
+// reencryptObject 用当前数据密钥重新加密PDF对象，写入新的对象键后在同一事务中切换对象记录和报告路径
+// 切换失败时删除新对象，旧对象保持不变；切换成功后旧对象也不在此删除：切换前已读到旧对象键的上传仍会写入指向旧对象的报告，
+// 旧对象不再被引用后由对账任务清理
+func (s *RekeyService) reencryptObject(ctx context.Context, report *models.Report, key string, object storage.Object, info storage.ObjectInfo, keyID int64, result *RekeyResult) error {
+	if report.ContentHash == "" {
+		return fmt.Errorf("PDF对象%s没有对应的内容哈希", key)
+	}
+	plain, plainInfo, err := s.keys.OpenObject(ctx, object, info)
+	if err != nil {
+		return err
+	}
+	reader, size, err := s.keys.EncryptObject(ctx, report.UserID, plain, plainInfo.Size)
+	if err != nil {
+		return err
+	}
+	newKey := rekeyedObjectKey(report.ContentHash, keyID)
+	if _, err := s.store.Put(ctx, newKey, reader, size, info.ContentType); err != nil {
+		return fmt.Errorf("上传PDF对象%s失败: %w", newKey, err)
+	}
+
+	err = s.blobRepo.ReplaceObject(ctx, report.ContentHash, key, newKey)
+	if err != nil {
+		s.removeObject(ctx, newKey)
+		if errors.Is(err, repository.ErrBlobNotFound) {
+			result.Skipped++
+			return nil
+		}
+		return err
+	}
+	result.Objects++
+	return nil
+}
+
+// migratePlainObject 将报告从明文PDF迁移到用户自己的加密副本：先增加副本的引用并确保副本已上传，
+// 再替换报告的PDF路径和内容哈希，最后释放对明文对象的引用，最后一个引用释放时删除明文对象
+func (s *RekeyService) migratePlainObject(ctx context.Context, report *models.Report, object storage.Object, info storage.ObjectInfo, keyID int64, objects *userObjects, result *RekeyResult) error {
+	key := objectKey(report.PDFPath)
+	hash, ok := objects.plainHashes[key]
+	if !ok {
+		contentHash, err := hashContent(object, info.Size)
+		if err != nil {
+			return err
+		}
+		hash = userBlobHash(report.UserID, contentHash)
+		objects.plainHashes[key] = hash
+	}
+
+	blob := &models.Blob{Hash: hash, ObjectKey: fmt.Sprintf("%s.pdf", hash), Size: info.Size}
+	if err := s.blobRepo.Acquire(ctx, blob); err != nil {
+		return err
+	}
+	if err := s.ensureEncrypted(ctx, report.UserID, blob.ObjectKey, object, info); err != nil {
+		s.releaseBlob(ctx, hash)
+		return err
+	}
+
+	migrated := *report
+	migrated.PDFPath = filepath.Join(filepath.Dir(report.PDFPath), blob.ObjectKey)
+	migrated.ContentHash = hash
+	err := s.reportRepo.ReplacePDF(ctx, report, &migrated)
+	if err != nil {
+		s.releaseBlob(ctx, hash)
+		if errors.Is(err, repository.ErrReportNotFound) {
+			result.Skipped++
+			return nil
+		}
+		return err
+	}
+	if report.ContentHash != "" {
+		s.releaseBlob(ctx, report.ContentHash)
+	}
+	result.MigratedPDFs++
+	// 用户之前上传过相同内容时副本已存在，可能仍使用旧的数据密钥
+	return s.reencryptPDF(ctx, &migrated, keyID, objects, result)
+}
+
+// ensureEncrypted 用户的加密副本不存在时以当前数据密钥加密明文对象后上传
+func (s *RekeyService) ensureEncrypted(ctx context.Context, userID string, key string, plain storage.Object, info storage.ObjectInfo) error {
+	_, err := s.store.Stat(ctx, key)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("检查PDF对象%s失败: %w", key, err)
+	}
+	reader, size, err := s.keys.EncryptObject(ctx, userID, plain, info.Size)
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.Put(ctx, key, reader, size, info.ContentType); err != nil {
+		return fmt.Errorf("上传PDF对象%s失败: %w", key, err)
+	}
+	return nil
+}
+
+// releaseBlob 释放对PDF对象的引用，最后一个引用释放时删除对象
+func (s *RekeyService) releaseBlob(ctx context.Context, hash string) {
+	err := s.blobRepo.Release(ctx, hash, func(objectKey string) error {
+		return s.store.Remove(ctx, objectKey)
+	})
+	if err != nil {
+		log.Printf("释放PDF对象 %s 的引用失败: %v", hash, err)
+	}
+}
+
+// removeObject 删除未被引用的新对象，失败时只记录日志，留下的对象由对账任务清理
+func (s *RekeyService) removeObject(ctx context.Context, key string) {
+	if err := s.store.Remove(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("删除PDF对象%s失败: %v", key, err)
+	}
+}
+
+// rekeyedObjectKey 重新加密后的对象键，包含数据密钥ID，与正在被读取的旧对象区分
+func rekeyedObjectKey(contentHash string, keyID int64) string {
+	return fmt.Sprintf("%s.k%d.pdf", contentHash, keyID)
+}
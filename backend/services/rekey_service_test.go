@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// rekeyReportRepo 在fakeReportRepo的基础上实现重新加密用到的方法
+type rekeyReportRepo struct {
+	fakeReportRepo
+}
+
+func (r *rekeyReportRepo) ListUserIDs(context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, report := range r.reports {
+		if !seen[report.UserID] {
+			seen[report.UserID] = true
+			userIDs = append(userIDs, report.UserID)
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+func (r *rekeyReportRepo) ListTextByUserID(_ context.Context, userID string, afterID string, limit int) ([]models.Report, error) {
+	var reports []models.Report
+	for _, report := range r.reports {
+		if report.UserID == userID && report.ID > afterID && len(reports) < limit {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+func (r *rekeyReportRepo) ReplaceText(_ context.Context, old *models.Report, report *models.Report) error {
+	for i := range r.reports {
+		if r.reports[i].ID == old.ID && r.reports[i].Content == old.Content && r.reports[i].Summary == old.Summary {
+			r.reports[i].Content, r.reports[i].Summary = report.Content, report.Summary
+			return nil
+		}
+	}
+	return repository.ErrReportNotFound
+}
+
+func (r *rekeyReportRepo) ReplacePDF(_ context.Context, old *models.Report, report *models.Report) error {
+	for i := range r.reports {
+		if r.reports[i].ID == old.ID && r.reports[i].PDFPath == old.PDFPath && r.reports[i].ContentHash == old.ContentHash {
+			r.reports[i].PDFPath, r.reports[i].ContentHash = report.PDFPath, report.ContentHash
+			return nil
+		}
+	}
+	return repository.ErrReportNotFound
+}
+
+// emptyPageRepo 没有分页记录的页面仓储
+type emptyPageRepo struct {
+	repository.IReportPageRepository
+}
+
+func (emptyPageRepo) GetPages(context.Context, string) ([]models.ReportPage, error) {
+	return nil, nil
+}
+
+// fakeBlobRepo 内存中的PDF对象引用计数，切换对象键时同时更新报告路径
+type fakeBlobRepo struct {
+	blobs   map[string]*models.Blob
+	reports *rekeyReportRepo
+}
+
+func (r *fakeBlobRepo) Acquire(_ context.Context, blob *models.Blob) error {
+	if existing, ok := r.blobs[blob.Hash]; ok {
+		existing.RefCount++
+		blob.ObjectKey = existing.ObjectKey
+		return nil
+	}
+	created := *blob
+	created.RefCount = 1
+	r.blobs[blob.Hash] = &created
+	return nil
+}
+
+func (r *fakeBlobRepo) Release(_ context.Context, hash string, onLast func(objectKey string) error) error {
+	blob, ok := r.blobs[hash]
+	if !ok {
+		return nil
+	}
+	if blob.RefCount > 1 {
+		blob.RefCount--
+		return nil
+	}
+	delete(r.blobs, hash)
+	return onLast(blob.ObjectKey)
+}
+
+func (r *fakeBlobRepo) ReplaceObject(_ context.Context, hash string, oldKey string, newKey string) error {
+	blob, ok := r.blobs[hash]
+	if !ok || blob.ObjectKey != oldKey {
+		return repository.ErrBlobNotFound
+	}
+	blob.ObjectKey = newKey
+	for i, report := range r.reports.reports {
+		if report.ContentHash == hash && objectKey(report.PDFPath) == oldKey {
+			r.reports.reports[i].PDFPath = filepath.Join(filepath.Dir(report.PDFPath), newKey)
+		}
+	}
+	return nil
+}
+
+func TestRekeyService_ReencryptPDFs(t *testing.T) {
+	ctx := context.Background()
+	masterKeys, err := ParseMasterKeys("1:" + "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	require.NoError(t, err)
+	keys, err := NewKeyService(&fakeUserKeyRepo{}, masterKeys, 0)
+	require.NoError(t, err)
+	store := storage.NewMemoryStore()
+
+	// 加密功能启用前上传、被两个用户共享的明文PDF
+	plainPDF := []byte("%PDF-1.4 shared plaintext")
+	plainHash, err := hashContent(bytes.NewReader(plainPDF), int64(len(plainPDF)))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, plainHash+".pdf", bytes.NewReader(plainPDF), int64(len(plainPDF)), "application/pdf")
+	require.NoError(t, err)
+
+	// u1以旧数据密钥加密的PDF
+	ownPDF := []byte("%PDF-1.4 encrypted for u1")
+	ownHash, err := hashContent(bytes.NewReader(ownPDF), int64(len(ownPDF)))
+	require.NoError(t, err)
+	ownHash = userBlobHash("u1", ownHash)
+	reader, size, err := keys.EncryptObject(ctx, "u1", bytes.NewReader(ownPDF), int64(len(ownPDF)))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, ownHash+".pdf", reader, size, "application/pdf")
+	require.NoError(t, err)
+
+	reportRepo := &rekeyReportRepo{fakeReportRepo{reports: []models.Report{
+		{ID: "r1", UserID: "u1", PDFPath: "reports/" + plainHash + ".pdf", ContentHash: plainHash},
+		{ID: "r2", UserID: "u2", PDFPath: "reports/" + plainHash + ".pdf", ContentHash: plainHash},
+		{ID: "r3", UserID: "u1", PDFPath: "reports/" + ownHash + ".pdf", ContentHash: ownHash},
+	}}}
+	blobRepo := &fakeBlobRepo{reports: reportRepo, blobs: map[string]*models.Blob{
+		plainHash: {Hash: plainHash, ObjectKey: plainHash + ".pdf", RefCount: 2},
+		ownHash:   {Hash: ownHash, ObjectKey: ownHash + ".pdf", RefCount: 1},
+	}}
+
+	result, err := NewRekeyService(keys, reportRepo, emptyPageRepo{}, blobRepo, store).Rekey(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.RotatedUsers)
+	assert.Equal(t, 2, result.MigratedPDFs)
+	assert.Equal(t, 1, result.Objects)
+	assert.Zero(t, result.Skipped)
+
+	// readPDF 读取报告引用的PDF，返回明文和加密使用的数据密钥ID
+	readPDF := func(report models.Report) ([]byte, int64) {
+		object, info, err := store.Get(ctx, objectKey(report.PDFPath))
+		require.NoError(t, err)
+		defer object.Close()
+		keyID, err := utils.ReadEncryptedKeyID(object)
+		require.NoError(t, err)
+		plain, _, err := keys.OpenObject(ctx, object, info)
+		require.NoError(t, err)
+		data, err := io.ReadAll(plain)
+		require.NoError(t, err)
+		return data, keyID
+	}
+	activeKeyID := func(userID string) int64 {
+		key, err := keys.keyRepo.GetActive(ctx, userID)
+		require.NoError(t, err)
+		return key.ID
+	}
+
+	// 共享的明文PDF迁移为每个用户各自的加密副本，最后一个引用释放后删除
+	for _, report := range reportRepo.reports[:2] {
+		hash := userBlobHash(report.UserID, plainHash)
+		assert.Equal(t, hash, report.ContentHash)
+		data, keyID := readPDF(report)
+		assert.Equal(t, plainPDF, data)
+		assert.Equal(t, activeKeyID(report.UserID), keyID)
+		assert.Equal(t, 1, blobRepo.blobs[hash].RefCount)
+	}
+	assert.NotContains(t, blobRepo.blobs, plainHash)
+	_, err = store.Stat(ctx, plainHash+".pdf")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// 重新加密的PDF写入新的对象键并切换引用，旧对象留给对账任务清理
+	r3 := reportRepo.reports[2]
+	newKey := rekeyedObjectKey(ownHash, activeKeyID("u1"))
+	assert.Equal(t, "reports/"+newKey, r3.PDFPath)
+	assert.Equal(t, newKey, blobRepo.blobs[ownHash].ObjectKey)
+	data, keyID := readPDF(r3)
+	assert.Equal(t, ownPDF, data)
+	assert.Equal(t, activeKeyID("u1"), keyID)
+	_, err = store.Stat(ctx, ownHash+".pdf")
+	assert.NoError(t, err)
+
+	// 切换前已读到旧对象键的上传仍能打开旧对象，之后的上传使用新的对象键
+	late := &models.Blob{Hash: ownHash, ObjectKey: ownHash + ".pdf"}
+	require.NoError(t, blobRepo.Acquire(ctx, late))
+	assert.Equal(t, newKey, late.ObjectKey)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// AuthorizeReport 检查用户能否对报告执行操作，返回解密后的报告和用户对报告的角色
+// 用户对报告没有任何角色时返回repository.ErrReportNotFound，角色权限不足时返回authz.ErrForbidden
+func (s *ReportService) AuthorizeReport(ctx context.Context, reportID string, userID string, action authz.Action) (*models.Report, authz.Role, error) {
+	report, role, err := s.authorizeReport(ctx, reportID, userID, action)
+	if err != nil {
+		return nil, role, err
+	}
+	if err := s.decryptReport(ctx, report); err != nil {
+		return nil, role, err
+	}
+	return report, role, nil
+}
+
+// authorizeReport 读取报告并检查权限，不解密内容，用于只需要标题和PDF路径的场景
+func (s *ReportService) authorizeReport(ctx context.Context, reportID string, userID string, action authz.Action) (*models.Report, authz.Role, error) {
+	report, err := s.reportRepo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, authz.RoleNone, err
+	}
+	role, err := s.Policy.Authorize(ctx, userID, report, action)
+	if errors.Is(err, authz.ErrNoAccess) {
+		// 不区分报告不存在和无权访问，避免泄露报告是否存在
+		return nil, role, repository.ErrReportNotFound
+	}
+	if err != nil {
+		return nil, role, err
+	}
+	return report, role, nil
+}
+
+// loadReport 读取报告并解密内容和摘要，调用方负责检查权限
+func (s *ReportService) loadReport(ctx context.Context, reportID string) (*models.Report, error) {
+	report, err := s.reportRepo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptReport(ctx, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
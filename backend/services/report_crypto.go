@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// blobHash 返回PDF对象的寻址哈希；启用加密时PDF以用户的数据密钥加密，相同内容只在同一用户内共享
+func (s *ReportService) blobHash(userID string, contentHash string) string {
+	if !s.Keys.Enabled() {
+		return contentHash
+	}
+	return userBlobHash(userID, contentHash)
+}
+
+// userBlobHash 按用户区分的对象哈希，相同内容的PDF只在同一用户的报告之间共享
+func userBlobHash(userID string, contentHash string) string {
+	sum := sha256.Sum256([]byte(userID + ":" + contentHash))
+	return hex.EncodeToString(sum[:])
+}
+
+// encryptReport 返回内容和摘要加密后的报告副本，用于写入数据库
+func (s *ReportService) encryptReport(ctx context.Context, report *models.Report) (*models.Report, error) {
+	encrypted := *report
+	var err error
+	if encrypted.Content, err = s.Keys.EncryptString(ctx, report.UserID, report.Content); err != nil {
+		return nil, err
+	}
+	if encrypted.Summary, err = s.Keys.EncryptString(ctx, report.UserID, report.Summary); err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+// decryptReport 解密从数据库读取的报告内容和摘要
+func (s *ReportService) decryptReport(ctx context.Context, report *models.Report) error {
+	var err error
+	if report.Content, err = s.Keys.DecryptString(ctx, report.Content); err != nil {
+		return fmt.Errorf("解密报告%s的内容失败: %w", report.ID, err)
+	}
+	if report.Summary, err = s.Keys.DecryptString(ctx, report.Summary); err != nil {
+		return fmt.Errorf("解密报告%s的摘要失败: %w", report.ID, err)
+	}
+	return nil
+}
+
+// encryptPages 返回页面文本加密后的副本，用于写入数据库
+func (s *ReportService) encryptPages(ctx context.Context, userID string, pages []models.ReportPage) ([]models.ReportPage, error) {
+	encrypted := make([]models.ReportPage, len(pages))
+	for i, page := range pages {
+		content, err := s.Keys.EncryptString(ctx, userID, page.Content)
+		if err != nil {
+			return nil, err
+		}
+		page.Content = content
+		encrypted[i] = page
+	}
+	return encrypted, nil
+}
+
+// decryptPages 解密从数据库读取的页面文本
+func (s *ReportService) decryptPages(ctx context.Context, pages []models.ReportPage) error {
+	for i := range pages {
+		content, err := s.Keys.DecryptString(ctx, pages[i].Content)
+		if err != nil {
+			return fmt.Errorf("解密报告%s第%d页失败: %w", pages[i].ReportID, pages[i].PageNumber, err)
+		}
+		pages[i].Content = content
+	}
+	return nil
+}
+
+// savePages 加密并保存报告的分页文本
+func (s *ReportService) savePages(ctx context.Context, userID string, reportID string, pages []models.ReportPage) error {
+	encrypted, err := s.encryptPages(ctx, userID, pages)
+	if err != nil {
+		return err
+	}
+	return s.pageRepo.SaveAll(ctx, reportID, encrypted)
+}
+
+// saveSummary 加密并保存报告摘要，选项明文保存
+func (s *ReportService) saveSummary(ctx context.Context, report *models.Report, summary string, opts models.SummaryOptions) error {
+	encrypted, err := s.Keys.EncryptString(ctx, report.UserID, summary)
+	if err != nil {
+		return err
+	}
+	return s.reportRepo.UpdateSummary(ctx, report.ID, encrypted, opts)
+}
+
+// openPDF 打开报告的PDF对象，加密的对象按需解密，返回的大小为明文长度
+func (s *ReportService) openPDF(ctx context.Context, pdfPath string) (storage.Object, storage.ObjectInfo, error) {
+	object, info, err := s.Store.Get(ctx, objectKey(pdfPath))
+	if err != nil {
+		return nil, storage.ObjectInfo{}, err
+	}
+	opened, info, err := s.Keys.OpenObject(ctx, object, info)
+	if err != nil {
+		object.Close()
+		return nil, storage.ObjectInfo{}, err
+	}
+	return opened, info, nil
+}
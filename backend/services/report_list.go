@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+// 报告列表分页参数
+const (
+	reportListDefaultLimit = 20
+	reportListMaxLimit     = 100
+)
+
+// ErrInvalidListQuery 报告列表查询参数无效
+var ErrInvalidListQuery = errors.New("无效的查询参数")
+
+// ListReports 按条件分页获取用户的报告列表
+func (s *ReportService) ListReports(ctx context.Context, userID string, query models.ReportListQuery) (*models.ReportListResponse, error) {
+	if query.Limit <= 0 {
+		query.Limit = reportListDefaultLimit
+	}
+	query.Limit = min(query.Limit, reportListMaxLimit)
+
+	switch query.SortBy {
+	case "":
+		query.SortBy = models.ReportSortCreatedAt
+	case models.ReportSortCreatedAt, models.ReportSortTitle:
+	default:
+		return nil, fmt.Errorf("%w: 不支持的排序字段 %s", ErrInvalidListQuery, query.SortBy)
+	}
+	if query.From != nil && query.To != nil && !query.From.Before(*query.To) {
+		return nil, fmt.Errorf("%w: 开始时间必须早于结束时间", ErrInvalidListQuery)
+	}
+	if query.AuthoredFrom != nil && query.AuthoredTo != nil && !query.AuthoredFrom.Before(*query.AuthoredTo) {
+		return nil, fmt.Errorf("%w: PDF创建时间的开始时间必须早于结束时间", ErrInvalidListQuery)
+	}
+
+	var after *models.ReportListCursor
+	if query.Cursor != "" {
+		cursor, err := decodeListCursor(query.Cursor, query)
+		if err != nil {
+			return nil, err
+		}
+		after = cursor
+	}
+
+	// 多取一条用于判断是否还有下一页
+	pageQuery := query
+	pageQuery.Limit = query.Limit + 1
+	reports, err := s.reportRepo.ListByUserID(ctx, userID, pageQuery, after)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.reportRepo.CountByUserID(ctx, userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ReportListResponse{Reports: reports, Total: total}
+	if len(reports) > query.Limit {
+		response.Reports = reports[:query.Limit]
+		response.NextCursor = encodeListCursor(response.Reports[query.Limit-1], query)
+	}
+	return response, nil
+}
+
+// encodeListCursor 根据本页最后一条记录生成下一页的游标，游标中记录排序字段和方向
+func encodeListCursor(last models.ReportListItem, query models.ReportListQuery) string {
+	cursor := models.ReportListCursor{SortBy: query.SortBy, Desc: query.Desc, ID: last.ReportID, SortValue: last.Title}
+	if query.SortBy == models.ReportSortCreatedAt {
+		cursor.SortValue = last.CreatedAt.Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor 解析分页游标，游标的排序字段或方向与本次查询不同时返回ErrInvalidListQuery
+func decodeListCursor(encoded string, query models.ReportListQuery) (*models.ReportListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的分页游标", ErrInvalidListQuery)
+	}
+	var cursor models.ReportListCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, fmt.Errorf("%w: 无效的分页游标", ErrInvalidListQuery)
+	}
+	if cursor.SortBy != query.SortBy || cursor.Desc != query.Desc {
+		return nil, fmt.Errorf("%w: 分页游标与排序方式不匹配", ErrInvalidListQuery)
+	}
+	if query.SortBy == models.ReportSortCreatedAt {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.SortValue); err != nil {
+			return nil, fmt.Errorf("%w: 无效的分页游标", ErrInvalidListQuery)
+		}
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// listReportRepo 在内存中按与SQL相同的 (排序字段, id) keyset 条件分页
+type listReportRepo struct {
+	repository.IReportRepository
+	reports []models.ReportListItem
+}
+
+func (r *listReportRepo) ListByUserID(_ context.Context, _ string, query models.ReportListQuery, after *models.ReportListCursor) ([]models.ReportListItem, error) {
+	// compare 返回a相对b在排序字段、id上的先后
+	compare := func(aValue, aID, bValue, bID string) int {
+		c := 0
+		switch {
+		case aValue < bValue:
+			c = -1
+		case aValue > bValue:
+			c = 1
+		case aID < bID:
+			c = -1
+		case aID > bID:
+			c = 1
+		}
+		if query.Desc {
+			c = -c
+		}
+		return c
+	}
+	sortValue := func(item models.ReportListItem) string {
+		if query.SortBy == models.ReportSortTitle {
+			return item.Title
+		}
+		return item.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	sorted := append([]models.ReportListItem(nil), r.reports...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return compare(sortValue(sorted[i]), sorted[i].ReportID, sortValue(sorted[j]), sorted[j].ReportID) < 0
+	})
+	page := []models.ReportListItem{}
+	for _, item := range sorted {
+		if after != nil {
+			afterValue := after.SortValue
+			if query.SortBy == models.ReportSortCreatedAt {
+				t, err := time.Parse(time.RFC3339Nano, after.SortValue)
+				if err != nil {
+					return nil, err
+				}
+				afterValue = t.UTC().Format(time.RFC3339Nano)
+			}
+			if compare(sortValue(item), item.ReportID, afterValue, after.ID) <= 0 {
+				continue
+			}
+		}
+		if len(page) == query.Limit {
+			break
+		}
+		page = append(page, item)
+	}
+	return page, nil
+}
+
+func (r *listReportRepo) CountByUserID(context.Context, string, models.ReportListQuery) (int, error) {
+	return len(r.reports), nil
+}
+
+func TestReportService_ListReportsPagesThroughTies(t *testing.T) {
+	ctx := context.Background()
+	// 大部分报告的创建时间和标题相同，只能靠id区分先后
+	base := time.Date(2025, 5, 12, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	repo := &listReportRepo{}
+	for i := 0; i < 23; i++ {
+		createdAt, title := base, "季度报告"
+		if i%5 == 0 {
+			createdAt, title = base.Add(time.Duration(i)*time.Second), fmt.Sprintf("报告%02d", i)
+		}
+		repo.reports = append(repo.reports, models.ReportListItem{ReportID: fmt.Sprintf("r%02d", i), Title: title, CreatedAt: createdAt})
+	}
+	s := NewReportService(repo, nil, nil, nil, nil, nil, "")
+
+	for _, sortBy := range []string{models.ReportSortCreatedAt, models.ReportSortTitle} {
+		for _, desc := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s desc=%v", sortBy, desc), func(t *testing.T) {
+				query := models.ReportListQuery{Limit: 4, SortBy: sortBy, Desc: desc}
+				seen := make(map[string]int)
+				for pages := 0; ; pages++ {
+					require.Less(t, pages, len(repo.reports), "分页没有结束")
+					response, err := s.ListReports(ctx, "u1", query)
+					require.NoError(t, err)
+					for _, item := range response.Reports {
+						seen[item.ReportID]++
+					}
+					if response.NextCursor == "" {
+						break
+					}
+					query.Cursor = response.NextCursor
+				}
+				// 每条报告恰好出现一次
+				assert.Len(t, seen, len(repo.reports))
+				for id, count := range seen {
+					assert.Equal(t, 1, count, "报告%s出现了%d次", id, count)
+				}
+			})
+		}
+	}
+}
+
+func TestReportService_ListReportsRejectsCursorForOtherSort(t *testing.T) {
+	ctx := context.Background()
+	repo := &listReportRepo{}
+	for i := 0; i < 3; i++ {
+		repo.reports = append(repo.reports, models.ReportListItem{ReportID: fmt.Sprintf("r%d", i), Title: fmt.Sprintf("报告%d", i), CreatedAt: time.Now()})
+	}
+	s := NewReportService(repo, nil, nil, nil, nil, nil, "")
+
+	response, err := s.ListReports(ctx, "u1", models.ReportListQuery{Limit: 1, SortBy: models.ReportSortCreatedAt, Desc: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, response.NextCursor)
+
+	// 游标只能用于生成它的排序字段和方向
+	_, err = s.ListReports(ctx, "u1", models.ReportListQuery{Limit: 1, Cursor: response.NextCursor, SortBy: models.ReportSortTitle, Desc: true})
+	assert.ErrorIs(t, err, ErrInvalidListQuery)
+	_, err = s.ListReports(ctx, "u1", models.ReportListQuery{Limit: 1, Cursor: response.NextCursor, SortBy: models.ReportSortCreatedAt})
+	assert.ErrorIs(t, err, ErrInvalidListQuery)
+	_, err = s.ListReports(ctx, "u1", models.ReportListQuery{Limit: 1, Cursor: response.NextCursor, SortBy: models.ReportSortCreatedAt, Desc: true})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+func TestToReportPages_TruncatesError(t *testing.T) {
+	long := strings.Repeat("页面解析失败", 300)
+	pages := toReportPages("r1", []utils.PageText{
+		{Number: 1, Text: "正文"},
+		{Number: 2, Err: errors.New("字体缺失")},
+		{Number: 3, Err: errors.New(long)},
+	})
+
+	assert.Empty(t, pages[0].Error)
+	assert.Equal(t, "字体缺失", pages[1].Error)
+	assert.Equal(t, maxPageErrorLength, utf8.RuneCountInString(pages[2].Error))
+	assert.True(t, strings.HasSuffix(pages[2].Error, "…"))
+	assert.True(t, strings.HasPrefix(long, strings.TrimSuffix(pages[2].Error, "…")))
+}
@@ -1,43 +1,76 @@
 package services
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
-	"net/url"
+	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/minio/minio-go/v7"
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/llm"
 	"github.com/qujing226/pdf-enhancer/backend/models"
 	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
 	"github.com/qujing226/pdf-enhancer/backend/utils"
 )
 
 // ReportService 报告服务
 type ReportService struct {
-	reportRepo     repository.IReportRepository
-	MinioClient    *minio.Client
-	DeepSeekClient *DeepSeekClient
-	BucketName     string
+	reportRepo   repository.IReportRepository
+	pageRepo     repository.IReportPageRepository
+	blobRepo     repository.IBlobRepository
+	metadataRepo repository.IReportMetadataRepository
+	Store        storage.BlobStore
+	LLM          *LLMClient
+	BucketName   string
+	// TrashRetention 报告在回收站中的保留时长，超过后被彻底删除；为0时使用默认的30天
+	TrashRetention time.Duration
+	// PDFLimits 上传PDF的页数、对象数和解压大小限制
+	PDFLimits utils.PDFLimits
+	// Keys 加密PDF、报告内容和摘要使用的密钥服务，为nil时不加密
+	Keys *KeyService
+	// Policy 报告授权策略，为nil时只有所有者可以访问报告
+	Policy *authz.ReportPolicy
+	// PDFLinks 报告详情中PDF下载链接的生成方式和有效期
+	PDFLinks PDFLinkConfig
+	// Quotas 大模型配额，为nil时不限制
+	Quotas *QuotaService
 }
 
 // NewReportService 创建新的报告服务
-func NewReportService(reportRepo repository.IReportRepository, minioClient *minio.Client, deepseekClient *DeepSeekClient, bucketName string) *ReportService {
+func NewReportService(reportRepo repository.IReportRepository, pageRepo repository.IReportPageRepository, blobRepo repository.IBlobRepository, metadataRepo repository.IReportMetadataRepository, store storage.BlobStore, llmClient *LLMClient, bucketName string) *ReportService {
 	return &ReportService{
-		reportRepo:     reportRepo,
-		MinioClient:    minioClient,
-		DeepSeekClient: deepseekClient,
-		BucketName:     bucketName,
+		reportRepo:   reportRepo,
+		pageRepo:     pageRepo,
+		blobRepo:     blobRepo,
+		metadataRepo: metadataRepo,
+		Store:        store,
+		LLM:          llmClient,
+		BucketName:   bucketName,
 	}
 }
 
+// UploadOptions 上传报告的可选参数
+type UploadOptions struct {
+	// Password 加密PDF的打开密码，为空时设置了打开密码的PDF以锁定状态保存，之后可通过解锁接口提供密码
+	Password string
+	// StoreDecrypted 为true时保存解密后的副本而不是原始的加密文件
+	StoreDecrypted bool
+}
+
 // CreateReportFromUpload 从上传的文件创建报告
-func (s *ReportService) CreateReportFromUpload(ctx context.Context, userID string, title string, fileHeader *multipart.FileHeader) (*models.Report, error) {
+// PDF按内容的SHA-256存储，相同内容只保存一份并复用已提取的文本
+// 当前用户已有相同内容的报告时，返回结果标明为重复上传并给出已有报告的ID
+func (s *ReportService) CreateReportFromUpload(ctx context.Context, userID string, title string, fileHeader *multipart.FileHeader, opts UploadOptions) (*models.UploadReportResponse, error) {
 	// 打开上传的文件
 	file, err := fileHeader.Open()
 	if err != nil {
@@ -45,121 +78,473 @@ func (s *ReportService) CreateReportFromUpload(ctx context.Context, userID strin
 	}
 	defer file.Close()
 
-	// 提取PDF文本内容
-	// 由于 ParsePDFText 需要 io.Reader，而 file 已经是 io.Reader，可以直接传递
-	// 但为了确保文件可以被多次读取（例如，一次用于解析，一次用于上传），我们先读取到内存
-	body, err := io.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+	// 上传文件支持随机访问（较大的文件由multipart写入临时文件），计算哈希、上传和解析都直接读取文件，不在内存中缓存整个文件
+	size := fileHeader.Size
+
+	// 校验文件确实是PDF且没有超出限制，不合法的文件不会写入对象存储；校验时一并提取文档元数据
+	encryptionStatus := models.EncryptionNone
+	pdfMetadata, err := utils.InspectPDF(file, size, s.PDFLimits, opts.Password)
+	if utils.IsPDFLocked(err) {
+		// 没有密码时无法解析文档结构和元数据，只保存文件，解锁时再提取
+		encryptionStatus = models.EncryptionLocked
+		pdfMetadata = &utils.PDFMetadata{Encrypted: true}
+	} else if err != nil {
+		return nil, err
+	} else if pdfMetadata.Encrypted && opts.Password != "" {
+		encryptionStatus = models.EncryptionUnlocked
 	}
 
-	// 重置文件读取器以便 ParsePDFText 可以从头读取
-	fileReaderForParse := bytes.NewReader(body)
-	textContent, err := utils.ParsePDFText(fileReaderForParse)
+	// 加密文件解密后提取文本；要求保存解密副本时上传的也是解密后的文件
+	var source io.ReaderAt = file
+	sourceSize := size
+	if pdfMetadata.Encrypted && encryptionStatus != models.EncryptionLocked {
+		decrypted, decryptedSize, err := s.decryptPDF(file, size, opts.Password)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(decrypted.Name())
+		defer decrypted.Close()
+		if opts.StoreDecrypted {
+			source, sourceSize = decrypted, decryptedSize
+		}
+		// 加密文件不复用其他报告的文本，避免没有密码的用户读到内容
+		return s.createReport(ctx, userID, title, source, sourceSize, encryptionStatus, pdfMetadata, func(reportID, _ string) []models.ReportPage {
+			return parseReportPages(reportID, decrypted, decryptedSize)
+		})
+	}
+	if encryptionStatus == models.EncryptionLocked {
+		return s.createReport(ctx, userID, title, source, sourceSize, encryptionStatus, pdfMetadata, func(string, string) []models.ReportPage {
+			return nil
+		})
+	}
+	return s.createReport(ctx, userID, title, source, sourceSize, encryptionStatus, pdfMetadata, func(reportID, contentHash string) []models.ReportPage {
+		return s.extractPages(ctx, reportID, contentHash, file, size)
+	})
+}
+
+// createReport 保存PDF对象并创建报告，extract负责提取新报告的分页文本
+func (s *ReportService) createReport(ctx context.Context, userID string, title string, file io.ReaderAt, size int64,
+	encryptionStatus string, pdfMetadata *utils.PDFMetadata, extract func(reportID, contentHash string) []models.ReportPage) (*models.UploadReportResponse, error) {
+	contentHash, err := hashContent(file, size)
 	if err != nil {
-		log.Printf("解析PDF文本内容失败: %v", err) // 记录错误，但可能仍希望保存文件
-		// 根据需求决定是否在此处返回错误，或者允许没有文本内容的报告
+		return nil, err
 	}
+	contentHash = s.blobHash(userID, contentHash)
 
-	// 生成报告ID和PDF文件名
-	reportID := uuid.New().String()
-	pdfObjectName := fmt.Sprintf("%s.pdf", reportID)
+	result := &models.UploadReportResponse{}
+	existing, err := s.reportRepo.GetByContentHash(ctx, userID, contentHash)
+	if err == nil {
+		result.Duplicate = true
+		result.ExistingReportID = existing.ID
+	} else if !errors.Is(err, repository.ErrReportNotFound) {
+		log.Printf("查询重复报告失败: %v", err)
+	}
 
-	// 上传PDF到MinIO
-	// 重置文件读取器以便上传
-	fileReaderForUpload := bytes.NewReader(body)
-	_, err = s.MinioClient.PutObject(ctx, s.BucketName, pdfObjectName, fileReaderForUpload, int64(len(body)), minio.PutObjectOptions{
-		ContentType: fileHeader.Header.Get("Content-Type"), // "application/pdf"
-	})
+	pdfObjectName, err := s.storeBlob(ctx, userID, contentHash, file, size)
 	if err != nil {
-		return nil, fmt.Errorf("上传PDF到MinIO失败: %w", err)
+		return nil, err
 	}
 
+	// 生成报告ID，复用或提取文本
+	reportID := uuid.New().String()
+	pages := extract(reportID, contentHash)
+
 	// 创建报告模型
 	report := &models.Report{
-		ID:        reportID,
-		UserID:    userID,
-		Title:     title,
-		Content:   textContent, // 保存提取的文本内容
-		Summary:   "",          // 初始摘要为空
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		PDFPath:   filepath.Join(s.BucketName, pdfObjectName), // MinIO中的对象路径
+		ID:               reportID,
+		UserID:           userID,
+		Title:            title,
+		Content:          joinReportPages(pages), // 保存提取的文本内容
+		Summary:          "",                     // 初始摘要为空
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		PDFPath:          filepath.Join(s.BucketName, pdfObjectName), // 对象存储中的对象路径
+		ContentHash:      contentHash,
+		EncryptionStatus: encryptionStatus,
 	}
 
-	// 将报告保存到数据库
-	err = s.reportRepo.Create(ctx, report)
+	// 将报告保存到数据库，内容加密后保存
+	stored, err := s.encryptReport(ctx, report)
+	if err == nil {
+		err = s.reportRepo.Create(ctx, stored)
+	}
 	if err != nil {
-		// 如果数据库插入失败，可能需要考虑删除已上传到MinIO的文件以保持一致性
-		// s.MinioClient.RemoveObject(ctx, s.BucketName, pdfObjectName, minio.RemoveObjectOptions{})
+		// 数据库插入失败时释放引用，没有其他报告引用时删除已上传的文件，避免留下孤立对象
+		s.releaseBlob(ctx, contentHash)
 		return nil, err
 	}
 
-	return report, nil
+	// 保存分页文本，失败时页面接口和问答会退回到按分隔符拆分报告内容
+	if err := s.savePages(ctx, userID, reportID, pages); err != nil {
+		log.Printf("保存报告%s的分页文本失败: %v", reportID, err)
+	}
+
+	// 保存PDF元数据，失败时只影响元数据展示和过滤
+	if err := s.metadataRepo.Save(ctx, toReportMetadata(reportID, pdfMetadata)); err != nil {
+		log.Printf("保存报告%s的PDF元数据失败: %v", reportID, err)
+	}
+
+	result.Report = *report
+	return result, nil
 }
 
-// GetReportsByUserID 根据用户ID获取报告列表
-func (s *ReportService) GetReportsByUserID(userID string) ([]models.ReportListItem, error) {
-	return s.reportRepo.GetByUserID(context.Background(), userID)
+// hashContent 计算文件内容的SHA-256
+func hashContent(file io.ReaderAt, size int64) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, io.NewSectionReader(file, 0, size)); err != nil {
+		return "", fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-// GetReportByID 根据报告ID和用户ID获取报告详情
-func (s *ReportService) GetReportByID(reportID string, userID string) (*models.Report, error) {
-	return s.reportRepo.GetByID(context.Background(), reportID, userID)
+// storeBlob 增加PDF对象的引用并确保对象已上传，返回对象名
+// 先加引用再检查对象，避免与释放最后一个引用的删除操作交错
+func (s *ReportService) storeBlob(ctx context.Context, userID string, contentHash string, file io.ReaderAt, size int64) (string, error) {
+	blob := &models.Blob{Hash: contentHash, ObjectKey: fmt.Sprintf("%s.pdf", contentHash), Size: size}
+	if err := s.blobRepo.Acquire(ctx, blob); err != nil {
+		return "", err
+	}
+	// 已有的对象可能在密钥轮换时换了键，以记录中的键为准
+	if err := s.ensureObject(ctx, userID, blob.ObjectKey, file, size, "application/pdf"); err != nil {
+		s.releaseBlob(ctx, contentHash)
+		return "", err
+	}
+	return blob.ObjectKey, nil
+}
+
+// ensureObject 对象不存在时上传，已存在时直接复用；启用加密时以用户的数据密钥加密后上传
+func (s *ReportService) ensureObject(ctx context.Context, userID string, key string, file io.ReaderAt, size int64, contentType string) error {
+	_, err := s.Store.Stat(ctx, key)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("检查PDF是否已存在失败: %w", err)
+	}
+	reader, storedSize, err := s.Keys.EncryptObject(ctx, userID, file, size)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Store.Put(ctx, key, reader, storedSize, contentType); err != nil {
+		return fmt.Errorf("上传PDF失败: %w", err)
+	}
+	return nil
+}
+
+// releaseBlob 释放报告对PDF对象的引用，最后一个引用释放时删除对象；请求被取消时也要完成清理
+func (s *ReportService) releaseBlob(ctx context.Context, contentHash string) {
+	ctx = context.WithoutCancel(ctx)
+	err := s.blobRepo.Release(ctx, contentHash, func(objectKey string) error {
+		return s.Store.Remove(ctx, objectKey)
+	})
+	if err != nil {
+		log.Printf("释放PDF对象 %s 的引用失败: %v", contentHash, err)
+	}
 }
 
-// GeneratePDFURL 生成PDF的预签名URL
-func (s *ReportService) GeneratePDFURL(reportID string) (string, error) {
-	// 假设PDFPath存储的是 bucketName/objectName
-	report, err := s.GetReportByID(reportID, "") // 注意：这里可能需要正确的userID，或者修改GetReportByID的逻辑
+// extractPages 优先复用相同内容的报告已提取的分页文本，没有可复用的结果时解析PDF
+func (s *ReportService) extractPages(ctx context.Context, reportID string, contentHash string, file io.ReaderAt, size int64) []models.ReportPage {
+	source, err := s.reportRepo.GetContentByHash(ctx, contentHash)
+	if err == nil {
+		pages, err := s.pageRepo.GetPages(ctx, source.ID)
+		if err == nil {
+			err = s.decryptPages(ctx, pages)
+		}
+		if err == nil && len(pages) > 0 {
+			now := time.Now()
+			for i := range pages {
+				pages[i].ReportID = reportID
+				pages[i].CreatedAt = now
+			}
+			return pages
+		}
+	}
+
+	return parseReportPages(reportID, file, size)
+}
+
+// parseReportPages 解析PDF并逐页提取文本，文档无法解析时返回空结果
+func parseReportPages(reportID string, file io.ReaderAt, size int64) []models.ReportPage {
+	parsed, err := utils.ParsePDFPagesAt(file, size)
 	if err != nil {
-		return "", fmt.Errorf("获取报告信息失败: %w", err)
+		log.Printf("解析PDF文本内容失败: %v", err) // 记录错误，但仍保存文件，允许没有文本内容的报告
+	}
+	return toReportPages(reportID, parsed)
+}
+
+// joinReportPages 将页面文本以 utils.PageSeparator 拼接，与 utils.JoinPageText 的格式一致
+func joinReportPages(pages []models.ReportPage) string {
+	texts := make([]utils.PageText, 0, len(pages))
+	for _, page := range pages {
+		pageText := utils.PageText{Number: page.PageNumber, Text: page.Content}
+		if page.Error != "" {
+			pageText.Err = errors.New(page.Error)
+		}
+		texts = append(texts, pageText)
+	}
+	return utils.JoinPageText(texts)
+}
+
+// toReportMetadata 将提取的PDF元数据转换为报告元数据模型
+func toReportMetadata(reportID string, metadata *utils.PDFMetadata) *models.ReportMetadata {
+	pageSizes := make([]models.PageSize, 0, len(metadata.PageSizes))
+	for _, size := range metadata.PageSizes {
+		pageSizes = append(pageSizes, models.PageSize{Width: size.Width, Height: size.Height})
 	}
+	return &models.ReportMetadata{
+		ReportID:     reportID,
+		Title:        metadata.Title,
+		Author:       metadata.Author,
+		Subject:      metadata.Subject,
+		Keywords:     metadata.Keywords,
+		Creator:      metadata.Creator,
+		Producer:     metadata.Producer,
+		CreationDate: metadata.CreationDate,
+		ModDate:      metadata.ModDate,
+		PageCount:    metadata.PageCount,
+		PageSizes:    pageSizes,
+		PDFVersion:   metadata.Version,
+		Encrypted:    metadata.Encrypted,
+	}
+}
+
+// GetReportMetadata 获取报告的PDF元数据，没有元数据时返回nil
+func (s *ReportService) GetReportMetadata(ctx context.Context, reportID string) (*models.ReportMetadata, error) {
+	metadata, err := s.metadataRepo.GetByReportID(ctx, reportID)
+	if errors.Is(err, repository.ErrMetadataNotFound) {
+		return nil, nil
+	}
+	return metadata, err
+}
+
+// maxPageErrorLength 页面错误原因的最大字符数，与report_pages.error列的长度一致
+const maxPageErrorLength = 1024
+
+// toReportPages 将提取结果转换为页面模型，提取失败的页面记录错误原因，过长的原因被截断
+func toReportPages(reportID string, pages []utils.PageText) []models.ReportPage {
+	now := time.Now()
+	reportPages := make([]models.ReportPage, 0, len(pages))
+	failed := 0
+	for _, page := range pages {
+		reportPage := models.ReportPage{
+			ReportID:   reportID,
+			PageNumber: page.Number,
+			Content:    page.Text,
+			CreatedAt:  now,
+		}
+		if page.Err != nil {
+			reportPage.Error = truncatePageError(page.Err.Error())
+			failed++
+		}
+		reportPages = append(reportPages, reportPage)
+	}
+	if failed > 0 {
+		log.Printf("报告%s共%d页，其中%d页文本提取失败", reportID, len(pages), failed)
+	}
+	return reportPages
+}
 
-	reqParams := make(url.Values)
-	reqParams["response-content-disposition"] = []string{fmt.Sprintf("attachment; filename=\"%s.pdf\"", report.Title)}
+// truncatePageError 按字符截断错误原因，截断时以省略号结尾
+func truncatePageError(msg string) string {
+	runes := []rune(msg)
+	if len(runes) <= maxPageErrorLength {
+		return msg
+	}
+	return string(runes[:maxPageErrorLength-1]) + "…"
+}
 
-	presignedURL, err := s.MinioClient.PresignedGetObject(context.Background(), s.BucketName, filepath.Base(report.PDFPath), time.Second*24*60*60, reqParams)
+// GetReportPage 获取报告的指定页面，早期上传、没有分页记录的报告按分隔符拆分内容
+func (s *ReportService) GetReportPage(ctx context.Context, report *models.Report, pageNumber int) (*models.ReportPageResponse, error) {
+	total, err := s.pageRepo.CountPages(ctx, report.ID)
 	if err != nil {
-		return "", fmt.Errorf("生成MinIO预签名URL失败: %w", err)
+		return nil, err
+	}
+	if total > 0 {
+		page, err := s.pageRepo.GetPage(ctx, report.ID, pageNumber)
+		if err != nil {
+			return nil, err
+		}
+		if page.Content, err = s.Keys.DecryptString(ctx, page.Content); err != nil {
+			return nil, err
+		}
+		return &models.ReportPageResponse{ReportPage: *page, TotalPages: total}, nil
+	}
+
+	pages := utils.SplitPages(report.Content)
+	if pageNumber < 1 || pageNumber > len(pages) {
+		return nil, repository.ErrPageNotFound
 	}
-	return presignedURL.String(), nil
+	return &models.ReportPageResponse{
+		ReportPage: models.ReportPage{
+			ReportID:   report.ID,
+			PageNumber: pageNumber,
+			Content:    pages[pageNumber-1],
+			CreatedAt:  report.CreatedAt,
+		},
+		TotalPages: len(pages),
+	}, nil
 }
 
-// GetReportPDF 获取报告的PDF文件流和信息
-func (s *ReportService) GetReportPDF(reportID, userID string) (io.ReadCloser, minio.ObjectInfo, error) {
-	report, err := s.GetReportByID(reportID, userID) // 注意：这里可能需要正确的userID
+// reportPageTexts 按页码顺序返回报告各页文本，没有分页记录时按分隔符拆分内容
+func (s *ReportService) reportPageTexts(ctx context.Context, report *models.Report) ([]string, error) {
+	pages, err := s.pageRepo.GetPages(ctx, report.ID)
 	if err != nil {
-		return nil, minio.ObjectInfo{}, fmt.Errorf("获取报告信息失败: %w", err)
+		return nil, err
+	}
+	if err := s.decryptPages(ctx, pages); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return utils.SplitPages(report.Content), nil
 	}
 
-	object, err := s.MinioClient.GetObject(context.Background(), s.BucketName, filepath.Base(report.PDFPath), minio.GetObjectOptions{})
+	texts := make([]string, pages[len(pages)-1].PageNumber)
+	for _, page := range pages {
+		texts[page.PageNumber-1] = page.Content
+	}
+	return texts, nil
+}
+
+// objectKey 从报告的PDF路径（bucketName/objectName）中取出对象键，没有PDF时返回空字符串
+func objectKey(pdfPath string) string {
+	if pdfPath == "" {
+		return ""
+	}
+	return filepath.Base(pdfPath)
+}
+
+// GetReportByID 获取用户有权查看的报告详情
+func (s *ReportService) GetReportByID(reportID string, userID string) (*models.Report, error) {
+	report, _, err := s.AuthorizeReport(context.Background(), reportID, userID, authz.ActionView)
+	return report, err
+}
+
+// GetReportPDF 获取报告的PDF文件流、对象信息和下载文件名
+func (s *ReportService) GetReportPDF(reportID, userID string) (storage.Object, storage.ObjectInfo, string, error) {
+	report, _, err := s.authorizeReport(context.Background(), reportID, userID, authz.ActionView) // 只需要PDF路径和标题，不解密内容
 	if err != nil {
-		return nil, minio.ObjectInfo{}, fmt.Errorf("从MinIO获取对象失败: %w", err)
+		return nil, storage.ObjectInfo{}, "", fmt.Errorf("获取报告信息失败: %w", err)
 	}
 
-	objInfo, err := object.Stat()
+	object, objInfo, err := s.openPDF(context.Background(), report.PDFPath)
 	if err != nil {
-		object.Close() // Ensure the object is closed on error
-		return nil, minio.ObjectInfo{}, fmt.Errorf("获取MinIO对象信息失败: %w", err)
+		return nil, storage.ObjectInfo{}, "", err
 	}
 
-	return object, objInfo, nil
+	return object, objInfo, utils.PDFFilename(report.Title), nil
 }
 
-// GenerateSummary 生成报告摘要
-func (s *ReportService) GenerateSummary(report *models.Report) (string, error) {
+// 预估配额时使用的token数，实际用量在请求结束后结算
+const (
+	quotaPromptOverheadTokens = 200  // 每次调用中提示词模板本身的token数
+	quotaChunkOutputTokens    = 500  // 分块摘要每块输出的token数
+	quotaDefaultOutputTokens  = 1000 // 未配置MaxTokens时每次调用输出的token数
+)
+
+// ReserveSummary 为生成报告摘要预留一次请求和预估的token数，剩余配额不足时返回 *QuotaExceededError；未配置配额时返回nil
+func (s *ReportService) ReserveSummary(ctx context.Context, report *models.Report, userID string) (*QuotaReservation, error) {
 	if report.Content == "" {
-		return "", fmt.Errorf("报告内容为空，无法生成摘要")
+		return nil, fmt.Errorf("报告内容为空，无法生成摘要")
+	}
+	return s.reserveQuota(ctx, userID, s.estimateSummaryTokens(report.Title, report.Content))
+}
+
+// ReleaseQuota 释放未使用的预留配额，r为nil时不做任何事
+func (s *ReportService) ReleaseQuota(ctx context.Context, r *QuotaReservation) {
+	if s.Quotas == nil || r == nil {
+		return
+	}
+	if err := s.Quotas.Settle(ctx, r, 0, false); err != nil {
+		log.Printf("释放用户%s的预留配额失败: %v", r.UserID, err)
+	}
+}
+
+func (s *ReportService) reserveQuota(ctx context.Context, userID string, tokens int64) (*QuotaReservation, error) {
+	if s.Quotas == nil {
+		return nil, nil
+	}
+	return s.Quotas.Reserve(ctx, userID, tokens)
+}
+
+// runAIRequest 在记录用量归属的ctx中执行fn，结束后按实际用量结算预留的配额，fn返回错误时释放占用的请求次数
+// 同一次请求的多次大模型调用共用一个请求ID
+func (s *ReportService) runAIRequest(ctx context.Context, report *models.Report, userID string, reservation *QuotaReservation, fn func(ctx context.Context) error) (err error) {
+	ctx = llm.WithUsageScope(ctx, llm.UsageScope{UserID: userID, ReportID: report.ID, RequestID: uuid.New().String()})
+	ctx, tally := llm.WithUsageTally(ctx)
+	succeeded := false
+	// fn发生panic时同样结算，避免预留的配额一直被占用
+	defer func() {
+		if s.Quotas == nil {
+			return
+		}
+		if settleErr := s.Quotas.Settle(context.WithoutCancel(ctx), reservation, tally.TotalTokens(), succeeded); settleErr != nil {
+			log.Printf("结算用户%s的配额失败: %v", userID, settleErr)
+		}
+	}()
+	err = fn(ctx)
+	succeeded = err == nil
+	return err
+}
+
+// estimateSummaryTokens 按与summarizeContent相同的分块方式预估生成摘要需要的token数
+func (s *ReportService) estimateSummaryTokens(title string, content string) int64 {
+	config := s.LLM.config
+	output := int64(config.MaxTokens)
+	if output <= 0 {
+		output = quotaDefaultOutputTokens
+	}
+	chunks := utils.SplitTextIntoChunks(content, config.ChunkTokens, config.ChunkOverlap)
+	if len(chunks) <= 1 {
+		return int64(utils.EstimateTokens(title)+utils.EstimateTokens(content)) + quotaPromptOverheadTokens + output
+	}
+
+	// 每块一次调用，最后将各块的部分摘要合并为一次调用
+	var tokens int64
+	for _, chunk := range chunks {
+		tokens += int64(utils.EstimateTokens(title)+utils.EstimateTokens(chunk)) + quotaPromptOverheadTokens + quotaChunkOutputTokens
+	}
+	combineInput := int64(len(chunks)) * quotaChunkOutputTokens
+	return tokens + combineInput + quotaPromptOverheadTokens + output
+}
+
+// prepareSummary 校验摘要选项和报告内容，reservation为nil时为本次生成预留配额；校验失败时释放传入的预留
+func (s *ReportService) prepareSummary(ctx context.Context, report *models.Report, userID string, opts models.SummaryOptions, reservation *QuotaReservation) (models.SummaryOptions, *QuotaReservation, error) {
+	opts, err := NormalizeSummaryOptions(opts)
+	if err == nil && report.Content == "" {
+		err = fmt.Errorf("报告内容为空，无法生成摘要")
+	}
+	if err != nil {
+		s.ReleaseQuota(ctx, reservation)
+		return opts, nil, err
+	}
+	if reservation == nil {
+		reservation, err = s.ReserveSummary(ctx, report, userID)
+	}
+	return opts, reservation, err
+}
+
+// summaryMapConcurrency 长报告分块摘要时的最大并发请求数
+const summaryMapConcurrency = 4
+
+// GenerateSummary 按摘要选项生成报告摘要，大模型用量记在userID名下
+// reservation为已通过ReserveSummary预留的配额，为nil时在生成前预留；无论成功与否预留都会被结算
+func (s *ReportService) GenerateSummary(ctx context.Context, report *models.Report, userID string, opts models.SummaryOptions, reservation *QuotaReservation) (string, error) {
+	opts, reservation, err := s.prepareSummary(ctx, report, userID, opts, reservation)
+	if err != nil {
+		return "", err
 	}
 
-	summary, err := s.DeepSeekClient.GenerateSummary(context.Background(), fmt.Sprintf("Title: %s\nContent:%s", report.Title, report.Content))
+	var summary string
+	err = s.runAIRequest(ctx, report, userID, reservation, func(ctx context.Context) error {
+		summary, err = s.summarizeContent(ctx, opts, report.Title, report.Content)
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("调用DeepSeek API生成摘要失败: %w", err)
+		return "", fmt.Errorf("调用大模型生成摘要失败: %w", err)
 	}
 
 	// 更新数据库中的摘要
-	err = s.reportRepo.UpdateSummary(context.Background(), report.ID, summary)
+	err = s.saveSummary(ctx, report, summary, opts)
 	if err != nil {
 		return "", err
 	}
@@ -167,15 +552,177 @@ func (s *ReportService) GenerateSummary(report *models.Report) (string, error) {
 	return summary, nil
 }
 
-// Helper function to read file into a byte slice and return a new reader
-// This is useful if you need to read the file multiple times (e.g., for parsing and uploading)
-func getReusableReader(file multipart.File) (io.Reader, []byte, error) {
-	body, err := io.ReadAll(file)
+// summarizeContent 生成摘要，内容超过单次请求上限时按 map-reduce 方式分块摘要再合并
+func (s *ReportService) summarizeContent(ctx context.Context, opts models.SummaryOptions, title string, content string) (string, error) {
+	config := s.LLM.config
+	chunks := utils.SplitTextIntoChunks(content, config.ChunkTokens, config.ChunkOverlap)
+	if len(chunks) <= 1 {
+		return s.LLM.GenerateSummary(ctx, opts, fmt.Sprintf("Title: %s\nContent:%s", title, content))
+	}
+
+	partials, err := s.summarizeChunks(ctx, opts, title, chunks, nil)
 	if err != nil {
-		return nil, nil, err
+		return "", err
+	}
+	partials, err = s.reducePartials(ctx, opts, title, partials)
+	if err != nil {
+		return "", err
 	}
-	return bytes.NewReader(body), body, nil
+	return s.LLM.CombineSummaries(ctx, opts, title, partials)
 }
 
-// bytes.NewReader is needed for ParsePDFText and PutObject if we read the file into memory first
-// Ensure to import "bytes"
+// SummaryStreamCallbacks 流式摘要的回调函数
+type SummaryStreamCallbacks struct {
+	// OnProgress 长报告分块摘要时每完成一块调用一次，可为nil
+	OnProgress func(done, total int)
+	// OnDelta 每收到一段摘要文本调用一次，返回错误时中止生成
+	OnDelta func(delta string) error
+}
+
+// StreamSummary 以流式方式生成报告摘要，完成后保存到数据库；reservation的含义与GenerateSummary相同
+// 长报告的分块摘要阶段不输出文本，只通过OnProgress报告进度，最终合并阶段流式输出
+func (s *ReportService) StreamSummary(ctx context.Context, report *models.Report, userID string, opts models.SummaryOptions, reservation *QuotaReservation, callbacks SummaryStreamCallbacks) (string, error) {
+	opts, reservation, err := s.prepareSummary(ctx, report, userID, opts, reservation)
+	if err != nil {
+		return "", err
+	}
+
+	var summary string
+	err = s.runAIRequest(ctx, report, userID, reservation, func(ctx context.Context) error {
+		summary, err = s.streamSummaryContent(ctx, opts, report.Title, report.Content, callbacks)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("调用大模型生成摘要失败: %w", err)
+	}
+
+	// 流已完整输出，即使客户端随后断开也保存摘要
+	if err := s.saveSummary(context.WithoutCancel(ctx), report, summary, opts); err != nil {
+		return "", err
+	}
+	return summary, nil
+}
+
+func (s *ReportService) streamSummaryContent(ctx context.Context, opts models.SummaryOptions, title string, content string, callbacks SummaryStreamCallbacks) (string, error) {
+	config := s.LLM.config
+	chunks := utils.SplitTextIntoChunks(content, config.ChunkTokens, config.ChunkOverlap)
+	if len(chunks) <= 1 {
+		return s.LLM.GenerateSummaryStream(ctx, opts, fmt.Sprintf("Title: %s\nContent:%s", title, content), callbacks.OnDelta)
+	}
+
+	partials, err := s.summarizeChunks(ctx, opts, title, chunks, callbacks.OnProgress)
+	if err != nil {
+		return "", err
+	}
+	partials, err = s.reducePartials(ctx, opts, title, partials)
+	if err != nil {
+		return "", err
+	}
+	return s.LLM.CombineSummariesStream(ctx, opts, title, partials, callbacks.OnDelta)
+}
+
+// summarizeChunks 并发地为每个块生成部分摘要，任意一块失败即取消其余请求
+func (s *ReportService) summarizeChunks(ctx context.Context, opts models.SummaryOptions, title string, chunks []string, onProgress func(done, total int)) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	partials := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, summaryMapConcurrency)
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+	done := 0
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			partial, err := s.LLM.SummarizeChunk(ctx, opts, title, chunk, i+1, len(chunks))
+			if err != nil {
+				errs[i] = fmt.Errorf("第%d部分摘要失败: %w", i+1, err)
+				cancel()
+				return
+			}
+			partials[i] = partial
+
+			if onProgress != nil {
+				progressMu.Lock()
+				done++
+				onProgress(done, len(chunks))
+				progressMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return partials, nil
+}
+
+// reducePartials 部分摘要总长仍超过上限时分组逐层合并，直到可以在一次请求中完成最终合并
+// 每条部分摘要先截断到上限的一半，任意两条都能放进同一组，每一轮至少减少一条，单条超长的摘要也不会原样进入最终合并
+func (s *ReportService) reducePartials(ctx context.Context, opts models.SummaryOptions, title string, partials []string) ([]string, error) {
+	limit := s.LLM.config.ChunkTokens
+	if limit <= 0 {
+		return partials, nil
+	}
+	partLimit := max(limit/2, 1)
+	for {
+		for i, partial := range partials {
+			if truncated := utils.TruncateToTokens(partial, partLimit); len(truncated) < len(partial) {
+				log.Printf("部分摘要超过%d个token，已截断", partLimit)
+				partials[i] = truncated
+			}
+		}
+		groups := groupByTokens(partials, limit)
+		if len(groups) == 1 {
+			return partials, nil
+		}
+
+		next := make([]string, 0, len(groups))
+		for _, group := range groups {
+			if len(group) == 1 {
+				next = append(next, group[0])
+				continue
+			}
+			combined, err := s.LLM.MergeSummaries(ctx, opts, title, group)
+			if err != nil {
+				return nil, fmt.Errorf("合并部分摘要失败: %w", err)
+			}
+			next = append(next, combined)
+		}
+		partials = next
+	}
+}
+
+// groupByTokens 按token上限将文本分组，每组至少包含一条
+func groupByTokens(texts []string, limit int) [][]string {
+	if limit <= 0 {
+		return [][]string{texts}
+	}
+	var groups [][]string
+	var current []string
+	currentTokens := 0
+	for _, text := range texts {
+		tokens := utils.EstimateTokens(text)
+		if len(current) > 0 && currentTokens+tokens > limit {
+			groups = append(groups, current)
+			current, currentTokens = nil, 0
+		}
+		current = append(current, text)
+		currentTokens += tokens
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
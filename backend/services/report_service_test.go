@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// failingPutStore 上传总是失败的存储，用于检查失败时引用计数的回滚
+type failingPutStore struct {
+	*storage.MemoryStore
+}
+
+func (failingPutStore) Put(context.Context, string, io.Reader, int64, string) (storage.ObjectInfo, error) {
+	return storage.ObjectInfo{}, errors.New("存储不可用")
+}
+
+func TestReportService_StoreBlobRefCount(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	blobRepo := &fakeBlobRepo{blobs: make(map[string]*models.Blob)}
+	s := NewReportService(nil, nil, blobRepo, nil, store, nil, "")
+	pdf := []byte("%PDF-1.4 dedup")
+
+	// 相同内容重复上传只增加引用，对象只保存一份
+	key, err := s.storeBlob(ctx, "u1", "h1", bytes.NewReader(pdf), int64(len(pdf)))
+	require.NoError(t, err)
+	assert.Equal(t, "h1.pdf", key)
+	again, err := s.storeBlob(ctx, "u1", "h1", bytes.NewReader(pdf), int64(len(pdf)))
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+	assert.Equal(t, 2, blobRepo.blobs["h1"].RefCount)
+	objects, err := store.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	// 只有最后一个引用释放时才删除对象
+	s.releaseBlob(ctx, "h1")
+	assert.Equal(t, 1, blobRepo.blobs["h1"].RefCount)
+	_, err = store.Stat(ctx, key)
+	require.NoError(t, err)
+	s.releaseBlob(ctx, "h1")
+	assert.NotContains(t, blobRepo.blobs, "h1")
+	_, err = store.Stat(ctx, key)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestReportService_StoreBlobRollsBackOnUploadFailure(t *testing.T) {
+	ctx := context.Background()
+	blobRepo := &fakeBlobRepo{blobs: make(map[string]*models.Blob)}
+	s := NewReportService(nil, nil, blobRepo, nil, failingPutStore{storage.NewMemoryStore()}, nil, "")
+	pdf := []byte("%PDF-1.4 upload fails")
+
+	// 新对象上传失败时撤销刚加的引用
+	_, err := s.storeBlob(ctx, "u1", "h1", bytes.NewReader(pdf), int64(len(pdf)))
+	require.Error(t, err)
+	assert.NotContains(t, blobRepo.blobs, "h1")
+
+	// 已有引用但对象缺失时补传失败，引用计数恢复原值
+	blobRepo.blobs["h2"] = &models.Blob{Hash: "h2", ObjectKey: "h2.pdf", RefCount: 1}
+	_, err = s.storeBlob(ctx, "u1", "h2", bytes.NewReader(pdf), int64(len(pdf)))
+	require.Error(t, err)
+	assert.Equal(t, 1, blobRepo.blobs["h2"].RefCount)
+}
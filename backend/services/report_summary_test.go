@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+func TestReportService_ReducePartials(t *testing.T) {
+	merges := 0
+	provider := llm.NewFakeProvider()
+	provider.Reply = func(llm.ChatRequest) (string, error) {
+		merges++
+		return strings.Repeat("合", 30), nil
+	}
+	s := NewReportService(nil, nil, nil, nil, nil, NewLLMClient(provider, LLMConfig{ChunkTokens: 100}), "")
+	ctx := context.Background()
+	opts := models.SummaryOptions{}
+
+	total := func(partials []string) int {
+		tokens := 0
+		for _, partial := range partials {
+			tokens += utils.EstimateTokens(partial)
+		}
+		return tokens
+	}
+
+	// 单条超过上限的部分摘要被截断，不会原样进入最终合并
+	partials, err := s.reducePartials(ctx, opts, "报告", []string{strings.Repeat("长", 300)})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, total(partials), 100)
+	assert.Zero(t, merges)
+
+	// 任意两条都放不进同一组时仍能逐层合并
+	partials, err = s.reducePartials(ctx, opts, "报告", []string{
+		strings.Repeat("甲", 80), strings.Repeat("乙", 80), strings.Repeat("丙", 80),
+	})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, total(partials), 100)
+	assert.Positive(t, merges)
+}
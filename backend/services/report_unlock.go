@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// ErrReportNotLocked 报告没有处于锁定状态，不需要解锁
+var ErrReportNotLocked = errors.New("报告未加密或已解锁")
+
+// UnlockReport 使用密码解密锁定状态的报告并提取文本；storeDecrypted为true时用解密后的副本替换原PDF
+// 需要编辑权限，密码错误时返回 *utils.PDFValidationError
+func (s *ReportService) UnlockReport(ctx context.Context, reportID string, userID string, password string, storeDecrypted bool) (*models.Report, error) {
+	report, _, err := s.AuthorizeReport(ctx, reportID, userID, authz.ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	// 文件和文本按报告所有者的密钥加密、按所有者去重，与执行解锁的用户无关
+	ownerID := report.UserID
+	if report.EncryptionStatus != models.EncryptionLocked {
+		return nil, ErrReportNotLocked
+	}
+
+	object, info, err := s.openPDF(ctx, report.PDFPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取报告PDF失败: %w", err)
+	}
+	defer object.Close()
+
+	// 上传时无法解析加密文件的结构，解锁时再校验页数和对象数限制并提取元数据
+	pdfMetadata, err := utils.InspectPDF(object, info.Size, s.PDFLimits, password)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, decryptedSize, err := s.decryptPDF(object, info.Size, password)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(decrypted.Name())
+	defer decrypted.Close()
+
+	pages := parseReportPages(report.ID, decrypted, decryptedSize)
+	unlocked := *report
+	unlocked.Content = joinReportPages(pages)
+	unlocked.EncryptionStatus = models.EncryptionUnlocked
+
+	if storeDecrypted {
+		contentHash, err := hashContent(decrypted, decryptedSize)
+		if err != nil {
+			return nil, err
+		}
+		contentHash = s.blobHash(ownerID, contentHash)
+		pdfObjectName, err := s.storeBlob(ctx, ownerID, contentHash, decrypted, decryptedSize)
+		if err != nil {
+			return nil, err
+		}
+		unlocked.PDFPath = filepath.Join(s.BucketName, pdfObjectName)
+		unlocked.ContentHash = contentHash
+	}
+
+	stored, err := s.encryptReport(ctx, &unlocked)
+	if err == nil {
+		err = s.reportRepo.Unlock(ctx, stored)
+	}
+	if err != nil {
+		if storeDecrypted {
+			s.releaseBlob(ctx, unlocked.ContentHash)
+		}
+		return nil, err
+	}
+	// 原加密文件不再被该报告引用
+	if storeDecrypted && report.ContentHash != "" {
+		s.releaseBlob(ctx, report.ContentHash)
+	}
+
+	if err := s.savePages(ctx, ownerID, report.ID, pages); err != nil {
+		log.Printf("保存报告%s的分页文本失败: %v", report.ID, err)
+	}
+	if err := s.metadataRepo.Save(ctx, toReportMetadata(report.ID, pdfMetadata)); err != nil {
+		log.Printf("保存报告%s的PDF元数据失败: %v", report.ID, err)
+	}
+	return &unlocked, nil
+}
+
+// decryptPDF 解密PDF并写入临时文件，调用方负责关闭并删除该文件
+// 加密文件的流在解密前是密文，解压大小限制只能在解密后检查，超出限制时返回 *utils.PDFValidationError
+func (s *ReportService) decryptPDF(file io.ReaderAt, size int64, password string) (*os.File, int64, error) {
+	decrypted, decryptedSize, err := utils.DecryptPDFToTempFile(file, size, password, s.PDFLimits)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := utils.CheckPDFStreamSizes(decrypted, decryptedSize, s.PDFLimits); err != nil {
+		decrypted.Close()
+		os.Remove(decrypted.Name())
+		return nil, 0, err
+	}
+	return decrypted, decryptedSize, nil
+}
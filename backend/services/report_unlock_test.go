@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// bombPDF 生成只有一页的PDF，页面内容流压缩后很小，解压后为inflated字节
+func bombPDF(t *testing.T, inflated int) []byte {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write(bytes.Repeat([]byte(" "), inflated))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var pdf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, pdf.Len())
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	pdf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>")
+	object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return pdf.Bytes()
+}
+
+func TestReportService_DecryptPDFChecksStreamSizes(t *testing.T) {
+	limits := utils.PDFLimits{MaxStreamSize: 1 << 20, MaxTotalStreamSize: 4 << 20}
+	plain := bombPDF(t, 8<<20)
+	err := utils.ValidatePDF(bytes.NewReader(plain), int64(len(plain)), limits)
+	require.Error(t, err, "未加密的压缩炸弹在校验时即被拒绝")
+
+	var encrypted bytes.Buffer
+	require.NoError(t, api.Encrypt(bytes.NewReader(plain), &encrypted, model.NewAESConfiguration("user-secret", "owner-secret", 256)))
+	reader, size := bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len())
+
+	// 加密后流是密文，解密前的检查无法发现
+	_, err = utils.InspectPDF(reader, size, limits, "user-secret")
+	require.NoError(t, err)
+
+	s := &ReportService{PDFLimits: limits}
+	file, _, err := s.decryptPDF(reader, size, "user-secret")
+	if file != nil {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	var validationErr *utils.PDFValidationError
+	require.True(t, errors.As(err, &validationErr), "解密后应检查解压大小: %v", err)
+	assert.Equal(t, utils.PDFRejectStreamTooLarge, validationErr.Reason)
+
+	// 不超出限制的加密文件可以正常解密
+	s.PDFLimits = utils.PDFLimits{MaxStreamSize: 16 << 20}
+	file, decryptedSize, err := s.decryptPDF(reader, size, "user-secret")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	assert.Positive(t, decryptedSize)
+}
+
+// unlockReportRepo 在内存中保存报告，模拟解锁时的条件更新
+type unlockReportRepo struct {
+	repository.IReportRepository
+	reports map[string]*models.Report
+}
+
+func (r *unlockReportRepo) GetByID(_ context.Context, reportID string) (*models.Report, error) {
+	report, ok := r.reports[reportID]
+	if !ok {
+		return nil, repository.ErrReportNotFound
+	}
+	found := *report
+	return &found, nil
+}
+
+func (r *unlockReportRepo) Unlock(_ context.Context, report *models.Report) error {
+	stored, ok := r.reports[report.ID]
+	if !ok || stored.EncryptionStatus != models.EncryptionLocked {
+		return repository.ErrReportNotFound
+	}
+	unlocked := *report
+	r.reports[report.ID] = &unlocked
+	return nil
+}
+
+// discardPageRepo 丢弃保存的分页文本
+type discardPageRepo struct {
+	repository.IReportPageRepository
+}
+
+func (discardPageRepo) SaveAll(context.Context, string, []models.ReportPage) error {
+	return nil
+}
+
+// discardMetadataRepo 丢弃保存的PDF元数据
+type discardMetadataRepo struct {
+	repository.IReportMetadataRepository
+}
+
+func (discardMetadataRepo) Save(context.Context, *models.ReportMetadata) error {
+	return nil
+}
+
+func TestReportService_UnlockStoreDecryptedReleasesOldBlob(t *testing.T) {
+	ctx := context.Background()
+	var encrypted bytes.Buffer
+	require.NoError(t, api.Encrypt(bytes.NewReader(bombPDF(t, 1024)), &encrypted, model.NewAESConfiguration("user-secret", "owner-secret", 256)))
+	store := storage.NewMemoryStore()
+	_, err := store.Put(ctx, "locked.pdf", bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len()), "application/pdf")
+	require.NoError(t, err)
+
+	// 两个报告共享同一个加密文件
+	reportRepo := &unlockReportRepo{reports: map[string]*models.Report{
+		"r1": {ID: "r1", UserID: "u1", PDFPath: "reports/locked.pdf", ContentHash: "locked", EncryptionStatus: models.EncryptionLocked},
+		"r2": {ID: "r2", UserID: "u1", PDFPath: "reports/locked.pdf", ContentHash: "locked", EncryptionStatus: models.EncryptionLocked},
+	}}
+	blobRepo := &fakeBlobRepo{blobs: map[string]*models.Blob{"locked": {Hash: "locked", ObjectKey: "locked.pdf", RefCount: 2}}}
+	s := NewReportService(reportRepo, discardPageRepo{}, blobRepo, discardMetadataRepo{}, store, nil, "reports")
+
+	// 保存解密副本后引用新对象，原加密文件的引用减一，其他报告仍引用时保留
+	unlocked, err := s.UnlockReport(ctx, "r1", "u1", "user-secret", true)
+	require.NoError(t, err)
+	assert.NotEqual(t, "locked", unlocked.ContentHash)
+	assert.Equal(t, unlocked.ContentHash, reportRepo.reports["r1"].ContentHash)
+	assert.Equal(t, 1, blobRepo.blobs[unlocked.ContentHash].RefCount)
+	assert.Equal(t, 1, blobRepo.blobs["locked"].RefCount)
+	_, err = store.Stat(ctx, "locked.pdf")
+	require.NoError(t, err)
+
+	// 最后一个引用释放后删除加密文件
+	again, err := s.UnlockReport(ctx, "r2", "u1", "user-secret", true)
+	require.NoError(t, err)
+	assert.Positive(t, blobRepo.blobs[again.ContentHash].RefCount)
+	assert.NotContains(t, blobRepo.blobs, "locked")
+	_, err = store.Stat(ctx, "locked.pdf")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
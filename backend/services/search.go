@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// 检索参数
+const (
+	searchMinQueryRunes = 2   // ngram分词的最小长度
+	searchMaxQueryRunes = 100 // 查询词最大长度
+	searchDefaultLimit  = 20
+	searchMaxLimit      = 50
+	searchSnippetRunes  = 120 // 高亮片段长度
+)
+
+// ErrInvalidSearchQuery 搜索关键词无效
+var ErrInvalidSearchQuery = errors.New("无效的搜索关键词")
+
+// SearchReports 在用户的报告中全文检索，返回按相关度排序的命中结果和高亮片段
+// 启用加密时内容和摘要以密文保存，只检索标题
+func (s *ReportService) SearchReports(ctx context.Context, userID string, query string, limit int) (*models.ReportSearchResponse, error) {
+	query = strings.TrimSpace(query)
+	if n := utf8.RuneCountInString(query); n < searchMinQueryRunes || n > searchMaxQueryRunes {
+		return nil, fmt.Errorf("%w: 长度应为%d到%d个字符", ErrInvalidSearchQuery, searchMinQueryRunes, searchMaxQueryRunes)
+	}
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	limit = min(limit, searchMaxLimit)
+
+	search := s.reportRepo.Search
+	if s.Keys.Enabled() {
+		search = s.reportRepo.SearchTitles
+	}
+	matches, err := search(ctx, userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]models.ReportSearchHit, 0, len(matches))
+	for _, match := range matches {
+		report := match.Report
+		hasSummary := report.Summary != ""
+		if s.Keys.Enabled() {
+			// 密文不生成片段
+			report.Content, report.Summary = "", ""
+		}
+		hits = append(hits, models.ReportSearchHit{
+			ReportID:       report.ID,
+			Title:          report.Title,
+			CreatedAt:      report.CreatedAt,
+			HasSummary:     hasSummary,
+			Score:          match.Score,
+			TitleHighlight: utils.HighlightSnippet(report.Title, query, 0),
+			ContentSnippet: utils.HighlightSnippet(strings.ReplaceAll(report.Content, utils.PageSeparator, "\n"), query, searchSnippetRunes),
+			SummarySnippet: utils.HighlightSnippet(report.Summary, query, searchSnippetRunes),
+		})
+	}
+
+	return &models.ReportSearchResponse{Query: query, Hits: hits}, nil
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// shareTokenBytes 分享令牌的随机字节数
+const shareTokenBytes = 32
+
+// 分享链接相关错误
+var (
+	// ErrShareExpired 分享链接已过期或已被撤销
+	ErrShareExpired = errors.New("分享链接已过期或已被撤销")
+	// ErrSharePasswordRequired 分享链接设置了访问密码，请求未提供密码
+	ErrSharePasswordRequired = errors.New("需要访问密码")
+	// ErrSharePasswordInvalid 访问密码错误
+	ErrSharePasswordInvalid = errors.New("访问密码错误")
+	// ErrShareDownloadNotAllowed 分享链接不允许下载PDF
+	ErrShareDownloadNotAllowed = errors.New("该分享链接不允许下载PDF")
+	// ErrInvalidShareRequest 创建分享链接的参数无效
+	ErrInvalidShareRequest = errors.New("无效的分享参数")
+)
+
+// ShareService 报告分享服务
+// 分享链接代表创建者授予的访问权限：通过链接访问时以创建者的身份检查权限并读取报告，报告被删除后链接随之失效
+type ShareService struct {
+	shareRepo     repository.IShareRepository
+	reportService *ReportService
+	// BaseURL 客户端访问本服务使用的地址，用于拼接分享链接
+	BaseURL string
+	// shareFailures、ipFailures 分别按分享链接和客户端IP限制访问密码的失败次数
+	shareFailures *attemptLimiter
+	ipFailures    *attemptLimiter
+}
+
+// NewShareService 创建报告分享服务
+func NewShareService(shareRepo repository.IShareRepository, reportService *ReportService, baseURL string) *ShareService {
+	return &ShareService{
+		shareRepo:     shareRepo,
+		reportService: reportService,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		shareFailures: newAttemptLimiter(sharePasswordShareFailures, sharePasswordWindow),
+		ipFailures:    newAttemptLimiter(sharePasswordIPFailures, sharePasswordWindow),
+	}
+}
+
+// CreateShare 为报告创建分享链接，只有所有者可以创建，令牌只在返回结果中出现一次
+func (s *ShareService) CreateShare(ctx context.Context, reportID, userID string, req models.CreateShareRequest) (*models.ShareResponse, error) {
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, fmt.Errorf("%w: 过期时间必须晚于当前时间", ErrInvalidShareRequest)
+	}
+	if _, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("生成分享令牌失败: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	share := &models.ReportShare{
+		ID:            uuid.New().String(),
+		ReportID:      reportID,
+		UserID:        userID,
+		TokenHash:     hashShareToken(token),
+		AllowDownload: req.AllowDownload,
+		ExpiresAt:     req.ExpiresAt,
+		CreatedAt:     now,
+	}
+	if req.Password != "" {
+		passwordHash, err := utils.GeneratePasswordHash(req.Password, nil)
+		if err != nil {
+			return nil, fmt.Errorf("生成密码哈希失败: %w", err)
+		}
+		share.PasswordHash = passwordHash
+		share.HasPassword = true
+	}
+	if err := s.shareRepo.Create(ctx, share); err != nil {
+		return nil, err
+	}
+
+	return &models.ShareResponse{
+		ReportShare: *share,
+		Active:      true,
+		Token:       token,
+		URL:         s.BaseURL + "/s/" + token,
+	}, nil
+}
+
+// ListShares 列出用户为报告创建的全部分享链接，包括已撤销和已过期的链接
+func (s *ShareService) ListShares(ctx context.Context, reportID, userID string) ([]models.ShareResponse, error) {
+	if _, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return nil, err
+	}
+	shares, err := s.shareRepo.ListByReportID(ctx, reportID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	responses := make([]models.ShareResponse, 0, len(shares))
+	for _, share := range shares {
+		responses = append(responses, models.ShareResponse{ReportShare: share, Active: share.Active(now)})
+	}
+	return responses, nil
+}
+
+// RevokeShare 撤销分享链接，撤销后立即无法访问
+func (s *ShareService) RevokeShare(ctx context.Context, reportID, shareID, userID string) error {
+	if _, _, err := s.reportService.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return err
+	}
+	return s.shareRepo.Revoke(ctx, shareID, reportID, userID)
+}
+
+// GetSharedReport 通过分享链接查看报告标题和摘要，并累加访问次数；clientIP用于限制访问密码的猜测次数
+func (s *ShareService) GetSharedReport(ctx context.Context, token, password, clientIP string) (*models.SharedReportResponse, error) {
+	share, err := s.authorize(ctx, token, password, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	report, _, err := s.reportService.AuthorizeReport(ctx, share.ReportID, share.UserID, authz.ActionView)
+	if err != nil {
+		if errors.Is(err, repository.ErrReportNotFound) {
+			return nil, repository.ErrShareNotFound
+		}
+		return nil, err
+	}
+
+	// 访问计数只是统计信息，失败时不影响查看
+	if err := s.shareRepo.RecordAccess(ctx, share.ID); err != nil {
+		log.Printf("记录分享链接%s的访问失败: %v", share.ID, err)
+	}
+
+	response := &models.SharedReportResponse{
+		Title:         report.Title,
+		Summary:       report.Summary,
+		CreatedAt:     report.CreatedAt,
+		AllowDownload: share.AllowDownload,
+		ExpiresAt:     share.ExpiresAt,
+	}
+	if share.AllowDownload {
+		response.PDFURL = s.BaseURL + "/s/" + token + "/pdf"
+		if share.PasswordHash != "" {
+			// 浏览器和PDF预览器打开链接时无法携带密码请求头，已通过密码校验的访问返回带签名的链接
+			response.PDFURL += "?" + s.signShareLink(share).Encode()
+		}
+	}
+	return response, nil
+}
+
+// AuthorizeDownload 校验分享链接是否允许下载PDF，返回分享链接，调用方以创建者的身份读取PDF
+// link为下载请求的查询参数，带有GetSharedReport返回的签名时不需要访问密码
+func (s *ShareService) AuthorizeDownload(ctx context.Context, token, password, clientIP string, link url.Values) (*models.ReportShare, error) {
+	var share *models.ReportShare
+	var err error
+	if link.Get("signature") != "" {
+		share, err = s.activeShare(ctx, token)
+		if err == nil {
+			err = s.verifyShareLink(share, link)
+		}
+	} else {
+		share, err = s.authorize(ctx, token, password, clientIP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !share.AllowDownload {
+		return nil, ErrShareDownloadNotAllowed
+	}
+	return share, nil
+}
+
+// RecordDownload 累加一次访问次数，失败时只记录日志
+func (s *ShareService) RecordDownload(ctx context.Context, share *models.ReportShare) {
+	if err := s.shareRepo.RecordAccess(ctx, share.ID); err != nil {
+		log.Printf("记录分享链接%s的下载失败: %v", share.ID, err)
+	}
+}
+
+// signShareLink 为分享链接的PDF下载地址生成签名参数，有效期同报告PDF下载链接，且不晚于分享链接的过期时间
+func (s *ShareService) signShareLink(share *models.ReportShare) url.Values {
+	ttl := s.reportService.PDFLinks.TTL
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	expiresAt := time.Now().Add(ttl)
+	if share.ExpiresAt != nil && share.ExpiresAt.Before(expiresAt) {
+		expiresAt = *share.ExpiresAt
+	}
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return url.Values{"expires": {expires}, "signature": {s.shareLinkSignature(share.ID, expires)}}
+}
+
+// verifyShareLink 校验下载地址的签名和过期时间
+func (s *ShareService) verifyShareLink(share *models.ReportShare, link url.Values) error {
+	expires, signature := link.Get("expires"), link.Get("signature")
+	if expires == "" || len(s.reportService.PDFLinks.Secret) == 0 {
+		return ErrPDFLinkInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.shareLinkSignature(share.ID, expires))) {
+		return ErrPDFLinkInvalid
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrPDFLinkInvalid
+	}
+	if time.Now().Unix() > unix {
+		return ErrPDFLinkExpired
+	}
+	return nil
+}
+
+// shareLinkSignature 签名覆盖分享ID和过期时间，与报告PDF下载链接使用同一密钥，以前缀区分
+func (s *ShareService) shareLinkSignature(shareID, expires string) string {
+	mac := hmac.New(sha256.New, s.reportService.PDFLinks.Secret)
+	mac.Write([]byte("share\n" + shareID + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authorize 校验分享令牌、有效期和访问密码
+// 同一链接或同一客户端IP的密码错误次数达到上限后，在窗口结束前直接返回 *ShareThrottledError，不再计算密码哈希
+func (s *ShareService) authorize(ctx context.Context, token, password, clientIP string) (*models.ReportShare, error) {
+	share, err := s.activeShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if share.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		shareKey, ipKey := "share:"+share.ID, "ip:"+clientIP
+		if err := s.checkThrottle(shareKey, ipKey); err != nil {
+			return nil, err
+		}
+		ok, err := utils.VerifyPassword(password, share.PasswordHash)
+		if err != nil {
+			return nil, fmt.Errorf("校验访问密码失败: %w", err)
+		}
+		if !ok {
+			s.shareFailures.fail(shareKey)
+			s.ipFailures.fail(ipKey)
+			return nil, ErrSharePasswordInvalid
+		}
+		// 只清除该链接的计数，IP的计数不因猜中其他链接而清除
+		s.shareFailures.reset(shareKey)
+	}
+	return share, nil
+}
+
+// activeShare 根据令牌获取未过期、未撤销的分享链接
+func (s *ShareService) activeShare(ctx context.Context, token string) (*models.ReportShare, error) {
+	if token == "" {
+		return nil, repository.ErrShareNotFound
+	}
+	share, err := s.shareRepo.GetByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if !share.Active(time.Now()) {
+		return nil, ErrShareExpired
+	}
+	return share, nil
+}
+
+// checkThrottle 链接或IP任意一个达到失败次数上限时返回 *ShareThrottledError，等待时间取较长的一个
+func (s *ShareService) checkThrottle(shareKey, ipKey string) error {
+	shareWait, shareBlocked := s.shareFailures.blocked(shareKey)
+	ipWait, ipBlocked := s.ipFailures.blocked(ipKey)
+	switch {
+	case shareBlocked && ipBlocked:
+		return &ShareThrottledError{RetryAfter: max(shareWait, ipWait)}
+	case shareBlocked:
+		return &ShareThrottledError{RetryAfter: shareWait}
+	case ipBlocked:
+		return &ShareThrottledError{RetryAfter: ipWait}
+	}
+	return nil
+}
+
+// hashShareToken 计算分享令牌的SHA-256，数据库中只保存哈希，泄露数据库也无法还原链接
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/utils"
+)
+
+// fakeShareRepo 内存中的分享链接仓储
+type fakeShareRepo struct {
+	shares []models.ReportShare
+}
+
+func (r *fakeShareRepo) Create(_ context.Context, share *models.ReportShare) error {
+	r.shares = append(r.shares, *share)
+	return nil
+}
+
+func (r *fakeShareRepo) GetByTokenHash(_ context.Context, tokenHash string) (*models.ReportShare, error) {
+	for _, share := range r.shares {
+		if share.TokenHash == tokenHash {
+			return &share, nil
+		}
+	}
+	return nil, repository.ErrShareNotFound
+}
+
+func (r *fakeShareRepo) ListByReportID(_ context.Context, reportID string, userID string) ([]models.ReportShare, error) {
+	return r.shares, nil
+}
+
+func (r *fakeShareRepo) Revoke(_ context.Context, shareID string, reportID string, userID string) error {
+	for i := range r.shares {
+		if r.shares[i].ID == shareID && r.shares[i].RevokedAt == nil {
+			now := time.Now()
+			r.shares[i].RevokedAt = &now
+			return nil
+		}
+	}
+	return repository.ErrShareNotFound
+}
+
+func (r *fakeShareRepo) RecordAccess(_ context.Context, shareID string) error {
+	return nil
+}
+
+func TestShareService_Authorize(t *testing.T) {
+	ctx := context.Background()
+	passwordHash, err := utils.GeneratePasswordHash("secret", nil)
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour)
+	repo := &fakeShareRepo{shares: []models.ReportShare{
+		{ID: "open", ReportID: "r1", UserID: "u1", TokenHash: hashShareToken("open-token")},
+		{ID: "protected", ReportID: "r1", UserID: "u1", TokenHash: hashShareToken("protected-token"), PasswordHash: passwordHash, AllowDownload: true},
+		{ID: "expired", ReportID: "r1", UserID: "u1", TokenHash: hashShareToken("expired-token"), ExpiresAt: &past},
+	}}
+	reportService := &ReportService{
+		reportRepo: &fakeReportRepo{reports: []models.Report{{ID: "r1", UserID: "u1"}}},
+		Policy:     authz.NewReportPolicy(nil),
+		PDFLinks:   PDFLinkConfig{TTL: time.Hour, Secret: []byte("link-secret")},
+	}
+	s := NewShareService(repo, reportService, "http://localhost:8080/")
+
+	share, err := s.authorize(ctx, "open-token", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "open", share.ID)
+
+	_, err = s.authorize(ctx, "unknown-token", "", "")
+	assert.ErrorIs(t, err, repository.ErrShareNotFound)
+	_, err = s.authorize(ctx, "expired-token", "", "")
+	assert.ErrorIs(t, err, ErrShareExpired)
+
+	_, err = s.authorize(ctx, "protected-token", "", "")
+	assert.ErrorIs(t, err, ErrSharePasswordRequired)
+	_, err = s.authorize(ctx, "protected-token", "wrong", "")
+	assert.ErrorIs(t, err, ErrSharePasswordInvalid)
+	_, err = s.AuthorizeDownload(ctx, "protected-token", "secret", "", nil)
+	assert.NoError(t, err)
+
+	_, err = s.AuthorizeDownload(ctx, "open-token", "", "", nil)
+	assert.ErrorIs(t, err, ErrShareDownloadNotAllowed)
+
+	// 签名的下载地址不需要密码，签名只对生成它的分享链接有效
+	link := s.signShareLink(&repo.shares[1])
+	_, err = s.AuthorizeDownload(ctx, "protected-token", "", "", link)
+	assert.NoError(t, err)
+	_, err = s.AuthorizeDownload(ctx, "expired-token", "", "", link)
+	assert.ErrorIs(t, err, ErrShareExpired)
+	link.Set("expires", strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10))
+	_, err = s.AuthorizeDownload(ctx, "protected-token", "", "", link)
+	assert.ErrorIs(t, err, ErrPDFLinkInvalid)
+
+	// 只有能管理报告的用户可以撤销
+	assert.ErrorIs(t, s.RevokeShare(ctx, "r1", "open", "u2"), repository.ErrReportNotFound)
+
+	// 撤销后立即失效
+	require.NoError(t, s.RevokeShare(ctx, "r1", "open", "u1"))
+	_, err = s.authorize(ctx, "open-token", "", "")
+	assert.ErrorIs(t, err, ErrShareExpired)
+	assert.ErrorIs(t, s.RevokeShare(ctx, "r1", "open", "u1"), repository.ErrShareNotFound)
+}
+
+func TestShareService_PasswordThrottle(t *testing.T) {
+	ctx := context.Background()
+	passwordHash, err := utils.GeneratePasswordHash("secret", nil)
+	require.NoError(t, err)
+	repo := &fakeShareRepo{shares: []models.ReportShare{
+		{ID: "a", TokenHash: hashShareToken("token-a"), PasswordHash: passwordHash},
+		{ID: "b", TokenHash: hashShareToken("token-b"), PasswordHash: passwordHash},
+	}}
+	s := NewShareService(repo, nil, "")
+	now := time.Now()
+	s.shareFailures.now = func() time.Time { return now }
+	s.ipFailures.now = s.shareFailures.now
+
+	// 同一链接连续猜错后，正确的密码也被拒绝，直到窗口结束
+	for range sharePasswordShareFailures {
+		_, err := s.authorize(ctx, "token-a", "wrong", "10.0.0.1")
+		require.ErrorIs(t, err, ErrSharePasswordInvalid)
+	}
+	_, err = s.authorize(ctx, "token-a", "secret", "10.0.0.2")
+	var throttled *ShareThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.ErrorIs(t, err, ErrShareThrottled)
+	assert.Equal(t, sharePasswordWindow, throttled.RetryAfter)
+
+	// 其他链接不受影响
+	_, err = s.authorize(ctx, "token-b", "secret", "10.0.0.2")
+	require.NoError(t, err)
+
+	now = now.Add(sharePasswordWindow)
+	_, err = s.authorize(ctx, "token-a", "secret", "10.0.0.2")
+	require.NoError(t, err)
+
+	// 同一IP对多个链接的失败次数合并计算
+	s.ipFailures = newAttemptLimiter(2, sharePasswordWindow)
+	s.ipFailures.now = s.shareFailures.now
+	for _, token := range []string{"token-a", "token-b"} {
+		_, err := s.authorize(ctx, token, "wrong", "10.0.0.3")
+		require.ErrorIs(t, err, ErrSharePasswordInvalid)
+	}
+	_, err = s.authorize(ctx, "token-b", "secret", "10.0.0.3")
+	assert.ErrorIs(t, err, ErrShareThrottled)
+	_, err = s.authorize(ctx, "token-b", "secret", "10.0.0.4")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrShareThrottled 访问密码错误次数过多，暂时不再校验密码
+var ErrShareThrottled = errors.New("访问密码错误次数过多，请稍后再试")
+
+// ShareThrottledError 密码尝试被限制的详细信息，errors.Is(err, ErrShareThrottled) 为true
+type ShareThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ShareThrottledError) Error() string {
+	return fmt.Sprintf("%s，%d秒后可重试", ErrShareThrottled.Error(), int(math.Ceil(e.RetryAfter.Seconds())))
+}
+
+// Is 使 errors.Is(err, ErrShareThrottled) 成立
+func (e *ShareThrottledError) Is(target error) bool {
+	return target == ErrShareThrottled
+}
+
+// 分享链接访问密码的失败次数限制，窗口从第一次失败开始计算
+const (
+	sharePasswordShareFailures = 5                // 同一分享链接在窗口内允许的失败次数
+	sharePasswordIPFailures    = 20               // 同一客户端IP在窗口内允许的失败次数，覆盖对多个链接的猜测
+	sharePasswordWindow        = 15 * time.Minute // 失败计数窗口，达到次数后直到窗口结束都不再校验密码
+	attemptLimiterMaxKeys      = 100000           // 计数条目超过该数量时清理已过期的条目
+)
+
+// attemptLimiter 按键统计窗口内的失败次数，只保存在进程内存中，多实例部署时各实例分别计数
+type attemptLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	failures    map[string]attemptWindow
+	now         func() time.Time
+}
+
+type attemptWindow struct {
+	count int
+	start time.Time
+}
+
+func newAttemptLimiter(maxFailures int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{maxFailures: maxFailures, window: window, failures: map[string]attemptWindow{}, now: time.Now}
+}
+
+// blocked 返回key是否已达到失败次数上限以及需要等待的时间
+func (l *attemptLimiter) blocked(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w, ok := l.failures[key]
+	if !ok {
+		return 0, false
+	}
+	wait := w.start.Add(l.window).Sub(l.now())
+	if wait <= 0 {
+		delete(l.failures, key)
+		return 0, false
+	}
+	return wait, w.count >= l.maxFailures
+}
+
+// fail 记录一次失败
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	w, ok := l.failures[key]
+	if !ok || !now.Before(w.start.Add(l.window)) {
+		if len(l.failures) >= attemptLimiterMaxKeys {
+			l.sweep(now)
+		}
+		w = attemptWindow{start: now}
+	}
+	w.count++
+	l.failures[key] = w
+}
+
+// reset 清除key的失败记录
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+func (l *attemptLimiter) sweep(now time.Time) {
+	for key, w := range l.failures {
+		if !now.Before(w.start.Add(l.window)) {
+			delete(l.failures, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+// ErrInvalidSummaryOptions 摘要选项无效
+var ErrInvalidSummaryOptions = errors.New("无效的摘要选项")
+
+// summaryTemplates 各摘要风格的提示词模板，模板名称即风格名称；新增风格只需增加一个模板
+// 模板参数：Subject 对报告的称呼，Requirements 篇幅和语言要求
+var summaryTemplates = template.Must(template.New("summary").Parse(`
+{{- define "concise"}}请为{{.Subject}}生成一个简洁的摘要。{{.Requirements}}{{end}}
+
+{{- define "executive"}}请为{{.Subject}}撰写一份面向管理层的执行摘要：先用一句话给出核心结论，再概括关键数据、主要变化和建议采取的行动。{{.Requirements}}{{end}}
+
+{{- define "bullet_points"}}请将{{.Subject}}的要点整理为列表，每条以"- "开头、只写一个要点，按重要性排序并保留关键数据。{{.Requirements}}{{end}}
+
+{{- define "risk"}}请从风险角度总结{{.Subject}}：列出报告揭示的主要风险、不确定因素和不利变化，说明可能的影响并引用相关数据；报告未涉及风险时请直接说明。{{.Requirements}}{{end}}
+
+{{- define "plain"}}请用通俗易懂的语言为没有专业背景的读者总结{{.Subject}}，避免使用术语，必要的专业概念用一句话解释。{{.Requirements}}{{end}}
+`))
+
+// summaryLanguages 各输出语言的写作要求
+var summaryLanguages = map[string]string{
+	models.SummaryLanguageChinese: "使用简体中文撰写",
+	models.SummaryLanguageEnglish: "使用英文（English）撰写，专有名词保留原文或使用通行的英文译名",
+}
+
+// summaryLengths 各输出语言下不同篇幅的字数要求
+var summaryLengths = map[string]map[string]string{
+	models.SummaryLanguageChinese: {
+		models.SummaryLengthShort:  "不超过100字",
+		models.SummaryLengthMedium: "不超过200字",
+		models.SummaryLengthLong:   "不超过500字",
+	},
+	models.SummaryLanguageEnglish: {
+		models.SummaryLengthShort:  "不超过80个英文单词",
+		models.SummaryLengthMedium: "不超过150个英文单词",
+		models.SummaryLengthLong:   "不超过400个英文单词",
+	},
+}
+
+// summaryFocus 长报告分块摘要时各风格提取信息的侧重点，未列出的风格使用defaultSummaryFocus
+var summaryFocus = map[string]string{
+	models.SummaryStyleRisk: "风险、不确定因素、不利变化及相关数据",
+}
+
+const defaultSummaryFocus = "关键信息和重要数据"
+
+// NormalizeSummaryOptions 校验摘要选项并补全默认值：简洁风格、中等篇幅、简体中文
+func NormalizeSummaryOptions(opts models.SummaryOptions) (models.SummaryOptions, error) {
+	if opts.Style == "" {
+		opts.Style = models.SummaryStyleConcise
+	}
+	if opts.Length == "" {
+		opts.Length = models.SummaryLengthMedium
+	}
+	if opts.Language == "" {
+		opts.Language = models.SummaryLanguageChinese
+	}
+
+	if opts.Style == summaryTemplates.Name() || summaryTemplates.Lookup(opts.Style) == nil {
+		return opts, fmt.Errorf("%w: style可选值为 %s", ErrInvalidSummaryOptions, strings.Join(summaryStyles(), ", "))
+	}
+	lengths, ok := summaryLengths[opts.Language]
+	if !ok {
+		return opts, fmt.Errorf("%w: language可选值为 %s", ErrInvalidSummaryOptions, strings.Join(sortedKeys(summaryLanguages), ", "))
+	}
+	if _, ok := lengths[opts.Length]; !ok {
+		return opts, fmt.Errorf("%w: length可选值为 %s", ErrInvalidSummaryOptions, strings.Join(sortedKeys(lengths), ", "))
+	}
+	return opts, nil
+}
+
+// summaryInstruction 按摘要选项渲染提示词中的摘要要求，subject为对报告的称呼；opts需已经过NormalizeSummaryOptions
+func summaryInstruction(opts models.SummaryOptions, subject string) (string, error) {
+	data := struct {
+		Subject      string
+		Requirements string
+	}{
+		Subject:      subject,
+		Requirements: fmt.Sprintf("要求：篇幅%s，%s。", summaryLengths[opts.Language][opts.Length], summaryLanguages[opts.Language]),
+	}
+	var builder strings.Builder
+	if err := summaryTemplates.ExecuteTemplate(&builder, opts.Style, data); err != nil {
+		return "", fmt.Errorf("渲染摘要提示词失败: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// summaryFocusOf 长报告分块摘要时提取信息的侧重点
+func summaryFocusOf(opts models.SummaryOptions) string {
+	if focus, ok := summaryFocus[opts.Style]; ok {
+		return focus
+	}
+	return defaultSummaryFocus
+}
+
+// summaryStyles 全部摘要风格名称
+func summaryStyles() []string {
+	var styles []string
+	for _, tmpl := range summaryTemplates.Templates() {
+		if tmpl.Name() != summaryTemplates.Name() {
+			styles = append(styles, tmpl.Name())
+		}
+	}
+	sort.Strings(styles)
+	return styles
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+)
+
+func TestNormalizeSummaryOptions(t *testing.T) {
+	opts, err := NormalizeSummaryOptions(models.SummaryOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, models.SummaryOptions{
+		Style:    models.SummaryStyleConcise,
+		Length:   models.SummaryLengthMedium,
+		Language: models.SummaryLanguageChinese,
+	}, opts)
+
+	invalid := []models.SummaryOptions{
+		{Style: "poem"},
+		{Style: "summary"}, // 模板集合的根模板不是风格
+		{Length: "huge"},
+		{Language: "fr"},
+	}
+	for _, opts := range invalid {
+		_, err := NormalizeSummaryOptions(opts)
+		assert.ErrorIs(t, err, ErrInvalidSummaryOptions, opts)
+	}
+}
+
+func TestSummaryInstruction(t *testing.T) {
+	assert.Equal(t, []string{
+		models.SummaryStyleBulletPoints, models.SummaryStyleConcise, models.SummaryStyleExecutive,
+		models.SummaryStylePlain, models.SummaryStyleRisk,
+	}, summaryStyles())
+
+	for _, style := range summaryStyles() {
+		opts, err := NormalizeSummaryOptions(models.SummaryOptions{Style: style, Length: models.SummaryLengthLong, Language: models.SummaryLanguageEnglish})
+		require.NoError(t, err)
+		instruction, err := summaryInstruction(opts, "以下报告")
+		require.NoError(t, err)
+		assert.Contains(t, instruction, "以下报告", style)
+		assert.Contains(t, instruction, "不超过400个英文单词", style)
+		assert.Contains(t, instruction, "English", style)
+	}
+
+	instruction, err := summaryInstruction(models.SummaryOptions{
+		Style: models.SummaryStyleConcise, Length: models.SummaryLengthMedium, Language: models.SummaryLanguageChinese,
+	}, "以下报告")
+	require.NoError(t, err)
+	assert.Equal(t, "请为以下报告生成一个简洁的摘要。要求：篇幅不超过200字，使用简体中文撰写。", instruction)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// 团队相关错误
+var (
+	// ErrNotTeamAdmin 只有团队管理员可以管理成员
+	ErrNotTeamAdmin = errors.New("只有团队管理员可以管理成员")
+	// ErrInvalidTeamRequest 团队参数无效
+	ErrInvalidTeamRequest = errors.New("无效的团队参数")
+)
+
+// TeamService 团队服务
+type TeamService struct {
+	teamRepo repository.ITeamRepository
+	userRepo repository.IUserRepository
+}
+
+// NewTeamService 创建团队服务
+func NewTeamService(teamRepo repository.ITeamRepository, userRepo repository.IUserRepository) *TeamService {
+	return &TeamService{teamRepo: teamRepo, userRepo: userRepo}
+}
+
+// CreateTeam 创建团队，创建者成为团队管理员
+func (s *TeamService) CreateTeam(ctx context.Context, userID string, name string) (*models.Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: 团队名称不能为空", ErrInvalidTeamRequest)
+	}
+	team := &models.Team{
+		ID:        uuid.New().String(),
+		Name:      name,
+		OwnerID:   userID,
+		Role:      models.TeamRoleAdmin,
+		CreatedAt: time.Now(),
+	}
+	if err := s.teamRepo.Create(ctx, team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// ListTeams 列出用户所在的团队
+func (s *TeamService) ListTeams(ctx context.Context, userID string) ([]models.Team, error) {
+	return s.teamRepo.ListByUserID(ctx, userID)
+}
+
+// ListMembers 列出团队成员，只有团队成员可以查看
+func (s *TeamService) ListMembers(ctx context.Context, teamID string, userID string) ([]models.TeamMember, error) {
+	if _, err := s.teamRepo.GetMemberRole(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.ListMembers(ctx, teamID)
+}
+
+// AddMember 按邮箱添加团队成员，成员已存在时更新角色；需要团队管理员权限
+func (s *TeamService) AddMember(ctx context.Context, teamID string, userID string, req models.AddTeamMemberRequest) ([]models.TeamMember, error) {
+	if err := s.requireAdmin(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+	member, err := s.userRepo.GetByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		return nil, err
+	}
+	role := req.Role
+	if role == "" {
+		role = models.TeamRoleMember
+	}
+	team, err := s.teamRepo.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if member.ID == team.OwnerID && role != models.TeamRoleAdmin {
+		return nil, fmt.Errorf("%w: 不能取消团队创建者的管理员角色", ErrInvalidTeamRequest)
+	}
+	if err := s.teamRepo.UpsertMember(ctx, teamID, member.ID, role); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.ListMembers(ctx, teamID)
+}
+
+// RemoveMember 移除团队成员；管理员可以移除其他成员，成员也可以自行退出，团队创建者不能被移除
+func (s *TeamService) RemoveMember(ctx context.Context, teamID string, userID string, memberID string) error {
+	if memberID != userID {
+		if err := s.requireAdmin(ctx, teamID, userID); err != nil {
+			return err
+		}
+	}
+	team, err := s.teamRepo.GetByID(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if team.OwnerID == memberID {
+		return fmt.Errorf("%w: 不能移除团队创建者", ErrInvalidTeamRequest)
+	}
+	return s.teamRepo.RemoveMember(ctx, teamID, memberID)
+}
+
+// requireAdmin 检查用户是否为团队管理员，不是团队成员时返回ErrTeamNotFound
+func (s *TeamService) requireAdmin(ctx context.Context, teamID string, userID string) error {
+	role, err := s.teamRepo.GetMemberRole(ctx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if role != models.TeamRoleAdmin {
+		return ErrNotTeamAdmin
+	}
+	return nil
+}
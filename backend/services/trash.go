@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/authz"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// 回收站参数
+const (
+	defaultTrashRetention = 30 * 24 * time.Hour
+	purgeBatchSize        = 100
+)
+
+// ErrInvalidTitle 报告标题无效
+var ErrInvalidTitle = errors.New("标题不能为空")
+
+// DeleteReport 将报告移入回收站，保留期内可恢复；只有所有者可以删除
+func (s *ReportService) DeleteReport(ctx context.Context, reportID string, userID string) error {
+	if _, _, err := s.authorizeReport(ctx, reportID, userID, authz.ActionManage); err != nil {
+		return err
+	}
+	return s.reportRepo.SoftDelete(ctx, reportID)
+}
+
+// RestoreReport 从回收站恢复报告，回收站只属于报告的所有者
+func (s *ReportService) RestoreReport(ctx context.Context, reportID string, userID string) error {
+	return s.reportRepo.Restore(ctx, reportID, userID)
+}
+
+// RenameReport 修改报告标题，需要编辑权限
+func (s *ReportService) RenameReport(ctx context.Context, reportID string, userID string, title string) (*models.Report, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, ErrInvalidTitle
+	}
+	if _, _, err := s.authorizeReport(ctx, reportID, userID, authz.ActionEdit); err != nil {
+		return nil, err
+	}
+	if err := s.reportRepo.UpdateTitle(ctx, reportID, title); err != nil {
+		return nil, err
+	}
+	return s.loadReport(ctx, reportID)
+}
+
+// ListTrash 列出用户回收站中的报告及其彻底删除时间
+func (s *ReportService) ListTrash(ctx context.Context, userID string) ([]models.TrashItem, error) {
+	items, err := s.reportRepo.ListDeleted(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	retention := s.trashRetention()
+	for i := range items {
+		items[i].PurgeAt = items[i].DeletedAt.Add(retention)
+	}
+	return items, nil
+}
+
+// StartPurge 按固定间隔彻底删除超过保留期的报告，直到ctx结束
+func (s *ReportService) StartPurge(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.PurgeExpired(ctx); err != nil {
+				log.Printf("清理回收站失败: %v", err)
+			} else if n > 0 {
+				log.Printf("已彻底删除%d个过期报告", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeExpired 彻底删除超过保留期的报告，返回删除的数量
+// 先删除数据库记录再删除PDF对象，对象删除失败只会留下孤立文件，不会出现指向不存在文件的报告；
+// 列出之后被恢复的报告不会被删除
+// 多个报告共享同一PDF时只释放引用，最后一个报告被删除时才删除对象
+func (s *ReportService) PurgeExpired(ctx context.Context) (int, error) {
+	deletedBefore := time.Now().Add(-s.trashRetention())
+	purged := 0
+	for {
+		reports, err := s.reportRepo.ListPurgeable(ctx, deletedBefore, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, report := range reports {
+			err := s.reportRepo.Purge(ctx, report.ID, deletedBefore)
+			if errors.Is(err, repository.ErrReportNotFound) {
+				// 列出之后已被恢复，报告和PDF都保留
+				continue
+			}
+			if err != nil {
+				return purged, err
+			}
+			s.removeReportPDF(ctx, report)
+			purged++
+		}
+		if len(reports) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// removeReportPDF 删除报告的PDF：按内容寻址的对象只释放引用，没有其他报告引用时才删除
+func (s *ReportService) removeReportPDF(ctx context.Context, report models.Report) {
+	if report.ContentHash != "" {
+		s.releaseBlob(ctx, report.ContentHash)
+		return
+	}
+	if key := objectKey(report.PDFPath); key != "" {
+		if err := s.Store.Remove(ctx, key); err != nil {
+			log.Printf("删除报告 %s 的PDF文件失败: %v", report.ID, err)
+		}
+	}
+}
+
+func (s *ReportService) trashRetention() time.Duration {
+	if s.TrashRetention > 0 {
+		return s.TrashRetention
+	}
+	return defaultTrashRetention
+}
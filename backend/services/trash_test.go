@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+	"github.com/qujing226/pdf-enhancer/backend/storage"
+)
+
+// trashReportRepo 在内存中模拟回收站相关的条件更新
+type trashReportRepo struct {
+	repository.IReportRepository
+	reports map[string]*models.Report
+	// beforePurge 在Purge执行前调用，用于模拟列出之后的并发恢复
+	beforePurge func(reportID string)
+}
+
+func (r *trashReportRepo) GetByID(_ context.Context, reportID string) (*models.Report, error) {
+	report, ok := r.reports[reportID]
+	if !ok || report.DeletedAt != nil {
+		return nil, repository.ErrReportNotFound
+	}
+	found := *report
+	return &found, nil
+}
+
+func (r *trashReportRepo) SoftDelete(_ context.Context, reportID string) error {
+	report, ok := r.reports[reportID]
+	if !ok || report.DeletedAt != nil {
+		return repository.ErrReportNotFound
+	}
+	now := time.Now()
+	report.DeletedAt = &now
+	return nil
+}
+
+func (r *trashReportRepo) Restore(_ context.Context, reportID string, userID string) error {
+	report, ok := r.reports[reportID]
+	if !ok || report.UserID != userID || report.DeletedAt == nil {
+		return repository.ErrReportNotFound
+	}
+	report.DeletedAt = nil
+	return nil
+}
+
+func (r *trashReportRepo) ListPurgeable(_ context.Context, deletedBefore time.Time, limit int) ([]models.Report, error) {
+	var reports []models.Report
+	for _, report := range r.reports {
+		if report.DeletedAt != nil && report.DeletedAt.Before(deletedBefore) && len(reports) < limit {
+			reports = append(reports, *report)
+		}
+	}
+	return reports, nil
+}
+
+func (r *trashReportRepo) Purge(_ context.Context, reportID string, deletedBefore time.Time) error {
+	if r.beforePurge != nil {
+		r.beforePurge(reportID)
+	}
+	report, ok := r.reports[reportID]
+	if !ok || report.DeletedAt == nil || !report.DeletedAt.Before(deletedBefore) {
+		return repository.ErrReportNotFound
+	}
+	delete(r.reports, reportID)
+	return nil
+}
+
+func TestReportService_TrashRestorePurge(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	_, err := store.Put(ctx, "h1.pdf", strings.NewReader("%PDF"), 4, "application/pdf")
+	require.NoError(t, err)
+
+	repo := &trashReportRepo{reports: map[string]*models.Report{
+		"r1": {ID: "r1", UserID: "u1", PDFPath: "reports/h1.pdf", ContentHash: "h1"},
+		"r2": {ID: "r2", UserID: "u1", PDFPath: "reports/h1.pdf", ContentHash: "h1"},
+	}}
+	blobRepo := &fakeBlobRepo{blobs: map[string]*models.Blob{"h1": {Hash: "h1", ObjectKey: "h1.pdf", RefCount: 2}}}
+	s := NewReportService(repo, nil, blobRepo, nil, store, nil, "")
+	s.TrashRetention = time.Nanosecond
+
+	// 移入回收站后不可访问，恢复后可以再次访问
+	require.NoError(t, s.DeleteReport(ctx, "r1", "u1"))
+	_, err = s.GetReportByID("r1", "u1")
+	assert.ErrorIs(t, err, repository.ErrReportNotFound)
+	assert.ErrorIs(t, s.RestoreReport(ctx, "r1", "u2"), repository.ErrReportNotFound)
+	require.NoError(t, s.RestoreReport(ctx, "r1", "u1"))
+	_, err = s.GetReportByID("r1", "u1")
+	require.NoError(t, err)
+
+	// 列出之后被恢复的报告不被删除，PDF的引用保持不变
+	require.NoError(t, s.DeleteReport(ctx, "r1", "u1"))
+	time.Sleep(time.Millisecond)
+	repo.beforePurge = func(reportID string) {
+		require.NoError(t, repo.Restore(ctx, reportID, "u1"))
+	}
+	purged, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.Contains(t, repo.reports, "r1")
+	assert.Equal(t, 2, blobRepo.blobs["h1"].RefCount)
+
+	// 超过保留期的报告被彻底删除，只释放引用，其他报告仍引用的PDF保留
+	repo.beforePurge = nil
+	require.NoError(t, s.DeleteReport(ctx, "r1", "u1"))
+	time.Sleep(time.Millisecond)
+	purged, err = s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NotContains(t, repo.reports, "r1")
+	assert.Equal(t, 1, blobRepo.blobs["h1"].RefCount)
+	_, err = store.Stat(ctx, "h1.pdf")
+	require.NoError(t, err)
+
+	// 最后一个引用释放后删除PDF
+	require.NoError(t, s.DeleteReport(ctx, "r2", "u1"))
+	time.Sleep(time.Millisecond)
+	purged, err = s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = store.Stat(ctx, "h1.pdf")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
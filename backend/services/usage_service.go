@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+	"github.com/qujing226/pdf-enhancer/backend/models"
+	"github.com/qujing226/pdf-enhancer/backend/repository"
+)
+
+// 用量统计参数
+const (
+	usageDateLayout       = "2006-01-02"
+	defaultUsageRangeDays = 30  // 未指定统计区间时统计最近30天
+	maxUsageRangeDays     = 366 // 单次统计区间的上限
+)
+
+// ErrInvalidUsageRange 统计区间无效
+var ErrInvalidUsageRange = errors.New("无效的统计区间")
+
+// ModelPrice 模型单价，按每百万token计，币种与配置一致
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// UsageService 记录和统计大模型用量，实现llm.UsageRecorder
+type UsageService struct {
+	usageRepo repository.IUsageRepository
+	pricing   map[string]ModelPrice
+}
+
+// NewUsageService 创建大模型用量服务，pricing为按模型名称配置的单价
+func NewUsageService(usageRepo repository.IUsageRepository, pricing map[string]ModelPrice) *UsageService {
+	return &UsageService{usageRepo: usageRepo, pricing: pricing}
+}
+
+// RecordUsage 保存一次调用的用量和估算费用，保存失败只记录日志，不影响调用结果
+func (s *UsageService) RecordUsage(ctx context.Context, record llm.UsageRecord) {
+	usage := &models.LLMUsage{
+		UserID:           record.UserID,
+		ReportID:         record.ReportID,
+		Operation:        record.Operation,
+		RequestID:        record.RequestID,
+		Provider:         record.Provider,
+		Model:            record.Model,
+		PromptTokens:     record.Usage.PromptTokens,
+		CompletionTokens: record.Usage.CompletionTokens,
+		TotalTokens:      record.Usage.TotalTokens,
+		Estimated:        record.Estimated,
+		LatencyMS:        record.Latency.Milliseconds(),
+		Cost:             s.Cost(record.Model, record.Usage),
+		Status:           models.UsageStatusSuccess,
+		CreatedAt:        record.CreatedAt,
+	}
+	if record.Err != nil {
+		usage.Status = models.UsageStatusError
+	}
+	if err := s.usageRepo.Create(ctx, usage); err != nil {
+		log.Printf("记录大模型用量失败: %v", err)
+	}
+}
+
+// Cost 按模型单价估算费用；模型名称没有精确匹配时使用最长的前缀匹配，如 gpt-4o 匹配 gpt-4o-2024-08-06
+func (s *UsageService) Cost(model string, usage llm.Usage) float64 {
+	price, ok := s.pricing[model]
+	if !ok {
+		matched := ""
+		for name, candidate := range s.pricing {
+			if strings.HasPrefix(model, name) && len(name) > len(matched) {
+				matched, price, ok = name, candidate, true
+			}
+		}
+	}
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
+
+// UserUsage 统计用户在 [from, to) 区间内每天和每个报告的用量
+func (s *UsageService) UserUsage(ctx context.Context, userID string, from, to time.Time) (*models.UsageResponse, error) {
+	daily, err := s.usageRepo.Daily(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := s.usageRepo.ByReport(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UsageResponse{
+		From:    from.Format(usageDateLayout),
+		To:      to.AddDate(0, 0, -1).Format(usageDateLayout),
+		Total:   sumUsage(daily),
+		Daily:   daily,
+		Reports: reports,
+	}, nil
+}
+
+// AllUsage 统计全部用户在 [from, to) 区间内每天和每个用户的用量
+func (s *UsageService) AllUsage(ctx context.Context, from, to time.Time) (*models.AdminUsageResponse, error) {
+	daily, err := s.usageRepo.Daily(ctx, "", from, to)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.usageRepo.ByUser(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AdminUsageResponse{
+		From:  from.Format(usageDateLayout),
+		To:    to.AddDate(0, 0, -1).Format(usageDateLayout),
+		Total: sumUsage(daily),
+		Daily: daily,
+		Users: users,
+	}, nil
+}
+
+func sumUsage(daily []models.UsageDaily) models.UsageTotals {
+	var total models.UsageTotals
+	for _, day := range daily {
+		total.Calls += day.Calls
+		total.FailedCalls += day.FailedCalls
+		total.PromptTokens += day.PromptTokens
+		total.CompletionTokens += day.CompletionTokens
+		total.TotalTokens += day.TotalTokens
+		total.Cost += day.Cost
+	}
+	return total
+}
+
+// ParseUsageRange 解析 YYYY-MM-DD 格式的起止日期（均包含），返回 [from, to) 区间
+// 未指定to时为今天，未指定from时为to之前的30天
+func ParseUsageRange(fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := today
+	if toStr != "" {
+		parsed, err := time.ParseInLocation(usageDateLayout, toStr, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: to格式应为YYYY-MM-DD", ErrInvalidUsageRange)
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultUsageRangeDays - 1))
+	if fromStr != "" {
+		parsed, err := time.ParseInLocation(usageDateLayout, fromStr, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: from格式应为YYYY-MM-DD", ErrInvalidUsageRange)
+		}
+		from = parsed
+	}
+
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from不能晚于to", ErrInvalidUsageRange)
+	}
+	if to.Sub(from) > maxUsageRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 统计区间不能超过%d天", ErrInvalidUsageRange, maxUsageRangeDays)
+	}
+	return from, to, nil
+}
+
+// ParseModelPricing 解析模型单价配置，格式为 "模型=输入单价/输出单价"，多个模型以逗号分隔，单价按每百万token计
+// 例如 "deepseek-chat=0.27/1.10,qwen2.5=0/0"
+func ParseModelPricing(spec string) (map[string]ModelPrice, error) {
+	pricing := make(map[string]ModelPrice)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		model, prices, ok := strings.Cut(item, "=")
+		input, output, ok2 := strings.Cut(prices, "/")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("模型单价格式错误: %s", item)
+		}
+		inputPrice, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		if err != nil || inputPrice < 0 {
+			return nil, fmt.Errorf("模型单价格式错误: %s", item)
+		}
+		outputPrice, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil || outputPrice < 0 {
+			return nil, fmt.Errorf("模型单价格式错误: %s", item)
+		}
+		pricing[strings.TrimSpace(model)] = ModelPrice{Input: inputPrice, Output: outputPrice}
+	}
+	return pricing, nil
+}
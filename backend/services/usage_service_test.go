@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/qujing226/pdf-enhancer/backend/llm"
+)
+
+func TestParseModelPricing(t *testing.T) {
+	pricing, err := ParseModelPricing(" deepseek-chat=0.27/1.10, gpt-4o=2.5/10 ,")
+	require.NoError(t, err)
+	assert.Equal(t, ModelPrice{Input: 0.27, Output: 1.10}, pricing["deepseek-chat"])
+	assert.Len(t, pricing, 2)
+
+	for _, spec := range []string{"deepseek-chat", "deepseek-chat=0.27", "=1/2", "m=a/1", "m=-1/1"} {
+		_, err := ParseModelPricing(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestUsageService_Cost(t *testing.T) {
+	s := NewUsageService(nil, map[string]ModelPrice{
+		"gpt-4o":      {Input: 2.5, Output: 10},
+		"gpt-4o-mini": {Input: 0.15, Output: 0.6},
+	})
+	usage := llm.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000}
+
+	assert.InDelta(t, 7.5, s.Cost("gpt-4o", usage), 1e-9)
+	// 带版本号的模型名称按最长前缀匹配
+	assert.InDelta(t, 0.45, s.Cost("gpt-4o-mini-2024-07-18", usage), 1e-9)
+	assert.Zero(t, s.Cost("qwen2.5", usage))
+}
+
+func TestParseUsageRange(t *testing.T) {
+	now := time.Date(2025, 4, 20, 15, 0, 0, 0, time.UTC)
+
+	from, to, err := ParseUsageRange("", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 22, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC), to)
+
+	from, to, err = ParseUsageRange("2025-04-01", "2025-04-01", now)
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, to.Sub(from))
+
+	for _, tc := range [][2]string{{"2025-04-02", "2025-04-01"}, {"2024-01-01", "2025-04-01"}, {"04/01/2025", ""}} {
+		_, _, err := ParseUsageRange(tc[0], tc[1], now)
+		assert.ErrorIs(t, err, ErrInvalidUsageRange, tc)
+	}
+}
@@ -83,6 +83,7 @@ func (s *UserService) CreateUser(name, email, password string) (*models.User, er
 		Name:         name,
 		Email:        email,
 		PasswordHash: hashedPassword,
+		Role:         models.UserRoleUser,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
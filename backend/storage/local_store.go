@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore 基于本地文件系统的对象存储实现，适合开发环境
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore 创建本地文件存储，目录不存在时自动创建
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put 写入本地文件，先写临时文件再重命名，避免读到写了一半的对象
+func (s *LocalStore) Put(_ context.Context, key string, reader io.Reader, _ int64, contentType string) (ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return ObjectInfo{}, fmt.Errorf("创建本地存储目录失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return ObjectInfo{}, fmt.Errorf("写入本地文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return ObjectInfo{}, fmt.Errorf("写入本地文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return ObjectInfo{}, fmt.Errorf("保存本地文件失败: %w", err)
+	}
+
+	info, err := s.Stat(context.Background(), key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if contentType != "" {
+		info.ContentType = contentType
+	}
+	return info, nil
+}
+
+// Get 打开本地文件
+func (s *LocalStore) Get(ctx context.Context, key string) (Object, ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	path, _ := s.path(key)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("打开本地文件失败: %w", wrapFSError(err))
+	}
+	return file, info, nil
+}
+
+// Stat 获取本地文件元信息
+func (s *LocalStore) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("获取本地文件信息失败: %w", wrapFSError(err))
+	}
+	return ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  contentTypeByKey(key),
+		ETag:         fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()),
+		LastModified: fi.ModTime(),
+	}, nil
+}
+
+// Remove 删除本地文件
+func (s *LocalStore) Remove(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除本地文件失败: %w", err)
+	}
+	return nil
+}
+
+// PresignedGetURL 本地存储不支持预签名URL
+func (s *LocalStore) PresignedGetURL(context.Context, string, time.Duration, url.Values) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// List 遍历存储目录，列出键以prefix开头的文件，跳过写入中的临时文件
+func (s *LocalStore) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, ObjectInfo{
+			Key:          key,
+			Size:         fi.Size(),
+			ContentType:  contentTypeByKey(key),
+			ETag:         fmt.Sprintf("%x-%x", fi.ModTime().UnixNano(), fi.Size()),
+			LastModified: fi.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("列出本地文件失败: %w", err)
+	}
+	return infos, nil
+}
+
+// path 将对象键映射为存储目录下的文件路径，拒绝越出存储目录的键
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") || clean == "/" {
+		return "", fmt.Errorf("无效的对象键: %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func wrapFSError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func contentTypeByKey(key string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore 基于内存的对象存储实现，适合测试和无外部依赖的本地运行
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+// Put 将对象写入内存
+func (s *MemoryStore) Put(_ context.Context, key string, reader io.Reader, _ int64, contentType string) (ObjectInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("读取对象内容失败: %w", err)
+	}
+	if contentType == "" {
+		contentType = contentTypeByKey(key)
+	}
+
+	sum := md5.Sum(data)
+	info := ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		ContentType:  contentType,
+		ETag:         hex.EncodeToString(sum[:]),
+		LastModified: time.Now(),
+	}
+
+	s.mu.Lock()
+	s.objects[key] = memoryObject{data: data, info: info}
+	s.mu.Unlock()
+	return info, nil
+}
+
+// Get 从内存读取对象
+func (s *MemoryStore) Get(_ context.Context, key string) (Object, ObjectInfo, error) {
+	s.mu.RLock()
+	obj, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	return nopCloser{bytes.NewReader(obj.data)}, obj.info, nil
+}
+
+// Stat 获取内存对象元信息
+func (s *MemoryStore) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	s.mu.RLock()
+	obj, ok := s.objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return obj.info, nil
+}
+
+// Remove 删除内存对象
+func (s *MemoryStore) Remove(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.objects, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// PresignedGetURL 内存存储不支持预签名URL
+func (s *MemoryStore) PresignedGetURL(context.Context, string, time.Duration, url.Values) (string, error) {
+	return "", ErrPresignNotSupported
+}
+
+// List 列出键以prefix开头的内存对象
+func (s *MemoryStore) List(_ context.Context, prefix string) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	var infos []ObjectInfo
+	for key, obj := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// MinIOStore 基于MinIO/S3的对象存储实现
+type MinIOStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewMinIOStore 创建MinIO存储，并确保存储桶存在
+func NewMinIOStore(ctx context.Context, config Config) (*MinIOStore, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MinIO客户端初始化失败: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("检查MinIO存储桶失败: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, config.Bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, fmt.Errorf("创建MinIO存储桶失败: %w", err)
+		}
+		log.Printf("已创建MinIO存储桶: %s", config.Bucket)
+	}
+
+	return &MinIOStore{client: client, bucket: config.Bucket}, nil
+}
+
+// Put 上传对象到MinIO
+func (s *MinIOStore) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (ObjectInfo, error) {
+	info, err := s.client.PutObject(ctx, s.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("上传对象到MinIO失败: %w", err)
+	}
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  contentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// Get 从MinIO读取对象
+func (s *MinIOStore) Get(ctx context.Context, key string) (Object, ObjectInfo, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("从MinIO获取对象失败: %w", wrapMinIOError(err))
+	}
+
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, ObjectInfo{}, fmt.Errorf("获取MinIO对象信息失败: %w", wrapMinIOError(err))
+	}
+
+	return object, toObjectInfo(info), nil
+}
+
+// Stat 获取MinIO对象元信息
+func (s *MinIOStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("获取MinIO对象信息失败: %w", wrapMinIOError(err))
+	}
+	return toObjectInfo(info), nil
+}
+
+// Remove 删除MinIO对象
+func (s *MinIOStore) Remove(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("删除MinIO对象失败: %w", err)
+	}
+	return nil
+}
+
+// PresignedGetURL 生成MinIO预签名下载链接
+func (s *MinIOStore) PresignedGetURL(ctx context.Context, key string, expiry time.Duration, params url.Values) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", fmt.Errorf("生成MinIO预签名URL失败: %w", err)
+	}
+	return u.String(), nil
+}
+
+// List 列出MinIO存储桶中键以prefix开头的对象
+func (s *MinIOStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("列出MinIO对象失败: %w", info.Err)
+		}
+		infos = append(infos, toObjectInfo(info))
+	}
+	return infos, nil
+}
+
+func toObjectInfo(info minio.ObjectInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}
+}
+
+// wrapMinIOError 将MinIO的对象不存在错误转换为ErrNotFound
+func wrapMinIOError(err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
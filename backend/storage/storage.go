@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"time"
+)
+
+// 存储相关错误
+var (
+	// ErrNotFound 对象不存在
+	ErrNotFound = errors.New("对象不存在")
+	// ErrPresignNotSupported 当前存储后端不支持预签名URL
+	ErrPresignNotSupported = errors.New("当前存储后端不支持预签名URL")
+)
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Object 已打开的对象，支持顺序读取和随机访问
+type Object interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// BlobStore 对象存储接口，屏蔽MinIO/S3、本地文件系统和内存等具体实现
+type BlobStore interface {
+	// Put 写入对象，size未知时传-1
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) (ObjectInfo, error)
+	// Get 打开对象，调用方负责关闭
+	Get(ctx context.Context, key string) (Object, ObjectInfo, error)
+	// Stat 获取对象元信息
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Remove 删除对象，对象不存在时不报错
+	Remove(ctx context.Context, key string) error
+	// PresignedGetURL 生成带有效期的下载链接
+	PresignedGetURL(ctx context.Context, key string, expiry time.Duration, params url.Values) (string, error)
+	// List 列出键以prefix开头的全部对象，按键排序
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// Config 对象存储配置
+type Config struct {
+	Backend string // minio、local 或 memory
+
+	// MinIO/S3 配置
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	Bucket    string
+
+	// 本地文件系统配置
+	LocalDir string
+}
+
+// 支持的存储后端
+const (
+	BackendMinIO  = "minio"
+	BackendLocal  = "local"
+	BackendMemory = "memory"
+)
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	content := "%PDF-1.4 测试内容"
+
+	info, err := store.Put(ctx, "a.pdf", strings.NewReader(content), int64(len(content)), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.NotEmpty(t, info.ETag)
+
+	obj, info, err := store.Get(ctx, "a.pdf")
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj)
+	require.NoError(t, err)
+	require.NoError(t, obj.Close())
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "application/pdf", info.ContentType)
+
+	_, err = store.Put(ctx, "b/c.pdf", strings.NewReader(content), int64(len(content)), "application/pdf")
+	require.NoError(t, err)
+	infos, err := store.List(ctx, "")
+	require.NoError(t, err)
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "a.pdf", infos[0].Key)
+		assert.Equal(t, "b/c.pdf", infos[1].Key)
+		assert.Equal(t, int64(len(content)), infos[1].Size)
+	}
+	infos, err = store.List(ctx, "b/")
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+	require.NoError(t, store.Remove(ctx, "b/c.pdf"))
+
+	require.NoError(t, store.Remove(ctx, "a.pdf"))
+	require.NoError(t, store.Remove(ctx, "a.pdf"), "重复删除不应报错")
+
+	_, err = store.Stat(ctx, "a.pdf")
+	assert.True(t, errors.Is(err, ErrNotFound), "期望ErrNotFound，实际: %v", err)
+
+	_, err = store.PresignedGetURL(ctx, "a.pdf", 0, nil)
+	assert.True(t, errors.Is(err, ErrPresignNotSupported))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testBlobStore(t, NewMemoryStore())
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	testBlobStore(t, store)
+
+	_, err = store.Put(context.Background(), "../escape.pdf", strings.NewReader("x"), 1, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContentDisposition 生成Content-Disposition响应头，disposition为inline或attachment
+// filename参数只含ASCII字符，不支持的字符替换为下划线，供旧客户端使用；
+// filename*参数按RFC 5987以UTF-8百分号编码，保留中文等完整文件名
+func ContentDisposition(disposition, filename string) string {
+	var fallback, encoded strings.Builder
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback.String(), encoded.String())
+}
+
+// isAttrChar 判断字节是否为RFC 5987中可不编码的attr-char
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// PDFFilename 由报告标题生成PDF文件名，标题已带.pdf扩展名时不再追加
+func PDFFilename(title string) string {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "report"
+	}
+	if strings.HasSuffix(strings.ToLower(title), ".pdf") {
+		return title
+	}
+	return title + ".pdf"
+}
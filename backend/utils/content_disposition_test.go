@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentDisposition(t *testing.T) {
+	assert.Equal(t, `inline; filename="report.pdf"; filename*=UTF-8''report.pdf`,
+		ContentDisposition("inline", "report.pdf"))
+	assert.Equal(t, `inline; filename="__Q1 _a_.pdf"; filename*=UTF-8''%E5%AD%A3%E6%8A%A5Q1%20%22a%22.pdf`,
+		ContentDisposition("inline", `季报Q1 "a".pdf`))
+}
+
+func TestPDFFilename(t *testing.T) {
+	assert.Equal(t, "季报.pdf", PDFFilename("季报"))
+	assert.Equal(t, "季报.PDF", PDFFilename("季报.PDF"))
+	assert.Equal(t, "report.pdf", PDFFilename("  "))
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Passage 报告中的一段文本及其所在页码（从1开始）
+type Passage struct {
+	Page int
+	Text string
+}
+
+// SplitPages 将 ParsePDFText 的结果按 PageSeparator 拆分为各页文本
+func SplitPages(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, PageSeparator)
+}
+
+// SplitPassages 将各页文本切分为不超过maxTokens的段落，并保留页码
+func SplitPassages(pages []string, maxTokens int) []Passage {
+	var passages []Passage
+	for i, page := range pages {
+		if strings.TrimSpace(page) == "" {
+			continue
+		}
+		parts := []string{page}
+		if maxTokens > 0 && EstimateTokens(page) > maxTokens {
+			parts = splitByTokens(page, maxTokens)
+		}
+		for _, part := range parts {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			passages = append(passages, Passage{Page: i + 1, Text: part})
+		}
+	}
+	return passages
+}
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// RankPassages 使用BM25按与问题的相关度对段落排序，返回最相关的topK个
+// 中文按相邻两字切分，其他语言按单词切分；没有任何段落命中时返回nil
+func RankPassages(question string, passages []Passage, topK int) []Passage {
+	queryTerms := uniqueTerms(searchTerms(question))
+	if len(queryTerms) == 0 || len(passages) == 0 {
+		return nil
+	}
+
+	// 统计每个段落的词频和文档频率
+	termFreqs := make([]map[string]int, len(passages))
+	docFreq := make(map[string]int)
+	var totalLen int
+	for i, passage := range passages {
+		terms := searchTerms(passage.Text)
+		totalLen += len(terms)
+		freq := make(map[string]int)
+		for _, term := range terms {
+			freq[term]++
+		}
+		for term := range freq {
+			docFreq[term]++
+		}
+		termFreqs[i] = freq
+	}
+	avgLen := float64(totalLen) / float64(len(passages))
+
+	type scored struct {
+		index int
+		score float64
+	}
+	var results []scored
+	n := float64(len(passages))
+	for i, freq := range termFreqs {
+		var score float64
+		docLen := 0
+		for _, c := range freq {
+			docLen += c
+		}
+		for _, term := range queryTerms {
+			tf := float64(freq[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(docLen)/avgLen))
+		}
+		if score > 0 {
+			results = append(results, scored{index: i, score: score})
+		}
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].score > results[b].score
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+
+	ranked := make([]Passage, 0, len(results))
+	for _, r := range results {
+		ranked = append(ranked, passages[r.index])
+	}
+	return ranked
+}
+
+// searchTerms 将文本切分为检索词：中日韩文字取相邻两字，其他文字按字母数字连续串切分并转为小写
+func searchTerms(text string) []string {
+	var terms []string
+	var word []rune
+	var prevCJK rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flushWord()
+			if prevCJK != 0 {
+				terms = append(terms, string([]rune{prevCJK, r}))
+			}
+			prevCJK = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			prevCJK = 0
+			word = append(word, r)
+		default:
+			prevCJK = 0
+			flushWord()
+		}
+	}
+	flushWord()
+	return terms
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitPassages(t *testing.T) {
+	pages := SplitPages("第一页内容" + PageSeparator + "  " + PageSeparator + "第三页内容")
+	passages := SplitPassages(pages, 100)
+	assert.Equal(t, []Passage{
+		{Page: 1, Text: "第一页内容"},
+		{Page: 3, Text: "第三页内容"},
+	}, passages)
+}
+
+func TestRankPassages(t *testing.T) {
+	passages := []Passage{
+		{Page: 1, Text: "本基金的投资目标是长期资本增值。"},
+		{Page: 2, Text: "2025年第一季度末，基金份额净值为1.2345元，本季度净值增长率为3.2%。"},
+		{Page: 3, Text: "Risk disclosure: the fund may invest in overseas markets."},
+	}
+
+	ranked := RankPassages("第一季度的基金净值是多少？", passages, 2)
+	if assert.NotEmpty(t, ranked) {
+		assert.Equal(t, 2, ranked[0].Page)
+	}
+
+	ranked = RankPassages("overseas RISK", passages, 2)
+	if assert.Len(t, ranked, 1) {
+		assert.Equal(t, 3, ranked[0].Page)
+	}
+
+	assert.Nil(t, RankPassages("完全无关的问题xyz", passages, 2))
+}
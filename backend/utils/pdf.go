@@ -1,42 +1,133 @@
 package utils
 
 import (
-	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
 
-// ParsePDFText 从 io.Reader 读取 PDF 内容并提取文本
+// PageSeparator 页面之间的分隔符（换页符），用于在拼接后的文本中保留页面边界
+const PageSeparator = "\f"
+
+// PageText 单页文本提取结果，Number从1开始，提取失败时Err不为nil且Text为空
+type PageText struct {
+	Number int
+	Text   string
+	Err    error
+}
+
+// ParsePDFText 从 io.Reader 读取 PDF 内容并提取文本，页面之间以 PageSeparator 分隔
+// 单页提取失败时该页内容为空，不影响其他页面
 func ParsePDFText(reader io.Reader) (string, error) {
-	// 读取全部内容到字节切片
-	content, err := ioutil.ReadAll(reader)
+	pages, err := ParsePDFPages(reader)
+	if err != nil {
+		return "", err
+	}
+	return JoinPageText(pages), nil
+}
+
+// ParsePDFPages 从 io.Reader 读取 PDF 内容并逐页提取文本
+// 只有文档整体无法解析时才返回错误，单页失败记录在对应 PageText.Err 中
+// 支持随机访问的 reader（如 *os.File、*bytes.Reader）直接解析，其他 reader 先写入临时文件，不在内存中缓存整个文件
+func ParsePDFPages(reader io.Reader) ([]PageText, error) {
+	if ra, ok := reader.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		size, err := ra.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("读取PDF内容失败: %v", err)
+		}
+		return ParsePDFPagesAt(ra, size)
+	}
+
+	file, size, err := SpoolToTempFile(reader)
 	if err != nil {
-		return "", fmt.Errorf("读取PDF内容失败: %v", err)
+		return nil, fmt.Errorf("读取PDF内容失败: %v", err)
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	return ParsePDFPagesAt(file, size)
+}
 
-	// 将字节切片转换为 io.ReadSeeker（PDF解析需要随机访问）
-	readSeeker := bytes.NewReader(content)
+// ParsePDFPagesAt 从支持随机访问的 reader 中按需读取并逐页提取文本，size 为文件总长度
+func ParsePDFPagesAt(reader io.ReaderAt, size int64) (pages []PageText, err error) {
+	// 解析库遇到损坏的文件可能panic，转换为错误返回
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("解析PDF失败: %v", r)
+		}
+	}()
 
 	// 创建PDF解析器
-	pdfReader, err := pdf.NewReader(readSeeker, int64(len(content)))
+	pdfReader, err := pdf.NewReader(reader, size)
 	if err != nil {
-		return "", fmt.Errorf("解析PDF失败: %v", err)
+		return nil, fmt.Errorf("解析PDF失败: %v", err)
 	}
 
-	// 提取所有页面文本
-	var fullText string
+	// 逐页提取文本
 	totalPages := pdfReader.NumPage()
+	pages = make([]PageText, 0, totalPages)
 	for pageNum := 1; pageNum <= totalPages; pageNum++ {
-		page := pdfReader.Page(pageNum)
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			return "", fmt.Errorf("提取第%d页文本失败: %v", pageNum, err)
+		text, err := extractPageText(pdfReader, pageNum)
+		pages = append(pages, PageText{Number: pageNum, Text: text, Err: err})
+	}
+
+	return pages, nil
+}
+
+// SpoolToTempFile 将 reader 的内容写入临时文件，返回定位到开头的文件和写入的字节数
+// 调用方负责关闭并删除该文件
+func SpoolToTempFile(reader io.Reader) (*os.File, int64, error) {
+	file, err := os.CreateTemp("", "pdf-upload-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	size, err := io.Copy(file, reader)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return file, size, nil
+}
+
+// extractPageText 提取单页文本，并将解析库的panic转换为该页的错误
+func extractPageText(pdfReader *pdf.Reader, pageNum int) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("提取第%d页文本失败: %v", pageNum, r)
 		}
-		fullText += text + "\n"
+	}()
+
+	page := pdfReader.Page(pageNum)
+	if page.V.IsNull() {
+		return "", fmt.Errorf("第%d页不存在或已损坏", pageNum)
+	}
+	text, err = page.GetPlainText(nil)
+	if err != nil {
+		return "", fmt.Errorf("提取第%d页文本失败: %v", pageNum, err)
 	}
+	return text, nil
+}
 
-	return fullText, nil
+// JoinPageText 将各页文本以 PageSeparator 拼接，提取失败的页面保留为空页以维持页码
+func JoinPageText(pages []PageText) string {
+	var fullText strings.Builder
+	for i, page := range pages {
+		if i > 0 {
+			fullText.WriteString(PageSeparator)
+		}
+		if page.Err == nil {
+			fullText.WriteString(page.Text)
+			fullText.WriteString("\n")
+		}
+	}
+	return fullText.String()
 }
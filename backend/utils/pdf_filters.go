@@ -0,0 +1,374 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hhrutter/lzw"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// filterAbbreviations 过滤器缩写对应的完整名称，缩写本用于内联图像，部分生成器也会写在流字典中
+var filterAbbreviations = map[string]string{
+	"Fl":  filter.Flate,
+	"LZW": filter.LZW,
+	"AHx": filter.ASCIIHex,
+	"A85": filter.ASCII85,
+	"RL":  filter.RunLength,
+	"DCT": filter.DCT,
+	"CCF": filter.CCITTFax,
+}
+
+// 解码后为图像数据的过滤器，只能作为最后一个过滤器，其输出不计入流大小
+var imageFilters = map[string]bool{
+	filter.DCT:      true,
+	filter.JPX:      true,
+	filter.CCITTFax: true,
+	filter.JBIG2:    true,
+}
+
+// cryptFilter 加密文件中的Crypt过滤器，之后的数据是密文，无法在这里解码
+const cryptFilter = "Crypt"
+
+// streamFilter 流的一个过滤器及其解码参数
+type streamFilter struct {
+	name  string
+	parms types.Dict
+}
+
+// intParm 读取整数解码参数，不存在时返回def
+func (f streamFilter) intParm(key string, def int) int {
+	if f.parms != nil {
+		if v := f.parms.IntEntry(key); v != nil {
+			return *v
+		}
+	}
+	return def
+}
+
+// predictorRowSize 使用预测器时每行数据的字节数，解码时需要按行分配缓冲区；不使用预测器时返回0
+func (f streamFilter) predictorRowSize() int64 {
+	if f.intParm("Predictor", 1) <= 1 {
+		return 0
+	}
+	size := int64(1)
+	for _, v := range []int{f.intParm("Colors", 1), f.intParm("BitsPerComponent", 8), f.intParm("Columns", 1)} {
+		if v <= 0 {
+			continue
+		}
+		if size > (1<<62)/int64(v) {
+			return 1 << 62
+		}
+		size *= int64(v)
+	}
+	return (size + 7) / 8
+}
+
+// parseStreamDict 解析窗口中最近一个对象的字典，窗口以stream关键字结尾；找不到对象头或无法解析时返回nil
+func parseStreamDict(window []byte) types.Dict {
+	i := bytes.LastIndex(window, []byte("obj"))
+	if i < 0 {
+		return nil
+	}
+	text := strings.TrimSuffix(strings.TrimRight(string(window[i+len("obj"):]), "\r\n"), "stream")
+	obj, err := model.ParseObject(&text)
+	if err != nil {
+		return nil
+	}
+	dict, _ := obj.(types.Dict)
+	return dict
+}
+
+// streamFilters 读取流字典中的过滤器链和对应的解码参数，过滤器不受支持时返回malformed校验错误
+func streamFilters(dict types.Dict) ([]streamFilter, error) {
+	var names types.Array
+	switch v := dict["Filter"].(type) {
+	case nil:
+		return nil, nil
+	case types.Name:
+		names = types.Array{v}
+	case types.Array:
+		names = v
+	default:
+		return nil, unsupportedFilterError(fmt.Sprint(v))
+	}
+
+	var parms types.Array
+	switch v := dict["DecodeParms"].(type) {
+	case types.Dict:
+		parms = types.Array{v}
+	case types.Array:
+		parms = v
+	}
+
+	filters := make([]streamFilter, 0, len(names))
+	for i, obj := range names {
+		name, ok := obj.(types.Name)
+		if !ok {
+			return nil, unsupportedFilterError(fmt.Sprint(obj))
+		}
+		f := streamFilter{name: name.Value()}
+		if full, ok := filterAbbreviations[f.name]; ok {
+			f.name = full
+		}
+		if i < len(parms) {
+			f.parms, _ = parms[i].(types.Dict)
+		}
+		switch {
+		case imageFilters[f.name] && i != len(names)-1:
+			return nil, &PDFValidationError{Reason: PDFRejectMalformed, Detail: fmt.Sprintf("图像过滤器%s之后不能再有其他过滤器", f.name)}
+		case !imageFilters[f.name] && f.name != cryptFilter && !isStreamFilter(f.name):
+			return nil, unsupportedFilterError(f.name)
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// isStreamFilter 判断是否为可以流式解码的过滤器
+func isStreamFilter(name string) bool {
+	switch name {
+	case filter.Flate, filter.LZW, filter.ASCIIHex, filter.ASCII85, filter.RunLength:
+		return true
+	}
+	return false
+}
+
+func unsupportedFilterError(name string) error {
+	return &PDFValidationError{Reason: PDFRejectMalformed, Detail: fmt.Sprintf("不支持的流过滤器: %s", name)}
+}
+
+// decodedSizes 依次应用过滤器链解码流数据并丢弃内容，返回每一级过滤器输出的字节数
+// 每一级最多输出limit字节，遇到图像过滤器或Crypt过滤器时停止；数据损坏时返回已解码的字节数
+func decodedSizes(r io.Reader, filters []streamFilter, limit int64) []int64 {
+	var counters []*countingReader
+	for _, f := range filters {
+		next := newFilterReader(r, f)
+		if next == nil {
+			break
+		}
+		counter := &countingReader{r: next, limit: limit}
+		counters = append(counters, counter)
+		r = counter
+	}
+	if len(counters) == 0 {
+		return nil
+	}
+
+	io.Copy(io.Discard, r)
+	sizes := make([]int64, len(counters))
+	for i, counter := range counters {
+		sizes[i] = counter.n
+	}
+	return sizes
+}
+
+// newFilterReader 创建解码单个过滤器的Reader，过滤器的输出无法解码时返回nil
+func newFilterReader(r io.Reader, f streamFilter) io.Reader {
+	switch f.name {
+	case filter.Flate:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return bytes.NewReader(nil)
+		}
+		return zr
+	case filter.LZW:
+		return lzw.NewReader(r, f.intParm("EarlyChange", 1) == 1)
+	case filter.ASCIIHex:
+		return &asciiHexReader{r: newByteReader(r)}
+	case filter.ASCII85:
+		return ascii85.NewDecoder(&terminatedReader{r: newByteReader(r), end: '~'})
+	case filter.RunLength:
+		return &runLengthReader{r: newByteReader(r)}
+	}
+	return nil
+}
+
+// byteReader 同时支持按字节读取的Reader
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// newByteReader 需要时为r加上缓冲，扫描文件时直接使用扫描器的缓冲，避免预读超出流数据
+func newByteReader(r io.Reader) byteReader {
+	if br, ok := r.(byteReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+// countingReader 统计读出的字节数，超过limit后不再读取
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	limit int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.n >= c.limit {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.limit-c.n {
+		p = p[:c.limit-c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// asciiHexReader 解码ASCIIHexDecode数据，忽略空白，读到结束符>为止
+type asciiHexReader struct {
+	r    byteReader
+	done bool
+}
+
+func (h *asciiHexReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && !h.done {
+		hi, ok, err := h.digit()
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			h.done = true
+			break
+		}
+		lo, ok, err := h.digit()
+		if err != nil {
+			return n, err
+		}
+		// 数字个数为奇数时最后一个数字按后面补0处理
+		h.done = !ok
+		p[n] = hi<<4 | lo
+		n++
+	}
+	if n == 0 && h.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// digit 读取下一个十六进制数字，遇到结束符或数据结束时ok为false
+func (h *asciiHexReader) digit() (value byte, ok bool, err error) {
+	for {
+		c, err := h.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		switch {
+		case c >= '0' && c <= '9':
+			return c - '0', true, nil
+		case c >= 'a' && c <= 'f':
+			return c - 'a' + 10, true, nil
+		case c >= 'A' && c <= 'F':
+			return c - 'A' + 10, true, nil
+		case c == '>':
+			return 0, false, nil
+		case !isPDFWhitespace(c):
+			return 0, false, errors.New("ASCIIHexDecode数据包含非法字符")
+		}
+	}
+}
+
+// terminatedReader 读取到结束符为止的数据，用于ASCII85Decode的~>结束标记
+type terminatedReader struct {
+	r    byteReader
+	end  byte
+	done bool
+}
+
+func (t *terminatedReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && !t.done {
+		c, err := t.r.ReadByte()
+		if err != nil {
+			t.done = true
+			if !errors.Is(err, io.EOF) {
+				return n, err
+			}
+			break
+		}
+		if c == t.end {
+			t.done = true
+			break
+		}
+		p[n] = c
+		n++
+	}
+	if n == 0 && t.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// runLengthReader 解码RunLengthDecode数据，读到结束标记128为止
+type runLengthReader struct {
+	r       byteReader
+	literal int // 剩余需要原样复制的字节数
+	repeat  int // 剩余需要重复输出value的次数
+	value   byte
+	done    bool
+}
+
+func (rl *runLengthReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		switch {
+		case rl.repeat > 0:
+			p[n] = rl.value
+			n++
+			rl.repeat--
+		case rl.literal > 0:
+			c, err := rl.r.ReadByte()
+			if err != nil {
+				rl.literal, rl.done = 0, true
+				continue
+			}
+			p[n] = c
+			n++
+			rl.literal--
+		case rl.done:
+			if n == 0 {
+				return 0, io.EOF
+			}
+			return n, nil
+		default:
+			length, err := rl.r.ReadByte()
+			if err != nil || length == 128 {
+				rl.done = true
+				continue
+			}
+			if length < 128 {
+				rl.literal = int(length) + 1
+				continue
+			}
+			if rl.value, err = rl.r.ReadByte(); err != nil {
+				rl.done = true
+				continue
+			}
+			rl.repeat = 257 - int(length)
+		}
+	}
+	return n, nil
+}
+
+// isPDFWhitespace 判断是否为PDF中的空白字符
+func isPDFWhitespace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PDFMetadata PDF文档信息字典和XMP元数据中提取的信息，两者都有时以文档信息字典为准
+type PDFMetadata struct {
+	Title        string
+	Author       string
+	Subject      string
+	Keywords     string
+	Creator      string // 创建文档的应用程序
+	Producer     string // 生成PDF的应用程序
+	CreationDate *time.Time
+	ModDate      *time.Time
+	PageCount    int
+	PageSizes    []PageSize // 去重后的页面尺寸，按首次出现的顺序
+	Version      string
+	Encrypted    bool
+}
+
+// PageSize 页面尺寸，单位为点（1/72英寸）
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// xmpMaxSize 读取XMP元数据流的最大字节数
+const xmpMaxSize = 1 << 20
+
+// extractPDFMetadata 从已解析的文档中提取元数据，单项提取失败时跳过该项
+func extractPDFMetadata(ctx *model.Context) *PDFMetadata {
+	xrt := ctx.XRefTable
+	metadata := &PDFMetadata{
+		Title:     xrt.Title,
+		Author:    xrt.Author,
+		Subject:   xrt.Subject,
+		Keywords:  xrt.Keywords,
+		Creator:   xrt.Creator,
+		Producer:  xrt.Producer,
+		PageCount: xrt.PageCount,
+		Version:   xrt.Version().String(),
+		Encrypted: xrt.Encrypt != nil,
+	}
+	metadata.CreationDate = pdfDate(xrt.CreationDate)
+	metadata.ModDate = pdfDate(xrt.ModDate)
+
+	if dims, err := xrt.PageDims(); err == nil {
+		seen := make(map[PageSize]bool)
+		for _, dim := range dims {
+			size := PageSize{Width: dim.Width, Height: dim.Height}
+			if !seen[size] {
+				seen[size] = true
+				metadata.PageSizes = append(metadata.PageSizes, size)
+			}
+		}
+	}
+
+	if xmp := readXMP(xrt); xmp != nil {
+		desc := xmp.RDF.Description
+		fill := func(target *string, value string) {
+			if *target == "" {
+				*target = strings.TrimSpace(value)
+			}
+		}
+		fill(&metadata.Title, strings.Join(desc.Title.Alt.Entries, " "))
+		fill(&metadata.Author, strings.Join(desc.Author.Seq.Entries, ", "))
+		fill(&metadata.Subject, strings.Join(desc.Subject.Alt.Entries, " "))
+		fill(&metadata.Keywords, desc.Keywords)
+		fill(&metadata.Creator, desc.Creator)
+		fill(&metadata.Producer, desc.Producer)
+		if t := time.Time(desc.CreationDate); metadata.CreationDate == nil && !t.IsZero() {
+			metadata.CreationDate = &t
+		}
+		if t := time.Time(desc.ModDate); metadata.ModDate == nil && !t.IsZero() {
+			metadata.ModDate = &t
+		}
+	}
+	return metadata
+}
+
+// pdfDate 解析PDF日期字符串（D:YYYYMMDDHHmmSSOHH'mm），无法解析时返回nil
+// XMP比文档信息字典更新时pdfcpu会以RFC3339格式回填日期，这里同样支持
+func pdfDate(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+	t, ok := types.DateTime(s, true)
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// readXMP 读取并解析文档目录中的XMP元数据流，不存在或无法解析时返回nil
+func readXMP(xrt *model.XRefTable) *model.XMPMeta {
+	catalog, err := xrt.Catalog()
+	if err != nil {
+		return nil
+	}
+	obj, found := catalog.Find("Metadata")
+	if !found {
+		return nil
+	}
+	sd, _, err := xrt.DereferenceStreamDict(obj)
+	if err != nil || sd == nil {
+		return nil
+	}
+	if sd.StreamLength != nil && *sd.StreamLength > xmpMaxSize {
+		return nil
+	}
+	if err := sd.Decode(); err != nil || len(sd.Content) > xmpMaxSize {
+		return nil
+	}
+
+	var xmp model.XMPMeta
+	if err := xml.Unmarshal(sd.Content, &xmp); err != nil {
+		return nil
+	}
+	return &xmp
+}
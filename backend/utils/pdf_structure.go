@@ -0,0 +1,620 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const (
+	objectReadSize    = 4 << 10  // 读取单个对象时第一次读取的字节数，对象不完整时逐步加大
+	maxObjectSize     = 1 << 20  // 单个对象（不含流数据）的最大字节数
+	maxStructStream   = 64 << 20 // 交叉引用流和对象流解码后的最大字节数
+	maxXRefSections   = 64       // 最多跟随的交叉引用段数
+	startXRefLookback = 1024     // 在文件末尾查找startxref的范围
+)
+
+// xrefEntry 交叉引用条目：未压缩的对象记录文件偏移，压缩的对象记录所在对象流和序号，已释放的对象free为true
+type xrefEntry struct {
+	offset int64
+	stream int
+	index  int
+	free   bool
+}
+
+// objectStream 解码后的对象流
+type objectStream struct {
+	number  int
+	data    []byte
+	offsets []int // 各个对象在data中的起始位置
+}
+
+// pdfStructure 只读取交叉引用表和用到的对象，不加载流内容，内存占用与文件大小无关
+// 用于超过完整解析大小的文件，只支持未加密的文档
+type pdfStructure struct {
+	reader  io.ReaderAt
+	size    int64
+	limits  PDFLimits
+	entries map[int]xrefEntry
+	trailer types.Dict
+	stream  *objectStream // 最近一次使用的对象流
+}
+
+// inspectPDFStructure 不加载流内容检查对象数和页数，并提取文档信息字典和页面尺寸
+func inspectPDFStructure(reader io.ReaderAt, size int64, limits PDFLimits, header []byte) (metadata *PDFMetadata, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			metadata, err = nil, &PDFValidationError{Reason: PDFRejectMalformed, Detail: fmt.Sprint(r)}
+		}
+	}()
+
+	s := &pdfStructure{reader: reader, size: size, limits: limits, entries: make(map[int]xrefEntry)}
+	if err := s.readXRef(); err != nil {
+		return nil, err
+	}
+	if _, ok := s.trailer["Encrypt"]; ok {
+		return nil, &PDFValidationError{
+			Reason: PDFRejectEncryptedTooLarge,
+			Detail: fmt.Sprintf("加密文件超过%d字节，无法在服务端解析", limits.MaxParseSize),
+		}
+	}
+
+	catalog, err := s.resolveDict(s.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	metadata = &PDFMetadata{Version: pdfHeaderVersion(header)}
+	if version, ok := catalog["Version"].(types.Name); ok && version.Value() > metadata.Version {
+		metadata.Version = version.Value()
+	}
+	if err := s.walkPages(catalog["Pages"], metadata); err != nil {
+		return nil, err
+	}
+	s.readInfo(metadata)
+	return metadata, nil
+}
+
+// readXRef 从startxref开始读取全部交叉引用段，较新的段中的条目优先
+func (s *pdfStructure) readXRef() error {
+	tail := make([]byte, min(s.size, startXRefLookback))
+	if _, err := s.reader.ReadAt(tail, s.size-int64(len(tail))); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("读取PDF内容失败: %w", err)
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return malformedStructure("找不到startxref")
+	}
+	fields := strings.Fields(string(tail[i+len("startxref"):]))
+	if len(fields) == 0 {
+		return malformedStructure("startxref缺少偏移")
+	}
+	offset, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return malformedStructure("startxref偏移无效")
+	}
+
+	pending := []int64{offset}
+	visited := make(map[int64]bool)
+	for len(pending) > 0 {
+		offset, pending = pending[0], pending[1:]
+		if visited[offset] {
+			continue
+		}
+		if len(visited) >= maxXRefSections {
+			return malformedStructure("交叉引用段过多")
+		}
+		visited[offset] = true
+
+		trailer, err := s.readXRefSection(offset)
+		if err != nil {
+			return err
+		}
+		if s.trailer == nil {
+			s.trailer = trailer
+		}
+		// 混合引用文件的XRefStm先于Prev生效
+		for _, key := range []string{"XRefStm", "Prev"} {
+			if next, ok := trailer[key].(types.Integer); ok {
+				pending = append(pending, int64(next.Value()))
+			}
+		}
+	}
+	return nil
+}
+
+// readXRefSection 读取一个交叉引用表或交叉引用流，返回其trailer字典
+func (s *pdfStructure) readXRefSection(offset int64) (types.Dict, error) {
+	if offset < 0 || offset >= s.size {
+		return nil, malformedStructure("交叉引用偏移超出文件范围")
+	}
+	lines := &lineReader{r: bufio.NewReader(io.NewSectionReader(s.reader, offset, s.size-offset)), offset: offset}
+	first, err := lines.next()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(first, "xref") {
+		return s.readXRefStream(offset)
+	}
+
+	for {
+		line, err := lines.next()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "trailer") {
+			obj, _, err := s.parseObjectAt(lines.start+int64(len("trailer")), false)
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(types.Dict)
+			if !ok {
+				return nil, malformedStructure("trailer不是字典")
+			}
+			return trailer, nil
+		}
+
+		var start, count int
+		if _, err := fmt.Sscanf(line, "%d %d", &start, &count); err != nil || start < 0 || count < 0 {
+			return nil, malformedStructure("交叉引用子段头无效")
+		}
+		for i := 0; i < count; i++ {
+			entry, err := lines.next()
+			if err != nil {
+				return nil, err
+			}
+			var objOffset int64
+			var gen int
+			var kind string
+			if _, err := fmt.Sscanf(entry, "%d %d %s", &objOffset, &gen, &kind); err != nil {
+				return nil, malformedStructure("交叉引用条目无效")
+			}
+			if err := s.addEntry(start+i, xrefEntry{offset: objOffset, free: kind != "n"}); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// readXRefStream 读取交叉引用流，流字典同时作为trailer
+func (s *pdfStructure) readXRefStream(offset int64) (types.Dict, error) {
+	obj, end, err := s.parseObjectAt(offset, true)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(types.Dict)
+	if !ok || dict.Type() == nil || *dict.Type() != "XRef" {
+		return nil, malformedStructure("交叉引用位置不是交叉引用表或交叉引用流")
+	}
+	data, err := s.streamData(dict, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var widths [3]int
+	w := dict.W()
+	if len(w) != 3 {
+		return nil, malformedStructure("交叉引用流的W无效")
+	}
+	for i, v := range w {
+		n, ok := v.(types.Integer)
+		if !ok || n.Value() < 0 || n.Value() > 8 {
+			return nil, malformedStructure("交叉引用流的W无效")
+		}
+		widths[i] = n.Value()
+	}
+	entrySize := widths[0] + widths[1] + widths[2]
+	if entrySize == 0 {
+		return nil, malformedStructure("交叉引用流的W无效")
+	}
+
+	index := dict.Index()
+	if index == nil {
+		size := dict.Size()
+		if size == nil {
+			return nil, malformedStructure("交叉引用流缺少Size")
+		}
+		index = types.Array{types.Integer(0), types.Integer(*size)}
+	}
+	for i := 0; i+1 < len(index); i += 2 {
+		start, ok1 := index[i].(types.Integer)
+		count, ok2 := index[i+1].(types.Integer)
+		if !ok1 || !ok2 || start.Value() < 0 || count.Value() < 0 {
+			return nil, malformedStructure("交叉引用流的Index无效")
+		}
+		for j := 0; j < count.Value() && len(data) >= entrySize; j++ {
+			row := data[:entrySize]
+			data = data[entrySize:]
+			kind := 1 // W的第一项为0时条目类型默认为1
+			if widths[0] > 0 {
+				kind = int(decodeBigEndian(row[:widths[0]]))
+			}
+			field2 := decodeBigEndian(row[widths[0] : widths[0]+widths[1]])
+			field3 := decodeBigEndian(row[widths[0]+widths[1]:])
+			var entry xrefEntry
+			switch kind {
+			case 0:
+				entry = xrefEntry{free: true}
+			case 1:
+				entry = xrefEntry{offset: field2}
+			case 2:
+				entry = xrefEntry{stream: int(field2), index: int(field3)}
+			default:
+				continue
+			}
+			if err := s.addEntry(start.Value()+j, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dict, nil
+}
+
+// addEntry 记录交叉引用条目，已由较新的交叉引用段记录的对象不覆盖；对象数与pdfcpu一致，包含已释放的对象
+func (s *pdfStructure) addEntry(objNr int, entry xrefEntry) error {
+	if _, ok := s.entries[objNr]; ok {
+		return nil
+	}
+	if s.limits.MaxObjects > 0 && len(s.entries) >= s.limits.MaxObjects {
+		return &PDFValidationError{
+			Reason: PDFRejectTooManyObjects,
+			Detail: fmt.Sprintf("对象数超过上限%d", s.limits.MaxObjects),
+		}
+	}
+	s.entries[objNr] = entry
+	return nil
+}
+
+// resolve 解析间接引用，其他对象原样返回；引用的对象不存在时返回nil
+func (s *pdfStructure) resolve(obj types.Object) (types.Object, error) {
+	ref, ok := obj.(types.IndirectRef)
+	if !ok {
+		return obj, nil
+	}
+	objNr := ref.ObjectNumber.Value()
+	entry, ok := s.entries[objNr]
+	if !ok || entry.free {
+		return nil, nil
+	}
+	if entry.stream == 0 {
+		obj, _, err := s.parseObjectAt(entry.offset, true)
+		return obj, err
+	}
+
+	stream, err := s.objectStream(entry.stream)
+	if err != nil {
+		return nil, err
+	}
+	if entry.index < 0 || entry.index >= len(stream.offsets) {
+		return nil, malformedStructure(fmt.Sprintf("对象%d不在对象流%d中", objNr, entry.stream))
+	}
+	end := len(stream.data)
+	if entry.index+1 < len(stream.offsets) {
+		end = stream.offsets[entry.index+1]
+	}
+	text := string(stream.data[stream.offsets[entry.index]:end])
+	obj, err = model.ParseObject(&text)
+	if err != nil {
+		return nil, malformedStructure(fmt.Sprintf("对象%d无法解析", objNr))
+	}
+	return obj, nil
+}
+
+// resolveDict 解析间接引用并要求结果为字典
+func (s *pdfStructure) resolveDict(obj types.Object) (types.Dict, error) {
+	obj, err := s.resolve(obj)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(types.Dict)
+	if !ok {
+		return nil, malformedStructure("对象不是字典")
+	}
+	return dict, nil
+}
+
+// objectStream 读取并解码对象流，最近一次使用的对象流会被缓存
+func (s *pdfStructure) objectStream(objNr int) (*objectStream, error) {
+	if s.stream != nil && s.stream.number == objNr {
+		return s.stream, nil
+	}
+	entry, ok := s.entries[objNr]
+	if !ok || entry.free || entry.stream != 0 {
+		return nil, malformedStructure(fmt.Sprintf("对象流%d不存在", objNr))
+	}
+	obj, end, err := s.parseObjectAt(entry.offset, true)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(types.Dict)
+	if !ok || dict.N() == nil || dict.First() == nil {
+		return nil, malformedStructure(fmt.Sprintf("对象%d不是对象流", objNr))
+	}
+	data, err := s.streamData(dict, end)
+	if err != nil {
+		return nil, err
+	}
+
+	n, first := *dict.N(), *dict.First()
+	if first < 0 || first > len(data) {
+		return nil, malformedStructure(fmt.Sprintf("对象流%d的First无效", objNr))
+	}
+	fields := strings.Fields(string(data[:first]))
+	if n < 0 || len(fields) < 2*n {
+		return nil, malformedStructure(fmt.Sprintf("对象流%d的对象表无效", objNr))
+	}
+	stream := &objectStream{number: objNr, data: data, offsets: make([]int, n)}
+	for i := range n {
+		offset, err := strconv.Atoi(fields[2*i+1])
+		if err != nil || offset < 0 || first+offset > len(data) {
+			return nil, malformedStructure(fmt.Sprintf("对象流%d的对象表无效", objNr))
+		}
+		stream.offsets[i] = first + offset
+	}
+	s.stream = stream
+	return stream, nil
+}
+
+// streamData 读取紧跟在流字典之后的流数据并按过滤器链解码，dictEnd为流字典结束的位置
+func (s *pdfStructure) streamData(dict types.Dict, dictEnd int64) ([]byte, error) {
+	lengthObj, err := s.resolve(dict["Length"])
+	if err != nil {
+		return nil, err
+	}
+	length, ok := lengthObj.(types.Integer)
+	if !ok || length.Value() < 0 || int64(length.Value()) > maxStructStream {
+		return nil, malformedStructure("流的Length无效")
+	}
+
+	// stream关键字之后是CRLF或LF
+	head := make([]byte, min(64, s.size-dictEnd))
+	if _, err := s.reader.ReadAt(head, dictEnd); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("读取PDF内容失败: %w", err)
+	}
+	i := bytes.Index(head, []byte("stream"))
+	if i < 0 {
+		return nil, malformedStructure("流字典之后缺少stream关键字")
+	}
+	start := dictEnd + int64(i+len("stream"))
+	if bytes.HasPrefix(head[i+len("stream"):], []byte("\r\n")) {
+		start += 2
+	} else {
+		start++
+	}
+	if start+int64(length.Value()) > s.size {
+		return nil, malformedStructure("流数据超出文件范围")
+	}
+
+	filters, err := streamFilters(dict)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = io.NewSectionReader(s.reader, start, int64(length.Value()))
+	for _, f := range filters {
+		parms := make(map[string]int)
+		for key := range f.parms {
+			if v := f.parms.IntEntry(key); v != nil {
+				parms[key] = *v
+			}
+		}
+		decoder, err := filter.NewFilter(f.name, parms)
+		if err != nil {
+			return nil, malformedStructure(fmt.Sprintf("不支持的流过滤器: %s", f.name))
+		}
+		// 流解码后的大小已由checkStreamSizes检查，这里不再限制
+		if r, err = decoder.Decode(r); err != nil {
+			return nil, malformedStructure(fmt.Sprintf("流解码失败: %v", err))
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxStructStream+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取PDF内容失败: %w", err)
+	}
+	if len(data) > maxStructStream {
+		return nil, malformedStructure("交叉引用流或对象流过大")
+	}
+	return data, nil
+}
+
+// parseObjectAt 解析offset处的对象，withHeader表示对象以"N G obj"开头；返回对象和对象结束的文件位置
+// 对象不完整时加大读取范围重试，最多读取maxObjectSize字节，不读取流数据
+func (s *pdfStructure) parseObjectAt(offset int64, withHeader bool) (types.Object, int64, error) {
+	if offset < 0 || offset >= s.size {
+		return nil, 0, malformedStructure("对象偏移超出文件范围")
+	}
+	for n := int64(objectReadSize); ; n *= 4 {
+		buf := make([]byte, min(n, maxObjectSize, s.size-offset))
+		if _, err := s.reader.ReadAt(buf, offset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("读取PDF内容失败: %w", err)
+		}
+
+		text := string(buf)
+		skipped := 0
+		if withHeader {
+			i := strings.Index(text, "obj")
+			if i < 0 || i > 32 {
+				return nil, 0, malformedStructure(fmt.Sprintf("偏移%d处不是对象", offset))
+			}
+			skipped = i + len("obj")
+			text = text[skipped:]
+		}
+		rest := text
+		obj, err := model.ParseObject(&rest)
+		if err == nil && obj != nil {
+			return obj, offset + int64(skipped+len(text)-len(rest)), nil
+		}
+		if int64(len(buf)) < n || n >= maxObjectSize {
+			return nil, 0, malformedStructure(fmt.Sprintf("偏移%d处的对象无法解析", offset))
+		}
+	}
+}
+
+// pageNode 遍历页面树时待处理的节点及其继承的属性
+type pageNode struct {
+	obj      types.Object
+	mediaBox types.Array
+	rotate   int
+}
+
+// walkPages 遍历页面树统计页数和页面尺寸，页数超过上限时立即返回
+func (s *pdfStructure) walkPages(root types.Object, metadata *PDFMetadata) error {
+	seen := make(map[PageSize]bool)
+	visited := make(map[int]bool)
+	stack := []pageNode{{obj: root}}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if ref, ok := node.obj.(types.IndirectRef); ok {
+			if visited[ref.ObjectNumber.Value()] {
+				return malformedStructure("页面树存在循环引用")
+			}
+			visited[ref.ObjectNumber.Value()] = true
+		}
+		dict, err := s.resolveDict(node.obj)
+		if err != nil {
+			return err
+		}
+		if box, err := s.resolve(dict["MediaBox"]); err == nil {
+			if box, ok := box.(types.Array); ok {
+				node.mediaBox = box
+			}
+		}
+		if rotate, err := s.resolve(dict["Rotate"]); err == nil {
+			if rotate, ok := rotate.(types.Integer); ok {
+				node.rotate = rotate.Value()
+			}
+		}
+
+		if dict.Type() == nil || *dict.Type() != "Page" {
+			kids, err := s.resolve(dict["Kids"])
+			if err != nil {
+				return err
+			}
+			if kids, ok := kids.(types.Array); ok {
+				// 逆序入栈，保证按文档顺序访问页面
+				for i := len(kids) - 1; i >= 0; i-- {
+					stack = append(stack, pageNode{obj: kids[i], mediaBox: node.mediaBox, rotate: node.rotate})
+				}
+				continue
+			}
+		}
+
+		metadata.PageCount++
+		if s.limits.MaxPages > 0 && metadata.PageCount > s.limits.MaxPages {
+			return &PDFValidationError{
+				Reason: PDFRejectTooManyPages,
+				Detail: fmt.Sprintf("页数超过上限%d", s.limits.MaxPages),
+			}
+		}
+		if rect := types.RectForArray(node.mediaBox); rect != nil {
+			size := PageSize{Width: rect.Width(), Height: rect.Height()}
+			if node.rotate%180 != 0 {
+				size.Width, size.Height = size.Height, size.Width
+			}
+			if !seen[size] {
+				seen[size] = true
+				metadata.PageSizes = append(metadata.PageSizes, size)
+			}
+		}
+	}
+	return nil
+}
+
+// readInfo 读取文档信息字典，单项读取失败时跳过该项
+func (s *pdfStructure) readInfo(metadata *PDFMetadata) {
+	info, err := s.resolveDict(s.trailer["Info"])
+	if err != nil {
+		return
+	}
+	text := func(key string) string {
+		obj, err := s.resolve(info[key])
+		if err != nil || obj == nil {
+			return ""
+		}
+		value, err := types.StringOrHexLiteral(obj)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(*value)
+	}
+	metadata.Title = text("Title")
+	metadata.Author = text("Author")
+	metadata.Subject = text("Subject")
+	metadata.Keywords = text("Keywords")
+	metadata.Creator = text("Creator")
+	metadata.Producer = text("Producer")
+	metadata.CreationDate = pdfDate(text("CreationDate"))
+	metadata.ModDate = pdfDate(text("ModDate"))
+}
+
+// pdfHeaderVersion 从文件头读取PDF版本号，如 "1.7"
+func pdfHeaderVersion(header []byte) string {
+	i := bytes.Index(header, []byte("%PDF-"))
+	if i < 0 {
+		return ""
+	}
+	version := header[i+len("%PDF-"):]
+	end := 0
+	for end < len(version) && end < 8 && (version[end] == '.' || version[end] >= '0' && version[end] <= '9') {
+		end++
+	}
+	return string(version[:end])
+}
+
+// lineReader 按行读取交叉引用表并记录文件位置，兼容CR、LF和CRLF换行
+type lineReader struct {
+	r      *bufio.Reader
+	offset int64 // 下一个待读取字节的位置
+	start  int64 // 最近返回的行的起始位置
+}
+
+// next 返回下一个非空行，行内容超过maxObjectSize时视为结构损坏
+func (l *lineReader) next() (string, error) {
+	var line []byte
+	for {
+		c, err := l.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", malformedStructure("交叉引用表不完整")
+		}
+		l.offset++
+		if c == '\r' || c == '\n' {
+			if len(line) > 0 {
+				return string(bytes.TrimSpace(line)), nil
+			}
+			continue
+		}
+		if len(line) == 0 {
+			if isPDFWhitespace(c) {
+				continue
+			}
+			l.start = l.offset - 1
+		}
+		if len(line) >= maxObjectSize {
+			return "", malformedStructure("交叉引用表的行过长")
+		}
+		line = append(line, c)
+	}
+}
+
+// decodeBigEndian 将大端字节序的字节解码为整数
+func decodeBigEndian(b []byte) int64 {
+	var v int64
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func malformedStructure(detail string) error {
+	return &PDFValidationError{Reason: PDFRejectMalformed, Detail: "文档结构损坏: " + detail}
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,3 +32,49 @@ func TestParsePDFText_ValidPDFFile(t *testing.T) {
 	
 	t.Logf("提取的文本内容: %s", text)
 }
+
+func TestParsePDFPages_ValidPDFFile(t *testing.T) {
+	file, err := os.Open(filepath.Join("..", "download.pdf"))
+	if err != nil {
+		t.Fatalf("打开PDF文件失败: %v", err)
+	}
+	defer file.Close()
+
+	pages, err := ParsePDFPages(file)
+	if err != nil {
+		t.Fatalf("解析PDF时出错: %v", err)
+	}
+
+	assert.NotEmpty(t, pages, "期望解析出至少一页")
+	for i, page := range pages {
+		assert.Equal(t, i+1, page.Number, "页码应从1开始连续编号")
+	}
+	assert.Equal(t, len(pages), len(SplitPages(JoinPageText(pages))), "拼接后的文本应保留页面边界")
+}
+
+func TestParsePDFPages_InvalidFile(t *testing.T) {
+	_, err := ParsePDFPages(strings.NewReader("这不是PDF文件"))
+	assert.Error(t, err)
+}
+
+func TestParsePDFPages_NonSeekableReader(t *testing.T) {
+	file, err := os.Open(filepath.Join("..", "download.pdf"))
+	if err != nil {
+		t.Fatalf("打开PDF文件失败: %v", err)
+	}
+	defer file.Close()
+
+	direct, err := ParsePDFPages(file)
+	if err != nil {
+		t.Fatalf("解析PDF时出错: %v", err)
+	}
+
+	// io.MultiReader 不支持随机访问，会先写入临时文件再解析
+	_, err = file.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	spooled, err := ParsePDFPages(io.MultiReader(file))
+	if err != nil {
+		t.Fatalf("解析PDF时出错: %v", err)
+	}
+	assert.Equal(t, JoinPageText(direct), JoinPageText(spooled))
+}
@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func init() {
+	// 不读取用户目录下的pdfcpu配置，配置目录异常时pdfcpu会直接退出进程
+	api.DisableConfigDir()
+}
+
+// PDF校验失败的原因
+const (
+	PDFRejectNotPDF                = "not_pdf"
+	PDFRejectMalformed             = "malformed"
+	PDFRejectEncrypted             = "encrypted" // 需要密码才能打开
+	PDFRejectWrongPassword         = "wrong_password"
+	PDFRejectUnsupportedEncryption = "unsupported_encryption"
+	PDFRejectTooManyPages          = "too_many_pages"
+	PDFRejectTooManyObjects        = "too_many_objects"
+	PDFRejectStreamTooLarge        = "stream_too_large"
+	PDFRejectEncryptedTooLarge     = "encrypted_too_large" // 加密文件超过完整解析的大小限制，无法解密
+)
+
+// PDFValidationError 上传的文件不是合法PDF或超出安全限制
+type PDFValidationError struct {
+	Reason string // 见 PDFReject* 常量
+	Detail string
+}
+
+func (e *PDFValidationError) Error() string {
+	return fmt.Sprintf("PDF校验失败(%s): %s", e.Reason, e.Detail)
+}
+
+// PDFLimits 上传PDF的安全限制，为0的字段表示不限制
+type PDFLimits struct {
+	MaxPages           int
+	MaxObjects         int
+	MaxStreamSize      int64 // 单个流解压后的最大字节数
+	MaxTotalStreamSize int64 // 全部流解压后的最大总字节数
+	MaxParseSize       int64 // 交给pdfcpu完整解析的最大文件字节数，pdfcpu会把全部流数据读入内存
+}
+
+// pdfHeaderSearchSize PDF头允许出现的范围，规范允许文件头之前存在少量其他字节
+const pdfHeaderSearchSize = 1024
+
+// ValidatePDF 校验文件是否为合法PDF并且没有超出限制
+func ValidatePDF(reader io.ReaderAt, size int64, limits PDFLimits) error {
+	_, err := InspectPDF(reader, size, limits, "")
+	return err
+}
+
+// IsPDFLocked 判断错误是否表示PDF设置了打开密码而调用方没有提供密码
+func IsPDFLocked(err error) bool {
+	var validationErr *PDFValidationError
+	return errors.As(err, &validationErr) && validationErr.Reason == PDFRejectEncrypted
+}
+
+// InspectPDF 校验文件是否为合法PDF并且没有超出限制，通过后返回文档元数据；password为加密文件的打开密码，可为空
+// 依次检查文件头、流解压后的大小、文档结构、页数和对象数；解压大小在结构解析之前检查，避免解析时被压缩炸弹耗尽内存
+// 加密文件的流是密文，这里的解压大小检查对其无效，调用方必须在解析解密后的文件前调用CheckPDFStreamSizes
+// 超过MaxParseSize的文件不交给pdfcpu，只读取交叉引用表和页面树，不提取XMP元数据；这类文件无法解密，加密时直接拒绝
+func InspectPDF(reader io.ReaderAt, size int64, limits PDFLimits, password string) (*PDFMetadata, error) {
+	header := make([]byte, min(size, pdfHeaderSearchSize))
+	if _, err := reader.ReadAt(header, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("读取PDF内容失败: %w", err)
+	}
+	if !bytes.Contains(header, []byte("%PDF-")) {
+		return nil, &PDFValidationError{Reason: PDFRejectNotPDF, Detail: "文件头不是PDF格式"}
+	}
+
+	if err := checkStreamSizes(io.NewSectionReader(reader, 0, size), limits); err != nil {
+		return nil, err
+	}
+	if limits.MaxParseSize > 0 && size > limits.MaxParseSize {
+		return inspectPDFStructure(reader, size, limits, header)
+	}
+
+	ctx, err := readPDFContext(io.NewSectionReader(reader, 0, size), password)
+	if err != nil {
+		return nil, err
+	}
+
+	if limits.MaxObjects > 0 && len(ctx.XRefTable.Table) > limits.MaxObjects {
+		return nil, &PDFValidationError{
+			Reason: PDFRejectTooManyObjects,
+			Detail: fmt.Sprintf("对象数%d超过上限%d", len(ctx.XRefTable.Table), limits.MaxObjects),
+		}
+	}
+	if limits.MaxPages > 0 && ctx.PageCount > limits.MaxPages {
+		return nil, &PDFValidationError{
+			Reason: PDFRejectTooManyPages,
+			Detail: fmt.Sprintf("页数%d超过上限%d", ctx.PageCount, limits.MaxPages),
+		}
+	}
+	return extractPDFMetadata(ctx), nil
+}
+
+// readPDFContext 使用pdfcpu解析并校验文档结构，并将解析库的panic转换为校验错误
+func readPDFContext(rs io.ReadSeeker, password string) (ctx *model.Context, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctx, err = nil, &PDFValidationError{Reason: PDFRejectMalformed, Detail: fmt.Sprint(r)}
+		}
+	}()
+
+	ctx, err = api.ReadAndValidate(rs, pdfConfiguration(password))
+	if err != nil {
+		return nil, pdfReadError(err, password)
+	}
+	return ctx, nil
+}
+
+// pdfConfiguration 创建pdfcpu配置，密码同时作为用户密码和所有者密码尝试
+func pdfConfiguration(password string) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = password
+	conf.OwnerPW = password
+	return conf
+}
+
+// pdfReadError 将pdfcpu的解析错误转换为校验错误
+func pdfReadError(err error, password string) error {
+	switch {
+	case errors.Is(err, pdfcpu.ErrWrongPassword) && password == "":
+		return &PDFValidationError{Reason: PDFRejectEncrypted, Detail: "文件已加密，需要提供密码"}
+	case errors.Is(err, pdfcpu.ErrWrongPassword):
+		return &PDFValidationError{Reason: PDFRejectWrongPassword, Detail: "PDF密码错误"}
+	case errors.Is(err, pdfcpu.ErrUnknownEncryption):
+		return &PDFValidationError{Reason: PDFRejectUnsupportedEncryption, Detail: "不支持的PDF加密方式"}
+	}
+	return &PDFValidationError{Reason: PDFRejectMalformed, Detail: err.Error()}
+}
+
+// DecryptPDFToTempFile 解密PDF并写入临时文件，返回定位到开头的文件和文件大小，调用方负责关闭并删除该文件
+// 只设置了权限密码的文件password可为空；解密需要pdfcpu完整解析，超过MaxParseSize的文件直接拒绝
+func DecryptPDFToTempFile(reader io.ReaderAt, size int64, password string, limits PDFLimits) (*os.File, int64, error) {
+	if limits.MaxParseSize > 0 && size > limits.MaxParseSize {
+		return nil, 0, &PDFValidationError{
+			Reason: PDFRejectEncryptedTooLarge,
+			Detail: fmt.Sprintf("加密文件超过%d字节，无法在服务端解析", limits.MaxParseSize),
+		}
+	}
+	file, err := os.CreateTemp("", "pdf-decrypted-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	written, err := decryptPDF(reader, size, password, file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+	return file, written, nil
+}
+
+// decryptPDF 将解密后的PDF写入file，返回写入的字节数，并将解析库的panic转换为校验错误
+func decryptPDF(reader io.ReaderAt, size int64, password string, file *os.File) (written int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			written, err = 0, &PDFValidationError{Reason: PDFRejectMalformed, Detail: fmt.Sprint(r)}
+		}
+	}()
+
+	if err := api.Decrypt(io.NewSectionReader(reader, 0, size), file, pdfConfiguration(password)); err != nil {
+		return 0, pdfReadError(err, password)
+	}
+	written, err = file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return written, nil
+}
+
+// CheckPDFStreamSizes 检查PDF中的流解码后的大小，超出限制时返回stream_too_large校验错误
+// 用于解密后的文件：加密文件的流是密文，InspectPDF无法在解密前检查
+func CheckPDFStreamSizes(reader io.ReaderAt, size int64, limits PDFLimits) error {
+	return checkStreamSizes(io.NewSectionReader(reader, 0, size), limits)
+}
+
+// streamDictLookback 解析流字典时向前查看的字节数
+const streamDictLookback = 4096
+
+// checkStreamSizes 顺序扫描文件中的流，按流字典声明的过滤器链逐级解码并计算每一级输出的大小
+// 只做有上限的解码并丢弃内容，不依赖文档结构，因此也能覆盖交叉引用流和对象流；不支持的过滤器按格式错误拒绝
+func checkStreamSizes(reader io.Reader, limits PDFLimits) error {
+	if limits.MaxStreamSize <= 0 && limits.MaxTotalStreamSize <= 0 {
+		return nil
+	}
+
+	br := bufio.NewReader(reader)
+	window := make([]byte, 0, 2*streamDictLookback)
+	var total int64
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取PDF内容失败: %w", err)
+		}
+
+		if len(window) == cap(window) {
+			window = append(window[:0], window[streamDictLookback:]...)
+		}
+		window = append(window, b)
+		if !isStreamStart(window) {
+			continue
+		}
+
+		// stream关键字之后是CRLF或LF，随后才是流数据
+		if b == '\r' {
+			if next, err := br.ReadByte(); err == nil && next != '\n' {
+				br.UnreadByte()
+			}
+		}
+		dict := parseStreamDict(window)
+		window = window[:0]
+		if dict == nil {
+			continue
+		}
+		filters, err := streamFilters(dict)
+		if err != nil {
+			return err
+		}
+		for _, f := range filters {
+			if limits.MaxStreamSize > 0 && f.predictorRowSize() > limits.MaxStreamSize {
+				return &PDFValidationError{
+					Reason: PDFRejectStreamTooLarge,
+					Detail: fmt.Sprintf("预测器的行宽超过%d字节", limits.MaxStreamSize),
+				}
+			}
+		}
+
+		// 过滤器链的每一级输出都计入单个流和全部流的大小
+		limit := limits.MaxStreamSize
+		if limits.MaxTotalStreamSize > 0 && (limit <= 0 || limits.MaxTotalStreamSize-total < limit) {
+			limit = limits.MaxTotalStreamSize - total
+		}
+		for _, n := range decodedSizes(br, filters, limit+1) {
+			total += n
+			if limits.MaxStreamSize > 0 && n > limits.MaxStreamSize {
+				return &PDFValidationError{
+					Reason: PDFRejectStreamTooLarge,
+					Detail: fmt.Sprintf("存在解压后超过%d字节的流", limits.MaxStreamSize),
+				}
+			}
+			if limits.MaxTotalStreamSize > 0 && total > limits.MaxTotalStreamSize {
+				return &PDFValidationError{
+					Reason: PDFRejectStreamTooLarge,
+					Detail: fmt.Sprintf("全部流解压后超过%d字节", limits.MaxTotalStreamSize),
+				}
+			}
+		}
+	}
+}
+
+// isStreamStart 判断窗口是否以 "stream" 关键字加换行结尾，排除 "endstream"
+func isStreamStart(window []byte) bool {
+	n := len(window)
+	if n < 7 || (window[n-1] != '\n' && window[n-1] != '\r') {
+		return false
+	}
+	if !bytes.Equal(window[n-7:n-1], []byte("stream")) {
+		return false
+	}
+	return n == 7 || !isRegularChar(window[n-8])
+}
+
+// isRegularChar 判断是否为PDF中的普通字符（非空白、非分隔符）
+func isRegularChar(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
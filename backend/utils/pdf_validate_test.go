@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hhrutter/lzw"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPDFLimits = PDFLimits{
+	MaxPages:           1000,
+	MaxObjects:         100000,
+	MaxStreamSize:      1 << 20,
+	MaxTotalStreamSize: 4 << 20,
+}
+
+func validationReason(err error) string {
+	var validationErr *PDFValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Reason
+	}
+	return ""
+}
+
+func TestValidatePDF_ValidFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "download.pdf"))
+	require.NoError(t, err)
+
+	assert.NoError(t, ValidatePDF(bytes.NewReader(data), int64(len(data)), PDFLimits{}))
+
+	pages, err := ParsePDFPages(bytes.NewReader(data))
+	require.NoError(t, err)
+	err = ValidatePDF(bytes.NewReader(data), int64(len(data)), PDFLimits{MaxPages: len(pages) - 1})
+	assert.Equal(t, PDFRejectTooManyPages, validationReason(err))
+}
+
+func TestValidatePDF_NotPDF(t *testing.T) {
+	data := []byte("PK\x03\x04 这是一个zip文件")
+	err := ValidatePDF(bytes.NewReader(data), int64(len(data)), testPDFLimits)
+	assert.Equal(t, PDFRejectNotPDF, validationReason(err))
+
+	data = []byte("%PDF-1.4\n这不是完整的PDF")
+	err = ValidatePDF(bytes.NewReader(data), int64(len(data)), testPDFLimits)
+	assert.Equal(t, PDFRejectMalformed, validationReason(err))
+}
+
+func TestValidatePDF_StreamBomb(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, err := zw.Write(make([]byte, 8<<20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var pdf bytes.Buffer
+	fmt.Fprintf(&pdf, "%%PDF-1.4\n1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	err = ValidatePDF(bytes.NewReader(pdf.Bytes()), int64(pdf.Len()), testPDFLimits)
+	assert.Equal(t, PDFRejectStreamTooLarge, validationReason(err))
+}
+
+// streamPDF 构造只包含一个流对象的PDF，dict为流字典中除Length以外的内容
+func streamPDF(dict string, data []byte) []byte {
+	var pdf bytes.Buffer
+	fmt.Fprintf(&pdf, "%%PDF-1.4\n1 0 obj\n<< /Length %d %s >>\nstream\n", len(data), dict)
+	pdf.Write(data)
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestValidatePDF_FilterChainBomb(t *testing.T) {
+	var lzwData bytes.Buffer
+	lw := lzw.NewWriter(&lzwData, true)
+	_, err := lw.Write(make([]byte, 8<<20))
+	require.NoError(t, err)
+	require.NoError(t, lw.Close())
+
+	var flateData bytes.Buffer
+	zw := zlib.NewWriter(&flateData)
+	_, err = zw.Write(make([]byte, 8<<20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	hexData := []byte(hex.EncodeToString(flateData.Bytes()) + ">")
+	var a85Data bytes.Buffer
+	aw := ascii85.NewEncoder(&a85Data)
+	_, err = aw.Write(flateData.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, aw.Close())
+	a85Data.WriteString("~>")
+
+	tests := []struct {
+		name string
+		pdf  []byte
+	}{
+		{"lzw", streamPDF("/Filter /LZWDecode", lzwData.Bytes())},
+		{"lzw abbreviation", streamPDF("/Filter /LZW /DecodeParms << /EarlyChange 1 >>", lzwData.Bytes())},
+		{"hex then flate", streamPDF("/Filter [/ASCIIHexDecode /FlateDecode]", hexData)},
+		{"ascii85 then flate", streamPDF("/Filter [/A85 /Fl] /DecodeParms [null << /Predictor 1 >>]", a85Data.Bytes())},
+		{"predictor row", streamPDF("/Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 100000000 >>", flateData.Bytes()[:16])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePDF(bytes.NewReader(tt.pdf), int64(len(tt.pdf)), testPDFLimits)
+			assert.Equal(t, PDFRejectStreamTooLarge, validationReason(err))
+		})
+	}
+
+	// 每一级过滤器的输出都计入总大小：十六进制编码本身不超过单个流的限制，但与解压后的大小合计超过总限制
+	var small bytes.Buffer
+	zw = zlib.NewWriter(&small)
+	_, err = zw.Write(make([]byte, 900<<10))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	pdf := streamPDF("/Filter [/AHx /Fl]", []byte(hex.EncodeToString(small.Bytes())+">"))
+	limits := PDFLimits{MaxStreamSize: 1 << 20, MaxTotalStreamSize: 900<<10 + int64(small.Len()) - 1}
+	err = CheckPDFStreamSizes(bytes.NewReader(pdf), int64(len(pdf)), limits)
+	assert.Equal(t, PDFRejectStreamTooLarge, validationReason(err))
+	limits.MaxTotalStreamSize++
+	assert.NoError(t, CheckPDFStreamSizes(bytes.NewReader(pdf), int64(len(pdf)), limits))
+
+	pdf = streamPDF("/Filter /JBIG2Decode /Foo 1", []byte("data"))
+	assert.NoError(t, CheckPDFStreamSizes(bytes.NewReader(pdf), int64(len(pdf)), testPDFLimits))
+	for _, dict := range []string{"/Filter /BrotliDecode", "/Filter [/DCTDecode /FlateDecode]", "/Filter 5 0 R"} {
+		pdf = streamPDF(dict, []byte("data"))
+		err = CheckPDFStreamSizes(bytes.NewReader(pdf), int64(len(pdf)), testPDFLimits)
+		assert.Equal(t, PDFRejectMalformed, validationReason(err), dict)
+	}
+}
+
+func TestInspectPDF_Metadata(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "download.pdf"))
+	require.NoError(t, err)
+
+	metadata, err := InspectPDF(bytes.NewReader(data), int64(len(data)), PDFLimits{}, "")
+	require.NoError(t, err)
+	pages, err := ParsePDFPages(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, len(pages), metadata.PageCount)
+	assert.NotEmpty(t, metadata.Version)
+	assert.NotEmpty(t, metadata.PageSizes)
+	assert.False(t, metadata.Encrypted)
+	t.Logf("元数据: %+v", metadata)
+}
+
+func TestInspectPDF_StructureOnly(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "download.pdf"))
+	require.NoError(t, err)
+	var optimized bytes.Buffer
+	require.NoError(t, api.Optimize(bytes.NewReader(data), &optimized, model.NewDefaultConfiguration()))
+	require.Contains(t, optimized.String(), "/ObjStm")
+
+	// 交叉引用表和交叉引用流+对象流两种文件，只读结构时的页数和页面尺寸与pdfcpu完整解析一致
+	for name, file := range map[string][]byte{"xref table": data, "xref stream": optimized.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			full, err := InspectPDF(bytes.NewReader(file), int64(len(file)), PDFLimits{}, "")
+			require.NoError(t, err)
+			structure, err := InspectPDF(bytes.NewReader(file), int64(len(file)), PDFLimits{MaxParseSize: 1}, "")
+			require.NoError(t, err)
+			assert.Equal(t, full.PageCount, structure.PageCount)
+			assert.Equal(t, full.PageSizes, structure.PageSizes)
+			assert.Equal(t, full.Version, structure.Version)
+
+			_, err = InspectPDF(bytes.NewReader(file), int64(len(file)), PDFLimits{MaxParseSize: 1, MaxPages: full.PageCount - 1}, "")
+			assert.Equal(t, PDFRejectTooManyPages, validationReason(err))
+			_, err = InspectPDF(bytes.NewReader(file), int64(len(file)), PDFLimits{MaxParseSize: 1, MaxObjects: 10}, "")
+			assert.Equal(t, PDFRejectTooManyObjects, validationReason(err))
+		})
+	}
+
+	conf := model.NewAESConfiguration("user-secret", "owner-secret", 256)
+	var encrypted bytes.Buffer
+	require.NoError(t, api.Encrypt(bytes.NewReader(data), &encrypted, conf))
+	limits := PDFLimits{MaxParseSize: 1}
+	_, err = InspectPDF(bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len()), limits, "user-secret")
+	assert.Equal(t, PDFRejectEncryptedTooLarge, validationReason(err))
+	_, _, err = DecryptPDFToTempFile(bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len()), "user-secret", limits)
+	assert.Equal(t, PDFRejectEncryptedTooLarge, validationReason(err))
+}
+
+// largePDF 构造包含pages个页面的PDF，第一页的内容流是size字节未压缩的数据
+func largePDF(pages int, size int) []byte {
+	var pdf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, pdf.Len())
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	pdf.WriteString("%PDF-1.7\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+i))
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Count %d /MediaBox [0 0 595 842] /Kids [%s] >>", pages, strings.Join(kids, " ")))
+	object("<< /Title (Large Report) >>")
+	offsets = append(offsets, pdf.Len())
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d >>\nstream\n", size)
+	pdf.Write(bytes.Repeat([]byte("0 0 m\n"), size/6))
+	pdf.Write(make([]byte, size%6))
+	pdf.WriteString("\nendstream\nendobj\n")
+	for range pages {
+		object("<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>")
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return pdf.Bytes()
+}
+
+func TestInspectPDF_LargeFileHeap(t *testing.T) {
+	const streamSize = 64 << 20
+	data := largePDF(3, streamSize)
+	limits := testPDFLimits
+	limits.MaxParseSize = 1 << 20
+
+	// 超过MaxParseSize的文件不加载流内容，校验期间分配的内存远小于文件大小
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	metadata, err := InspectPDF(bytes.NewReader(data), int64(len(data)), limits, "")
+	runtime.ReadMemStats(&after)
+	require.NoError(t, err)
+	allocated := after.TotalAlloc - before.TotalAlloc
+	t.Logf("文件%d字节，校验分配%d字节", len(data), allocated)
+	assert.Less(t, allocated, uint64(streamSize/8))
+
+	assert.Equal(t, 3, metadata.PageCount)
+	assert.Equal(t, []PageSize{{Width: 595, Height: 842}}, metadata.PageSizes)
+	assert.Equal(t, "Large Report", metadata.Title)
+	assert.Equal(t, "1.7", metadata.Version)
+}
+
+func TestPDFDate(t *testing.T) {
+	d := pdfDate("D:20240315093000+08'00'")
+	if assert.NotNil(t, d) {
+		assert.Equal(t, time.Date(2024, 3, 15, 1, 30, 0, 0, time.UTC), d.UTC())
+	}
+	assert.Nil(t, pdfDate(""))
+	assert.Nil(t, pdfDate("0001-01-01T00:00:00Z"))
+	assert.Nil(t, pdfDate("不是日期"))
+}
+
+func TestInspectPDF_Encrypted(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "download.pdf"))
+	require.NoError(t, err)
+
+	conf := model.NewAESConfiguration("user-secret", "owner-secret", 256)
+	var encrypted bytes.Buffer
+	require.NoError(t, api.Encrypt(bytes.NewReader(data), &encrypted, conf))
+	reader, size := bytes.NewReader(encrypted.Bytes()), int64(encrypted.Len())
+
+	_, err = InspectPDF(reader, size, PDFLimits{}, "")
+	assert.True(t, IsPDFLocked(err))
+	_, err = InspectPDF(reader, size, PDFLimits{}, "wrong")
+	assert.Equal(t, PDFRejectWrongPassword, validationReason(err))
+
+	metadata, err := InspectPDF(reader, size, PDFLimits{}, "user-secret")
+	require.NoError(t, err)
+	assert.True(t, metadata.Encrypted)
+
+	file, decryptedSize, err := DecryptPDFToTempFile(reader, size, "user-secret", PDFLimits{})
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+	pages, err := ParsePDFPagesAt(file, decryptedSize)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.PageCount, len(pages))
+	assert.NotEmpty(t, JoinPageText(pages))
+}
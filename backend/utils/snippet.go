@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 高亮标签
+const (
+	HighlightOpen  = "<mark>"
+	HighlightClose = "</mark>"
+)
+
+// HighlightSnippet 截取文本中第一个命中位置附近约maxRunes个字符的片段，并用<mark>标记命中的关键词
+// 返回的片段已做HTML转义，未命中任何关键词时返回空字符串
+func HighlightSnippet(text string, query string, maxRunes int) string {
+	terms := highlightTerms(query)
+	if len(terms) == 0 || text == "" {
+		return ""
+	}
+
+	lower := asciiLower(text)
+	if len(lower) != len(text) {
+		// 文本包含非法UTF-8字节时逐字节对应关系不成立，退回区分大小写的匹配
+		lower = text
+	}
+	first := -1
+	for _, term := range terms {
+		if idx := strings.Index(lower, term); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// 以命中位置为中心截取片段
+	start, end := snippetWindow(text, first, maxRunes)
+	window := text[start:end]
+	windowLower := lower[start:end]
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("...")
+	}
+	builder.WriteString(markTerms(window, windowLower, terms))
+	if end < len(text) {
+		builder.WriteString("...")
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// highlightTerms 从查询中提取高亮关键词：按空白和标点切分，中文词额外拆出相邻两字，以覆盖ngram检索命中的片段
+func highlightTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		term = asciiLower(term)
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	for _, word := range words {
+		add(word)
+	}
+	for _, term := range searchTerms(query) {
+		add(term)
+	}
+
+	// 长词优先匹配，避免被其中的短词截断
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return terms
+}
+
+// asciiLower 只转换ASCII字母的大小写，保证结果与原文按字节一一对应
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
+
+// snippetWindow 计算以pos为中心、约maxRunes个字符的字节区间
+func snippetWindow(text string, pos int, maxRunes int) (int, int) {
+	if maxRunes <= 0 || utf8.RuneCountInString(text) <= maxRunes {
+		return 0, len(text)
+	}
+
+	start := pos
+	for i := 0; i < maxRunes/3 && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(text[:start])
+		start -= size
+	}
+	end := start
+	for i := 0; i < maxRunes && end < len(text); i++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+	return start, end
+}
+
+// markTerms 转义文本并为关键词加上高亮标签
+func markTerms(text string, lower string, terms []string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) {
+				matched = term
+				break
+			}
+		}
+		if matched != "" {
+			builder.WriteString(HighlightOpen)
+			builder.WriteString(html.EscapeString(text[i : i+len(matched)]))
+			builder.WriteString(HighlightClose)
+			i += len(matched)
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		builder.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return builder.String()
+}
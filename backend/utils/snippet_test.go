@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	text := "本季度基金净值增长3.2%，主要受益于<科技>板块的上涨。"
+	assert.Equal(t, "本季度<mark>基金净值</mark>增长3.2%，主要受益于&lt;科技&gt;板块的上涨。",
+		HighlightSnippet(text, "基金净值", 100))
+
+	assert.Equal(t, "The <mark>NAV</mark> rose.", HighlightSnippet("The NAV rose.", "nav", 100))
+	assert.Empty(t, HighlightSnippet(text, "债券", 100))
+}
+
+func TestHighlightSnippet_Window(t *testing.T) {
+	text := strings.Repeat("前", 100) + "净值" + strings.Repeat("后", 100)
+	snippet := HighlightSnippet(text, "净值", 30)
+	assert.True(t, strings.HasPrefix(snippet, "..."))
+	assert.True(t, strings.HasSuffix(snippet, "..."))
+	assert.Contains(t, snippet, "<mark>净值</mark>")
+}
@@ -0,0 +1,253 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 分段加密格式：文件头 + 若干密文段。每段明文最多 EncryptedSegmentSize 字节，单独用AES-GCM加密，
+// 因此可以只解密需要的段来支持随机读取。
+// 文件头：魔数(4) + 版本(1) + 密钥ID(8) + 随机nonce前缀(7)；
+// 每段的nonce为 nonce前缀 + 段序号(4) + 是否最后一段(1)，文件头作为附加数据，防止段被截断、重排或替换密钥ID。
+const (
+	EncryptedSegmentSize = 64 << 10
+	encryptedHeaderSize  = 20
+	encryptedVersion     = 1
+	encryptedTagSize     = 16
+	noncePrefixSize      = 7
+)
+
+var encryptedMagic = []byte("PDFE")
+
+// ErrNotEncrypted 数据不是分段加密格式
+var ErrNotEncrypted = errors.New("数据未加密")
+
+// EncryptedSize 返回明文加密后的总长度
+func EncryptedSize(plainSize int64) int64 {
+	return encryptedHeaderSize + plainSize + segmentCount(plainSize)*encryptedTagSize
+}
+
+// segmentCount 明文的分段数，空内容也占一段
+func segmentCount(plainSize int64) int64 {
+	if plainSize == 0 {
+		return 1
+	}
+	return (plainSize + EncryptedSegmentSize - 1) / EncryptedSegmentSize
+}
+
+// ReadEncryptedKeyID 读取分段加密数据的密钥ID，数据不是加密格式时返回ErrNotEncrypted
+func ReadEncryptedKeyID(r io.ReaderAt) (int64, error) {
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, ErrNotEncrypted
+		}
+		return 0, fmt.Errorf("读取加密文件头失败: %w", err)
+	}
+	if !bytes.Equal(header[:4], encryptedMagic) || header[4] != encryptedVersion {
+		return 0, ErrNotEncrypted
+	}
+	return int64(binary.BigEndian.Uint64(header[5:13])), nil
+}
+
+// NewEncryptReader 返回读取src加密结果的reader，输出长度为 EncryptedSize(size)
+func NewEncryptReader(src io.ReaderAt, size int64, key []byte, keyID int64) (io.Reader, error) {
+	aead, err := newSegmentAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptedHeaderSize)
+	copy(header, encryptedMagic)
+	header[4] = encryptedVersion
+	binary.BigEndian.PutUint64(header[5:13], uint64(keyID))
+	if _, err := io.ReadFull(rand.Reader, header[13:]); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return &encryptReader{
+		src:      src,
+		size:     size,
+		aead:     aead,
+		header:   header,
+		segments: segmentCount(size),
+		buf:      header,
+		plain:    make([]byte, EncryptedSegmentSize),
+	}, nil
+}
+
+// encryptReader 按段读取明文并加密输出
+type encryptReader struct {
+	src      io.ReaderAt
+	size     int64
+	aead     cipher.AEAD
+	header   []byte
+	segments int64
+	next     int64  // 下一个待加密的段序号
+	buf      []byte // 尚未输出的密文
+	plain    []byte
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next >= r.segments {
+			return 0, io.EOF
+		}
+		offset := r.next * EncryptedSegmentSize
+		n := min(int64(EncryptedSegmentSize), r.size-offset)
+		plain := r.plain[:n]
+		if _, err := r.src.ReadAt(plain, offset); err != nil && !(errors.Is(err, io.EOF) && int64(len(plain)) == n) {
+			return 0, fmt.Errorf("读取待加密内容失败: %w", err)
+		}
+		last := r.next == r.segments-1
+		r.buf = r.aead.Seal(r.buf[:0:0], segmentNonce(r.header, r.next, last), plain, r.header)
+		r.next++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// DecryptReader 按需解密分段加密数据，支持顺序读取、随机读取和Seek
+type DecryptReader struct {
+	src      io.ReaderAt
+	aead     cipher.AEAD
+	header   []byte
+	size     int64 // 明文长度
+	segments int64
+	offset   int64 // Read和Seek使用的当前位置
+
+	// 最近解密的一段，顺序读取时避免重复解密
+	cached      int64
+	cachedPlain []byte
+	cipherBuf   []byte
+}
+
+// NewDecryptReader 打开长度为encSize的分段加密数据，key为加密时使用的数据密钥
+func NewDecryptReader(src io.ReaderAt, encSize int64, key []byte) (*DecryptReader, error) {
+	if _, err := ReadEncryptedKeyID(src); err != nil {
+		return nil, err
+	}
+	aead, err := newSegmentAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := src.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("读取加密文件头失败: %w", err)
+	}
+
+	body := encSize - encryptedHeaderSize
+	segments := (body + EncryptedSegmentSize + encryptedTagSize - 1) / (EncryptedSegmentSize + encryptedTagSize)
+	size := body - segments*encryptedTagSize
+	if segments < 1 || size < 0 || (size == 0 && segments != 1) {
+		return nil, errors.New("加密数据长度无效")
+	}
+	return &DecryptReader{
+		src:       src,
+		aead:      aead,
+		header:    header,
+		size:      size,
+		segments:  segments,
+		cached:    -1,
+		cipherBuf: make([]byte, EncryptedSegmentSize+encryptedTagSize),
+	}, nil
+}
+
+// Size 返回明文长度
+func (r *DecryptReader) Size() int64 {
+	return r.size
+}
+
+func (r *DecryptReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *DecryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("无效的whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *DecryptReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+	total := 0
+	for len(p) > 0 && off < r.size {
+		segment := off / EncryptedSegmentSize
+		plain, err := r.segment(segment)
+		if err != nil {
+			return total, err
+		}
+		n := copy(p, plain[off-segment*EncryptedSegmentSize:])
+		p = p[n:]
+		off += int64(n)
+		total += n
+	}
+	if len(p) > 0 {
+		return total, io.EOF
+	}
+	return total, nil
+}
+
+// segment 解密第index段
+func (r *DecryptReader) segment(index int64) ([]byte, error) {
+	if index == r.cached {
+		return r.cachedPlain, nil
+	}
+	plainLen := min(int64(EncryptedSegmentSize), r.size-index*EncryptedSegmentSize)
+	ciphertext := r.cipherBuf[:plainLen+encryptedTagSize]
+	offset := encryptedHeaderSize + index*(EncryptedSegmentSize+encryptedTagSize)
+	if _, err := r.src.ReadAt(ciphertext, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("读取加密内容失败: %w", err)
+	}
+	last := index == r.segments-1
+	plain, err := r.aead.Open(r.cachedPlain[:0], segmentNonce(r.header, index, last), ciphertext, r.header)
+	if err != nil {
+		r.cached = -1
+		return nil, fmt.Errorf("解密第%d段失败: %w", index, err)
+	}
+	r.cached, r.cachedPlain = index, plain
+	return plain, nil
+}
+
+// newSegmentAEAD 创建分段加密使用的AES-GCM
+func newSegmentAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce 生成第index段的nonce
+func segmentNonce(header []byte, index int64, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, header[encryptedHeaderSize-noncePrefixSize:])
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], uint32(index))
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encryptForTest(t *testing.T, plain []byte, key []byte) []byte {
+	reader, err := NewEncryptReader(bytes.NewReader(plain), int64(len(plain)), key, 42)
+	require.NoError(t, err)
+	encrypted, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, EncryptedSize(int64(len(plain))), int64(len(encrypted)))
+	return encrypted
+}
+
+func TestStreamCrypto_RoundTrip(t *testing.T) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, EncryptedSegmentSize, 3*EncryptedSegmentSize + 17} {
+		plain := make([]byte, size)
+		_, err := rand.Read(plain)
+		require.NoError(t, err)
+		encrypted := encryptForTest(t, plain, key)
+
+		keyID, err := ReadEncryptedKeyID(bytes.NewReader(encrypted))
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), keyID)
+
+		reader, err := NewDecryptReader(bytes.NewReader(encrypted), int64(len(encrypted)), key)
+		require.NoError(t, err)
+		assert.Equal(t, int64(size), reader.Size())
+		decrypted, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.True(t, bytes.Equal(plain, decrypted), "size %d", size)
+
+		// 跨段的随机读取
+		if size > EncryptedSegmentSize {
+			part := make([]byte, 100)
+			off := int64(EncryptedSegmentSize - 50)
+			_, err := reader.ReadAt(part, off)
+			require.NoError(t, err)
+			assert.Equal(t, plain[off:off+100], part)
+		}
+	}
+}
+
+func TestStreamCrypto_Tampered(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	plain := bytes.Repeat([]byte("报告内容"), EncryptedSegmentSize/4)
+	encrypted := encryptForTest(t, plain, key)
+
+	_, err := ReadEncryptedKeyID(bytes.NewReader(plain))
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	tampered := bytes.Clone(encrypted)
+	tampered[len(tampered)-1] ^= 1
+	reader, err := NewDecryptReader(bytes.NewReader(tampered), int64(len(tampered)), key)
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+
+	// 截断到段边界也会被发现：剩余的最后一段不是以最后一段的身份加密的
+	truncated := encrypted[:encryptedHeaderSize+EncryptedSegmentSize+encryptedTagSize]
+	reader, err = NewDecryptReader(bytes.NewReader(truncated), int64(len(truncated)), key)
+	require.NoError(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// runeTokenCost 估算单个字符消耗的token数：中日韩字符约1个token，其他字符约4个字符1个token
+func runeTokenCost(r rune) float64 {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return 1
+	}
+	return 0.25
+}
+
+// EstimateTokens 粗略估算文本的token数，用于在调用模型前控制输入长度
+func EstimateTokens(text string) int {
+	var cost float64
+	for _, r := range text {
+		cost += runeTokenCost(r)
+	}
+	return int(math.Ceil(cost))
+}
+
+// SplitTextIntoChunks 将文本切分为不超过maxTokens的块，相邻块之间保留overlapTokens的重叠
+// 优先在 PageSeparator 处切分，单页超长时再按换行和字符切分
+func SplitTextIntoChunks(text string, maxTokens, overlapTokens int) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+	if overlapTokens < 0 || overlapTokens >= maxTokens {
+		overlapTokens = 0
+	}
+
+	// 以页面为最小切分单元，超长页面继续拆分
+	var units []string
+	for _, page := range strings.Split(text, PageSeparator) {
+		if strings.TrimSpace(page) == "" {
+			continue
+		}
+		if EstimateTokens(page) <= maxTokens {
+			units = append(units, page)
+			continue
+		}
+		units = append(units, splitByTokens(page, maxTokens)...)
+	}
+
+	// 将单元依次装入块中
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+	for _, unit := range units {
+		unitTokens := EstimateTokens(unit)
+		if currentTokens > 0 && currentTokens+unitTokens > maxTokens {
+			chunks = append(chunks, current.String())
+
+			tail := tailByTokens(current.String(), overlapTokens)
+			current.Reset()
+			currentTokens = 0
+			if tail != "" && EstimateTokens(tail)+unitTokens <= maxTokens {
+				current.WriteString(tail)
+				currentTokens = EstimateTokens(tail)
+			}
+		}
+		if current.Len() > 0 {
+			current.WriteString(PageSeparator)
+		}
+		current.WriteString(unit)
+		currentTokens += unitTokens
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
+
+// splitByTokens 将超长文本按token上限拆分，尽量在换行处断开
+func splitByTokens(text string, maxTokens int) []string {
+	var parts []string
+	for text != "" {
+		var cost float64
+		cut, lastNewline := len(text), -1
+		for i, r := range text {
+			cost += runeTokenCost(r)
+			if cost > float64(maxTokens) {
+				cut = i
+				break
+			}
+			if r == '\n' {
+				lastNewline = i + 1
+			}
+		}
+		// 在后半段找到换行时优先在换行处断开
+		if cut < len(text) && lastNewline > cut/2 {
+			cut = lastNewline
+		}
+		if cut == 0 {
+			_, size := utf8.DecodeRuneInString(text)
+			cut = size
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	return parts
+}
+
+// TruncateToTokens 返回文本开头不超过maxTokens个token的内容，maxTokens不大于0时返回空字符串
+func TruncateToTokens(text string, maxTokens int) string {
+	var cost float64
+	for i, r := range text {
+		cost += runeTokenCost(r)
+		if cost > float64(maxTokens) {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// tailByTokens 返回文本末尾约maxTokens个token的内容
+func tailByTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	var cost float64
+	start := len(text)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		cost += runeTokenCost(r)
+		if cost > float64(maxTokens) {
+			break
+		}
+		start -= size
+	}
+	return text[start:]
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 0, EstimateTokens(""))
+	assert.Equal(t, 4, EstimateTokens("基金净值"))
+	assert.Equal(t, 2, EstimateTokens("nav-2025"))
+}
+
+func TestSplitTextIntoChunks_ShortText(t *testing.T) {
+	chunks := SplitTextIntoChunks("短文本", 100, 10)
+	assert.Equal(t, []string{"短文本"}, chunks)
+	assert.Empty(t, SplitTextIntoChunks("  \n", 100, 10))
+}
+
+func TestSplitTextIntoChunks_PageBoundaries(t *testing.T) {
+	pages := []string{
+		strings.Repeat("甲", 40),
+		strings.Repeat("乙", 40),
+		strings.Repeat("丙", 40),
+	}
+	text := strings.Join(pages, PageSeparator)
+
+	chunks := SplitTextIntoChunks(text, 100, 0)
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, pages[0]+PageSeparator+pages[1], chunks[0])
+	assert.Equal(t, pages[2], chunks[1])
+
+	for _, chunk := range SplitTextIntoChunks(text, 100, 10) {
+		assert.LessOrEqual(t, EstimateTokens(chunk), 100)
+	}
+}
+
+func TestSplitTextIntoChunks_OversizedPage(t *testing.T) {
+	text := strings.Repeat("字", 250)
+	chunks := SplitTextIntoChunks(text, 100, 0)
+	assert.Len(t, chunks, 3)
+	assert.Equal(t, text, strings.Join(chunks, ""))
+}
+
+func TestSplitTextIntoChunks_Overlap(t *testing.T) {
+	text := strings.Repeat("甲", 60) + PageSeparator + strings.Repeat("乙", 60)
+	chunks := SplitTextIntoChunks(text, 80, 10)
+	assert.Len(t, chunks, 2)
+	assert.True(t, strings.HasPrefix(chunks[1], strings.Repeat("甲", 10)+PageSeparator), "第二块应以上一块末尾的重叠内容开头")
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	assert.Equal(t, "基金", TruncateToTokens("基金净值", 2))
+	assert.Equal(t, "基金净值", TruncateToTokens("基金净值", 10))
+	assert.Equal(t, "nav-", TruncateToTokens("nav-2025", 1))
+	assert.Empty(t, TruncateToTokens("基金净值", 0))
+}
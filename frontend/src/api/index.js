@@ -11,9 +11,9 @@ if (token) {
 
 // 报告相关API
 export const reportApi = {
-  // 获取报告列表
-  getReports() {
-    return axios.get('/api/v1/reports')
+  // 分页获取报告列表
+  getReports(params = {}) {
+    return axios.get('/api/v1/reports', { params })
   },
   
   // 获取报告详情
@@ -21,10 +21,15 @@ export const reportApi = {
     return axios.get(`/api/v1/report/${reportId}`)
   },
   
-  // 生成报告摘要
+  // 创建报告摘要任务
   generateSummary(reportId) {
     return axios.post(`/api/v1/report/${reportId}/summary`)
   },
+
+  // 查询摘要任务状态
+  getJob(jobId) {
+    return axios.get(`/api/v1/jobs/${jobId}`)
+  },
   
   // 获取报告PDF的URL
   getReportPdfUrl(reportId) {
@@ -10,6 +10,8 @@ CREATE TABLE IF NOT EXISTS `users` (
   `email` varchar(100) NOT NULL COMMENT '邮箱',
   `password_hash` varchar(255) NOT NULL COMMENT '密码哈希',
   `salt` varchar(64) NOT NULL COMMENT '盐值',
+  `role` varchar(16) NOT NULL DEFAULT 'user' COMMENT '角色: user/admin',
+  `plan` varchar(32) NOT NULL DEFAULT 'free' COMMENT '大模型配额套餐，对应quota_plans.name',
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
   PRIMARY KEY (`id`),
@@ -26,11 +28,223 @@ CREATE TABLE IF NOT EXISTS `reports` (
   `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
   `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
   `pdf_path` varchar(255) DEFAULT NULL COMMENT 'PDF文件路径',
+  `deleted_at` timestamp NULL DEFAULT NULL COMMENT '移入回收站的时间，为空表示未删除',
+  `content_hash` char(64) DEFAULT NULL COMMENT 'PDF内容的SHA-256，用于去重；启用加密时按用户隔离',
+  `encryption_status` varchar(16) NOT NULL DEFAULT 'none' COMMENT 'PDF加密状态: none/locked/unlocked',
+  `summary_style` varchar(32) NOT NULL DEFAULT '' COMMENT '生成当前摘要时使用的风格，为空表示未记录',
+  `summary_length` varchar(16) NOT NULL DEFAULT '' COMMENT '生成当前摘要时使用的篇幅',
+  `summary_language` varchar(16) NOT NULL DEFAULT '' COMMENT '生成当前摘要时使用的输出语言',
   PRIMARY KEY (`id`),
   KEY `idx_user_id` (`user_id`),
+  KEY `idx_user_created_at` (`user_id`, `created_at`, `id`),
+  KEY `idx_user_title` (`user_id`, `title`, `id`),
+  KEY `idx_deleted_at` (`deleted_at`),
+  KEY `idx_content_hash` (`content_hash`, `created_at`),
+  KEY `idx_user_content_hash` (`user_id`, `content_hash`),
+  FULLTEXT KEY `ft_reports_search` (`title`, `content`, `summary`) WITH PARSER ngram,
+  FULLTEXT KEY `ft_reports_title` (`title`) WITH PARSER ngram,
   CONSTRAINT `fk_reports_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='报告表';
 
+-- 创建PDF对象引用计数表，相同内容的PDF只存储一份
+CREATE TABLE IF NOT EXISTS `blobs` (
+  `hash` char(64) NOT NULL COMMENT 'PDF内容的SHA-256',
+  `object_key` varchar(255) NOT NULL COMMENT '对象存储中的对象键',
+  `size` bigint NOT NULL COMMENT '文件大小（字节）',
+  `ref_count` int NOT NULL DEFAULT 0 COMMENT '引用该对象的报告数量',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`hash`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='PDF对象引用计数表';
+
+-- 创建用户数据密钥表
+CREATE TABLE IF NOT EXISTS `user_keys` (
+  `id` bigint NOT NULL AUTO_INCREMENT COMMENT '数据密钥ID',
+  `user_id` varchar(64) NOT NULL COMMENT '用户ID',
+  `wrapped_key` varchar(255) NOT NULL COMMENT '以主密钥加密后的数据密钥（base64）',
+  `master_key_version` int NOT NULL COMMENT '包装数据密钥的主密钥版本',
+  `active` tinyint(1) DEFAULT 1 COMMENT '1表示当前启用，NULL表示已轮换停用',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_user_active` (`user_id`, `active`),
+  KEY `idx_master_key_version` (`master_key_version`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户数据密钥表';
+
+-- 创建报告分页文本表
+CREATE TABLE IF NOT EXISTS `report_pages` (
+  `report_id` varchar(64) NOT NULL COMMENT '报告ID',
+  `page_number` int NOT NULL COMMENT '页码，从1开始',
+  `content` longtext COMMENT '页面文本',
+  `error` varchar(1024) DEFAULT NULL COMMENT '文本提取失败原因',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`report_id`, `page_number`),
+  CONSTRAINT `fk_report_pages_report_id` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='报告分页文本表';
+
+-- 创建报告PDF元数据表
+CREATE TABLE IF NOT EXISTS `report_metadata` (
+  `report_id` varchar(64) NOT NULL COMMENT '报告ID',
+  `title` text COMMENT '文档标题',
+  `author` text COMMENT '作者',
+  `subject` text COMMENT '主题',
+  `keywords` text COMMENT '关键词',
+  `creator` text COMMENT '创建文档的应用程序',
+  `producer` text COMMENT '生成PDF的应用程序',
+  `creation_date` datetime DEFAULT NULL COMMENT 'PDF创建时间',
+  `mod_date` datetime DEFAULT NULL COMMENT 'PDF修改时间',
+  `page_count` int NOT NULL DEFAULT 0 COMMENT '页数',
+  `page_sizes` text COMMENT '去重后的页面尺寸（JSON）',
+  `pdf_version` varchar(16) NOT NULL DEFAULT '' COMMENT 'PDF版本',
+  `encrypted` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否加密',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`report_id`),
+  KEY `idx_creation_date` (`creation_date`),
+  CONSTRAINT `fk_report_metadata_report_id` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='报告PDF元数据表';
+
+-- 创建摘要任务表
+CREATE TABLE IF NOT EXISTS `summary_jobs` (
+  `id` varchar(64) NOT NULL COMMENT '任务ID',
+  `report_id` varchar(64) NOT NULL COMMENT '报告ID',
+  `user_id` varchar(64) NOT NULL COMMENT '用户ID',
+  `status` varchar(16) NOT NULL DEFAULT 'queued' COMMENT '任务状态: queued/running/succeeded/failed',
+  `summary_style` varchar(32) NOT NULL DEFAULT '' COMMENT '摘要风格，为空时使用默认风格',
+  `summary_length` varchar(16) NOT NULL DEFAULT '' COMMENT '摘要篇幅: short/medium/long',
+  `summary_language` varchar(16) NOT NULL DEFAULT '' COMMENT '摘要输出语言: zh/en',
+  `error` text COMMENT '失败原因',
+  `error_code` smallint DEFAULT NULL COMMENT '失败时的错误码，与同步接口返回的HTTP状态码相同',
+  `retry_after` int DEFAULT NULL COMMENT '失败时建议在finished_at之后等待的秒数',
+  `quota_reserved_tokens` bigint DEFAULT NULL COMMENT '创建任务时预留的token数，NULL表示未预留配额',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+  `started_at` timestamp NULL DEFAULT NULL COMMENT '开始执行时间',
+  `finished_at` timestamp NULL DEFAULT NULL COMMENT '结束时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_status_created_at` (`status`, `created_at`),
+  KEY `idx_report_id` (`report_id`),
+  CONSTRAINT `fk_summary_jobs_report_id` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='摘要任务表';
+
+-- 创建报告分享链接表
+CREATE TABLE IF NOT EXISTS `report_shares` (
+  `id` varchar(64) NOT NULL COMMENT '分享ID',
+  `report_id` varchar(64) NOT NULL COMMENT '报告ID',
+  `user_id` varchar(64) NOT NULL COMMENT '创建分享的用户ID',
+  `token_hash` char(64) NOT NULL COMMENT '分享令牌的SHA-256，令牌本身不保存',
+  `password_hash` varchar(255) DEFAULT NULL COMMENT '访问密码哈希，NULL表示不需要密码',
+  `allow_download` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否允许下载PDF',
+  `expires_at` timestamp NULL DEFAULT NULL COMMENT '过期时间，NULL表示永不过期',
+  `revoked_at` timestamp NULL DEFAULT NULL COMMENT '撤销时间',
+  `access_count` bigint unsigned NOT NULL DEFAULT 0 COMMENT '访问次数',
+  `last_accessed_at` timestamp NULL DEFAULT NULL COMMENT '最近访问时间',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_token_hash` (`token_hash`),
+  KEY `idx_report_user` (`report_id`, `user_id`),
+  CONSTRAINT `fk_report_shares_report_id` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='报告分享链接表';
+
+-- 创建团队表
+CREATE TABLE IF NOT EXISTS `teams` (
+  `id` varchar(64) NOT NULL COMMENT '团队ID',
+  `name` varchar(255) NOT NULL COMMENT '团队名称',
+  `owner_id` varchar(64) NOT NULL COMMENT '创建者用户ID',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_owner_id` (`owner_id`),
+  CONSTRAINT `fk_teams_owner_id` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='团队表';
+
+-- 创建团队成员表
+CREATE TABLE IF NOT EXISTS `team_members` (
+  `team_id` varchar(64) NOT NULL COMMENT '团队ID',
+  `user_id` varchar(64) NOT NULL COMMENT '成员用户ID',
+  `role` varchar(16) NOT NULL DEFAULT 'member' COMMENT '团队角色: admin/member',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '加入时间',
+  PRIMARY KEY (`team_id`, `user_id`),
+  KEY `idx_user_id` (`user_id`),
+  CONSTRAINT `fk_team_members_team_id` FOREIGN KEY (`team_id`) REFERENCES `teams` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_team_members_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='团队成员表';
+
+-- 创建报告授权表，记录共享给用户或团队的报告角色
+CREATE TABLE IF NOT EXISTS `report_grants` (
+  `id` varchar(64) NOT NULL COMMENT '授权ID',
+  `report_id` varchar(64) NOT NULL COMMENT '报告ID',
+  `grantee_type` varchar(16) NOT NULL COMMENT '授权对象类型: user/team',
+  `grantee_id` varchar(64) NOT NULL COMMENT '被授权的用户ID或团队ID',
+  `role` varchar(16) NOT NULL COMMENT '角色: viewer/editor',
+  `granted_by` varchar(64) NOT NULL COMMENT '授权人用户ID',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '授权时间',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_report_grantee` (`report_id`, `grantee_type`, `grantee_id`),
+  KEY `idx_grantee` (`grantee_type`, `grantee_id`),
+  CONSTRAINT `fk_report_grants_report_id` FOREIGN KEY (`report_id`) REFERENCES `reports` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='报告授权表';
+
+-- 创建大模型用量表，不设外键，用户或报告删除后仍保留用量用于核算
+CREATE TABLE IF NOT EXISTS `llm_usage` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT COMMENT '记录ID',
+  `user_id` varchar(64) NOT NULL DEFAULT '' COMMENT '用量归属的用户ID',
+  `report_id` varchar(64) NOT NULL DEFAULT '' COMMENT '关联的报告ID',
+  `operation` varchar(32) NOT NULL DEFAULT '' COMMENT '业务操作: summary/summary_chunk/summary_combine/ask',
+  `request_id` varchar(64) NOT NULL DEFAULT '' COMMENT '用户发起的AI请求ID，同一请求的多次调用相同',
+  `provider` varchar(32) NOT NULL COMMENT '大模型服务: openai/ollama/fake',
+  `model` varchar(128) NOT NULL DEFAULT '' COMMENT '模型名称',
+  `prompt_tokens` int unsigned NOT NULL DEFAULT 0 COMMENT '输入token数',
+  `completion_tokens` int unsigned NOT NULL DEFAULT 0 COMMENT '输出token数',
+  `total_tokens` int unsigned NOT NULL DEFAULT 0 COMMENT '总token数',
+  `estimated` tinyint(1) NOT NULL DEFAULT 0 COMMENT '服务端未返回用量，token数为估算值',
+  `latency_ms` int unsigned NOT NULL DEFAULT 0 COMMENT '耗时（毫秒），包括重试',
+  `cost` decimal(16,8) NOT NULL DEFAULT 0 COMMENT '按配置单价估算的费用',
+  `status` varchar(16) NOT NULL COMMENT '调用结果: success/error',
+  `created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '调用时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_user_created_at` (`user_id`, `created_at`),
+  KEY `idx_created_at` (`created_at`),
+  KEY `idx_report_id` (`report_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='大模型用量表';
+
+-- 创建大模型配额套餐表，配额为NULL表示不限制
+CREATE TABLE IF NOT EXISTS `quota_plans` (
+  `name` varchar(32) NOT NULL COMMENT '套餐名称',
+  `daily_requests` int unsigned DEFAULT NULL COMMENT '每天的AI请求数上限',
+  `daily_tokens` bigint unsigned DEFAULT NULL COMMENT '每天的token数上限',
+  `monthly_requests` int unsigned DEFAULT NULL COMMENT '每月的AI请求数上限',
+  `monthly_tokens` bigint unsigned DEFAULT NULL COMMENT '每月的token数上限',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='大模型配额套餐表';
+
+-- 创建用户单独配额表，配额为NULL时沿用套餐配额
+CREATE TABLE IF NOT EXISTS `user_quotas` (
+  `user_id` varchar(64) NOT NULL COMMENT '用户ID',
+  `daily_requests` int unsigned DEFAULT NULL COMMENT '每天的AI请求数上限',
+  `daily_tokens` bigint unsigned DEFAULT NULL COMMENT '每天的token数上限',
+  `monthly_requests` int unsigned DEFAULT NULL COMMENT '每月的AI请求数上限',
+  `monthly_tokens` bigint unsigned DEFAULT NULL COMMENT '每月的token数上限',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`user_id`),
+  CONSTRAINT `fk_user_quotas_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户单独配额表';
+
+-- 创建配额用量计数表，调用大模型前在此加锁预留，结束后按实际用量结算
+CREATE TABLE IF NOT EXISTS `quota_usage` (
+  `user_id` varchar(64) NOT NULL COMMENT '用户ID',
+  `period` varchar(16) NOT NULL COMMENT '统计周期: d:2006-01-02 或 m:2006-01',
+  `requests` bigint NOT NULL DEFAULT 0 COMMENT '请求数，包括进行中的请求',
+  `tokens` bigint NOT NULL DEFAULT 0 COMMENT 'token数，包括进行中请求预留的部分',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '更新时间',
+  PRIMARY KEY (`user_id`, `period`),
+  CONSTRAINT `fk_quota_usage_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='配额用量计数表';
+
+-- 默认套餐，可通过管理员接口修改
+INSERT INTO `quota_plans` (`name`, `daily_requests`, `daily_tokens`, `monthly_requests`, `monthly_tokens`) VALUES
+('free', 20, 200000, 300, 3000000),
+('pro', 200, 2000000, 5000, 50000000),
+('unlimited', NULL, NULL, NULL, NULL);
+
 -- 插入示例用户数据（密码为测试密码的哈希值，实际应用中应该使用Argon2id生成）
 INSERT INTO `users` (`id`, `name`, `email`, `password_hash`, `salt`) VALUES
 ('u123', 'Alice', 'alice@example.com', '$argon2id$v=19$m=65536,t=3,p=4$c2FsdHNhbHRzYWx0c2FsdA$bgGZ6ZNWcszOhcLPPb8/8QcBGCDOm1wBnBNCy+fGqhg', 'saltsaltsaltsalt'),